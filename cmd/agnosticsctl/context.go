@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Context contains the information to connect to a scheduler.
+type Context struct {
+	Name string `yaml:"name"`
+	// Server is the base URL of the scheduler API, for example https://scheduler.example.com
+	Server string `yaml:"server"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// InsecureSkipTLSVerify disables the verification of the server certificate.
+	InsecureSkipTLSVerify bool `yaml:"insecure-skip-tls-verify,omitempty"`
+	// CertificateAuthority is the path of the PEM CA bundle used to verify the server certificate.
+	CertificateAuthority string `yaml:"certificate-authority,omitempty"`
+	// ClientCertificate and ClientKey are the paths of the PEM client certificate and key,
+	// for the schedulers started with '-tls-client-ca'.
+	ClientCertificate string `yaml:"client-certificate,omitempty"`
+	ClientKey string `yaml:"client-key,omitempty"`
+}
+
+// CtlConfig is the content of the context file, similar to a kubeconfig.
+type CtlConfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts []Context `yaml:"contexts"`
+}
+
+// defaultConfigPath returns the path of the context file:
+// $AGNOSTICSCONFIG if set, ~/.agnostics/config otherwise.
+func defaultConfigPath() string {
+	if e := os.Getenv("AGNOSTICSCONFIG"); e != "" {
+		return e
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".agnostics", "config")
+}
+
+// readCtlConfig reads the context file.
+func readCtlConfig(path string) (CtlConfig, error) {
+	config := CtlConfig{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return config, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// saveCtlConfig writes the context file. It contains passwords, so only the user can read it.
+func saveCtlConfig(path string, config CtlConfig) error {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// loadContext reads the context file and returns the context 'name',
+// or the current context if name is empty.
+func loadContext(path string, name string) (Context, error) {
+	config, err := readCtlConfig(path)
+	if err != nil {
+		return Context{}, err
+	}
+
+	if name == "" {
+		name = config.CurrentContext
+	}
+	if name == "" {
+		return Context{}, errors.New("no context specified and no current-context in " + path)
+	}
+	for _, c := range config.Contexts {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return Context{}, fmt.Errorf("context '%s' not found in %s", name, path)
+}
+
+// resolveContext returns the context of the commands: the context 'name' of the context file,
+// with the server replaced by server if it's not empty. A missing context file is only
+// accepted with server and without name.
+func resolveContext(path string, name string, server string) (Context, error) {
+	c, err := loadContext(path, name)
+	if err != nil {
+		if server == "" || name != "" || ! os.IsNotExist(err) {
+			return Context{}, err
+		}
+		c = Context{}
+	}
+	if server != "" {
+		c.Server = server
+	}
+	return c, nil
+}
+
+// useContext sets the current context of the context file to the context 'name'.
+func useContext(path string, name string) error {
+	config, err := readCtlConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, c := range config.Contexts {
+		if c.Name == name {
+			config.CurrentContext = name
+			return saveCtlConfig(path, config)
+		}
+	}
+	return fmt.Errorf("context '%s' not found in %s", name, path)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCtlConfig = `current-context: prod
+contexts:
+  - name: prod
+    server: https://scheduler.example.com
+    username: admin
+    password: changeme
+  - name: local
+    server: http://localhost:8080
+`
+
+// setenv sets the environment variable, or unsets it if value is empty, until the end of the test.
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// writeCtlConfig writes the context file in a temporary directory and returns its path.
+func writeCtlConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	home := t.TempDir()
+	testCases := []struct {
+		name string
+		env string
+		expected string
+	}{
+		{"AGNOSTICSCONFIG", "/etc/agnostics/config", "/etc/agnostics/config"},
+		{"home directory", "", filepath.Join(home, ".agnostics", "config")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setenv(t, "HOME", home)
+			setenv(t, "AGNOSTICSCONFIG", tc.env)
+			if path := defaultConfigPath(); path != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, path)
+			}
+		})
+	}
+}
+
+func TestLoadContext(t *testing.T) {
+	testCases := []struct {
+		name string
+		content string
+		context string
+		server string
+		err string
+	}{
+		{"current context", testCtlConfig, "", "https://scheduler.example.com", ""},
+		{"named context", testCtlConfig, "local", "http://localhost:8080", ""},
+		{"unknown context", testCtlConfig, "staging", "", "context 'staging' not found"},
+		{"no current context", "contexts:\n  - name: local\n    server: http://localhost:8080\n", "", "", "no current-context"},
+		{"unknown field", "current-context: prod\ncontexts:\n  - name: prod\n    url: https://scheduler.example.com\n", "", "", "field url not found"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := loadContext(writeCtlConfig(t, tc.content), tc.context)
+			if tc.err != "" {
+				if err == nil || ! strings.Contains(err.Error(), tc.err) {
+					t.Errorf("expected the error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Server != tc.server {
+				t.Errorf("expected the server %s, got %s", tc.server, c.Server)
+			}
+		})
+	}
+
+	if _, err := loadContext(filepath.Join(t.TempDir(), "missing"), ""); ! os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestResolveContext(t *testing.T) {
+	path := writeCtlConfig(t, testCtlConfig)
+	missing := filepath.Join(t.TempDir(), "missing")
+	testCases := []struct {
+		name string
+		path string
+		context string
+		server string
+		expected string
+		err bool
+	}{
+		{"current context", path, "", "", "https://scheduler.example.com", false},
+		{"server", path, "", "http://localhost:9090", "http://localhost:9090", false},
+		{"server of a named context", path, "local", "http://localhost:9090", "http://localhost:9090", false},
+		{"unknown context with server", path, "staging", "http://localhost:9090", "", true},
+		{"invalid file with server", writeCtlConfig(t, "contexts: [\n"), "", "http://localhost:9090", "", true},
+		{"no file", missing, "", "", "", true},
+		{"no file with server", missing, "", "http://localhost:9090", "http://localhost:9090", false},
+		{"no file with a context", missing, "local", "http://localhost:9090", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := resolveContext(tc.path, tc.context, tc.server)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error=%v, got %+v %v", tc.err, c, err)
+			}
+			if c.Server != tc.expected {
+				t.Errorf("expected the server %q, got %q", tc.expected, c.Server)
+			}
+		})
+	}
+	// The credentials of the context are kept
+	if c, err := resolveContext(path, "prod", "http://localhost:9090"); err != nil || c.Username != "admin" {
+		t.Errorf("expected the username of the context, got %+v %v", c, err)
+	}
+}
+
+func TestSaveContext(t *testing.T) {
+	// The directory ~/.agnostics is created if needed
+	path := filepath.Join(t.TempDir(), ".agnostics", "config")
+	config := CtlConfig{
+		CurrentContext: "local",
+		Contexts: []Context{
+			{Name: "local", Server: "http://localhost:8080"},
+			{Name: "prod", Server: "https://scheduler.example.com", Username: "admin", Password: "changeme", InsecureSkipTLSVerify: true},
+		},
+	}
+	if err := saveCtlConfig(path, config); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the file to be readable by the user only, got %v", info.Mode().Perm())
+	}
+
+	c, err := loadContext(path, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != config.Contexts[1] {
+		t.Errorf("expected %+v, got %+v", config.Contexts[1], c)
+	}
+}
+
+func TestUseContext(t *testing.T) {
+	path := writeCtlConfig(t, testCtlConfig)
+
+	testCases := []struct {
+		name string
+		context string
+		err bool
+		server string
+	}{
+		{"switch", "local", false, "http://localhost:8080"},
+		{"switch back", "prod", false, "https://scheduler.example.com"},
+		{"unknown context", "staging", true, "https://scheduler.example.com"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := runContext(path, []string{"context", "use", tc.context})
+			if (err != nil) != tc.err {
+				t.Errorf("expected error=%v, got %v %v", tc.err, result, err)
+			}
+			// The current context of the file
+			c, err := loadContext(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Server != tc.server {
+				t.Errorf("expected the current context to use %s, got %s", tc.server, c.Server)
+			}
+		})
+	}
+
+	if _, err := runContext(path, []string{"context", "use"}); err == nil {
+		t.Error("expected an error without the name of the context")
+	}
+}
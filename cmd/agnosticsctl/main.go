@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/client"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `agnosticsctl is the command-line client of the agnostics scheduler.
+
+Usage:
+  agnosticsctl [flags] <command> [arguments]
+
+Commands:
+  schedule [schedule flags]          Request a placement
+  dry-run [schedule flags]           Show where a placement would go, without saving it
+  placements list                    List all the placements
+  placements get <uuid>              Show a placement
+  placements delete <uuid>           Delete a placement
+  clouds list                        List all the clouds
+  clouds get <name>                  Show a cloud
+  taint <cloud> <key>[=<value>]:<effect>
+                                     Add a taint to a cloud
+  untaint <cloud> <key>:<effect>     Remove a taint from a cloud
+  repo show                          Show the commit of the config currently used
+  repo pull                          Request the scheduler to pull the config repository
+  context use <name>                 Switch the current-context of the context file
+
+Schedule flags:
+  -uuid string                       The uuid of the service
+  -selector key=value                Label the cloud must have (repeatable)
+  -preference key=value              Label the cloud should have (repeatable)
+  -toleration key[=value][:effect]   Toleration, operator is Exists if no value (repeatable)
+  -annotation key=value              Annotation of the placement (repeatable)
+
+Flags:
+`
+
+// Global flags
+var configPath string
+var contextName string
+var server string
+var output string
+
+// mapFlag is a repeatable flag 'key=value'
+type mapFlag map[string]string
+
+func (m mapFlag) String() string {
+	return formatMap(m)
+}
+
+func (m mapFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("'%s' must be in the format key=value", s)
+	}
+	m[kv[0]] = kv[1]
+	return nil
+}
+
+// tolerationsFlag is a repeatable flag 'key[=value][:effect]'
+type tolerationsFlag []v1.Toleration
+
+func (t *tolerationsFlag) String() string {
+	return fmt.Sprint(*t)
+}
+
+func (t *tolerationsFlag) Set(s string) error {
+	tol := v1.Toleration{}
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		tol.Effect = s[i+1:]
+		s = s[:i]
+	}
+	kv := strings.SplitN(s, "=", 2)
+	tol.Key = kv[0]
+	if len(kv) == 2 {
+		tol.Operator = v1.TolerationOpEqual
+		tol.Value = kv[1]
+	} else {
+		tol.Operator = v1.TolerationOpExists
+	}
+	*t = append(*t, tol)
+	return nil
+}
+
+func parseScheduleQuery(args []string) (v1.ScheduleQuery, error) {
+	fs := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	q := v1.ScheduleQuery{
+		CloudSelector: map[string]string{},
+		CloudPreference: map[string]string{},
+		Annotations: map[string]string{},
+	}
+	tolerations := tolerationsFlag{}
+	fs.StringVar(&q.UUID, "uuid", "", "The uuid of the service")
+	fs.Var(mapFlag(q.CloudSelector), "selector", "Label the cloud must have (key=value)")
+	fs.Var(mapFlag(q.CloudPreference), "preference", "Label the cloud should have (key=value)")
+	fs.Var(&tolerations, "toleration", "Toleration key[=value][:effect]")
+	fs.Var(mapFlag(q.Annotations), "annotation", "Annotation of the placement (key=value)")
+	if err := fs.Parse(args); err != nil {
+		return q, err
+	}
+	if fs.NArg() > 0 {
+		return q, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	q.Tolerations = tolerations
+	return q, nil
+}
+
+// run executes the command and returns the object to print.
+func run(c *client.Client, args []string) (interface{}, error) {
+	ctx := context.Background()
+	argsError := errors.New("invalid arguments, see 'agnosticsctl -h'")
+	if len(args) == 0 {
+		return nil, argsError
+	}
+
+	switch args[0] {
+	case "schedule", "dry-run":
+		q, err := parseScheduleQuery(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if args[0] == "dry-run" {
+			return c.DryRunSchedule(ctx, q)
+		}
+		return c.Schedule(ctx, q)
+
+	case "placements":
+		switch {
+		case len(args) == 2 && args[1] == "list":
+			return c.GetPlacements(ctx)
+		case len(args) == 3 && args[1] == "get":
+			return c.GetPlacement(ctx, args[2])
+		case len(args) == 3 && args[1] == "delete":
+			return c.DeletePlacement(ctx, args[2])
+		}
+
+	case "clouds":
+		switch {
+		case len(args) == 2 && args[1] == "list":
+			return c.GetClouds(ctx)
+		case len(args) == 3 && args[1] == "get":
+			return c.GetCloud(ctx, args[2])
+		}
+
+	case "taint", "untaint":
+		if len(args) != 3 {
+			return nil, argsError
+		}
+		t, err := v1.ParseTaint(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if args[0] == "taint" {
+			return c.Taint(ctx, args[1], t)
+		}
+		return c.Untaint(ctx, args[1], t)
+
+	case "repo":
+		switch {
+		case len(args) == 2 && args[1] == "show":
+			return c.GetRepository(ctx)
+		case len(args) == 2 && args[1] == "pull":
+			return c.PullRepository(ctx)
+		}
+	}
+	return nil, argsError
+}
+
+// runContext executes the command on the context file at path and returns the object to print.
+func runContext(path string, args []string) (interface{}, error) {
+	if len(args) != 3 || args[1] != "use" {
+		return nil, errors.New("invalid arguments, see 'agnosticsctl -h'")
+	}
+	if err := useContext(path, args[2]); err != nil {
+		return nil, err
+	}
+	return v1.Message{Message: "Switched to context '" + args[2] + "'."}, nil
+}
+
+// newClient returns the API client for the context.
+func newClient(c Context) (*client.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: c.InsecureSkipTLSVerify}
+	if c.CertificateAuthority != "" {
+		pem, err := ioutil.ReadFile(c.CertificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if ! pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CertificateAuthority)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if c.ClientCertificate != "" || c.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertificate, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	options := []client.Option{
+		client.WithHTTPClient(&http.Client{Transport: transport, Timeout: 30 * time.Second}),
+	}
+	if c.Username != "" {
+		options = append(options, client.WithBasicAuth(c.Username, c.Password))
+	}
+	return client.New(c.Server, options...), nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&configPath, "config", defaultConfigPath(), "The path of the context file.\nEnvironment variable: AGNOSTICSCONFIG\n")
+	flag.StringVar(&contextName, "context", "", "The context to use. Default is the current-context of the context file.")
+	flag.StringVar(&server, "server", "", "The URL of the scheduler. Overrides the server of the context.")
+	flag.StringVar(&output, "o", "table", "Output format: table, json or yaml.")
+	flag.Parse()
+
+	// The context commands don't connect to the scheduler
+	if flag.NArg() > 0 && flag.Arg(0) == "context" {
+		result, err := runContext(configPath, flag.Args())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			os.Exit(1)
+		}
+		if err := printOutput(os.Stdout, output, result); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ctlContext, err := resolveContext(configPath, contextName, server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+
+	c, err := newClient(ctlContext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	result, err := run(c, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	if err := printOutput(os.Stdout, output, result); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"github.com/redhat-gpe/agnostics/internal/api"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/client"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestServer loads a config with two clouds and starts a server with the real API handlers.
+// Redis is not available in the tests.
+func newTestServer(t *testing.T) *httptest.Server {
+	log.InitLoggers(false)
+	dir := t.TempDir()
+	files := map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicates\n  - name: TaintPredicates\npriorities:\n  - name: LabelPriorities\n    weight: 1\n",
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels:\n  region: na\n  purpose: ilt\n",
+		"clouds/openstack-red.yml": "name: openstack-red\nlabels:\n  region: emea\n  purpose: ilt\n",
+	}
+	os.Mkdir(filepath.Join(dir, "clouds"), 0755)
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := config.LoadFromDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	router, err := api.NewRouter(false, "", api.ValidationEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseScheduleQuery(t *testing.T) {
+	q, err := parseScheduleQuery([]string{
+		"-uuid", "4be1d9d2",
+		"-selector", "purpose=ilt",
+		"-preference", "region=na",
+		"-toleration", "maintenance=incident-42:NoSchedule",
+		"-toleration", "degraded",
+		"-annotation", "owner=alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := v1.ScheduleQuery{
+		UUID: "4be1d9d2",
+		CloudSelector: map[string]string{"purpose": "ilt"},
+		CloudPreference: map[string]string{"region": "na"},
+		Tolerations: []v1.Toleration{
+			{Key: "maintenance", Operator: v1.TolerationOpEqual, Value: "incident-42", Effect: v1.TaintEffectNoSchedule},
+			{Key: "degraded", Operator: v1.TolerationOpExists},
+		},
+		Annotations: map[string]string{"owner": "alice"},
+	}
+	if ! reflect.DeepEqual(q, expected) {
+		t.Errorf("expected %+v, got %+v", expected, q)
+	}
+
+	for _, args := range [][]string{{"-selector", "purpose"}, {"-uuid", "4be1d9d2", "extra"}} {
+		if _, err := parseScheduleQuery(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := newTestServer(t)
+	c := client.New(server.URL)
+
+	testCases := []struct {
+		name string
+		args []string
+		expected interface{}
+		err error
+	}{
+		{"clouds list", []string{"clouds", "list"}, []v1.Cloud{}, nil},
+		{"clouds get", []string{"clouds", "get", "openstack-red"}, v1.Cloud{}, nil},
+		{"unknown cloud", []string{"clouds", "get", "openstack-gold"}, nil, client.ErrNotFound},
+		{"dry-run", []string{"dry-run", "-selector", "purpose=ilt", "-preference", "region=emea"}, v1.Placement{}, nil},
+		{"no cloud", []string{"dry-run", "-selector", "region=apac"}, nil, client.ErrNotFound},
+		{"unknown command", []string{"clusters", "list"}, nil, nil},
+		{"no command", []string{}, nil, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := run(c, tc.args)
+			if tc.expected == nil {
+				if err == nil || (tc.err != nil && ! errors.Is(err, tc.err)) {
+					t.Errorf("expected the error %v, got %v %v", tc.err, result, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.TypeOf(result) != reflect.TypeOf(tc.expected) {
+				t.Errorf("expected a %T, got %T", tc.expected, result)
+			}
+		})
+	}
+}
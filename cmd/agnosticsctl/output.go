@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"gopkg.in/yaml.v2"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printJSON prints the object as indented JSON, like the API.
+func printJSON(w io.Writer, data interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// printYAML prints the object as YAML. The JSON field names are used,
+// so the output is consistent with the API and the JSON output.
+func printYAML(w io.Writer, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(b, &generic); err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// formatMap returns the map as a sorted list 'k=v,k2=v2'.
+func formatMap(m map[string]string) string {
+	result := []string{}
+	for k, v := range m {
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+	return strings.Join(result, ",")
+}
+
+func formatTaints(taints []v1.Taint) string {
+	result := []string{}
+	for _, t := range taints {
+		result = append(result, t.String())
+	}
+	return strings.Join(result, ",")
+}
+
+// printTable prints the object as a table. Unknown types are printed as YAML.
+func printTable(w io.Writer, data interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch v := data.(type) {
+	case v1.Placement:
+		return printTable(w, []v1.Placement{v})
+	case []v1.Placement:
+		fmt.Fprintln(tw, "UUID\tCLOUD\tCREATED\tANNOTATIONS")
+		for _, p := range v {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.UUID, p.Cloud.Name,
+				p.CreationTimestamp.Format(time.RFC3339), formatMap(p.Annotations))
+		}
+	case v1.Cloud:
+		return printTable(w, []v1.Cloud{v})
+	case []v1.Cloud:
+		sort.Slice(v, func(i, j int) bool { return v[i].Name < v[j].Name })
+		fmt.Fprintln(tw, "NAME\tENABLED\tLABELS\tTAINTS")
+		for _, c := range v {
+			fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", c.Name, c.Enabled, formatMap(c.Labels), formatTaints(c.Taints))
+		}
+	case v1.GitCommit:
+		fmt.Fprintln(tw, "HASH\tAUTHOR\tDATE\tORIGIN")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Hash, v.Author, v.Date, v.Origin)
+	case v1.Message:
+		fmt.Fprintln(tw, v.Message)
+	default:
+		return printYAML(w, data)
+	}
+	return nil
+}
+
+// printOutput prints the object in the format: table, json or yaml.
+func printOutput(w io.Writer, format string, data interface{}) error {
+	switch format {
+	case "json":
+		return printJSON(w, data)
+	case "yaml":
+		return printYAML(w, data)
+	case "table", "":
+		return printTable(w, data)
+	default:
+		return fmt.Errorf("unknown output format '%s', must be table, json or yaml", format)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/client"
+	"gopkg.in/yaml.v2"
+	"strings"
+	"testing"
+)
+
+func TestPrintOutput(t *testing.T) {
+	server := newTestServer(t)
+	c := client.New(server.URL)
+
+	testCases := []struct {
+		name string
+		args []string
+		format string
+		// contains are the lines expected in the output
+		contains []string
+	}{
+		{"clouds table", []string{"clouds", "list"}, "table", []string{
+			"NAME            ENABLED  LABELS                   TAINTS",
+			"openstack-blue  true     purpose=ilt,region=na    ",
+			"openstack-red   true     purpose=ilt,region=emea  ",
+		}},
+		{"clouds json", []string{"clouds", "list"}, "json", []string{`    "name": "openstack-blue",`}},
+		{"clouds yaml", []string{"clouds", "list"}, "yaml", []string{"- enabled: true", "  name: openstack-blue", "    region: emea"}},
+		{"cloud table", []string{"clouds", "get", "openstack-red"}, "table", []string{"openstack-red  true     purpose=ilt,region=emea  "}},
+		{"default format", []string{"clouds", "get", "openstack-red"}, "", []string{"NAME           ENABLED  LABELS                   TAINTS"}},
+		{"cloud json", []string{"clouds", "get", "openstack-red"}, "json", []string{`  "name": "openstack-red",`}},
+		{"cloud yaml", []string{"clouds", "get", "openstack-red"}, "yaml", []string{"name: openstack-red"}},
+		{"placement table", []string{"dry-run", "-uuid", "4be1d9d2", "-selector", "region=na"}, "table", []string{"UUID      CLOUD           CREATED               ANNOTATIONS"}},
+		{"placement json", []string{"dry-run", "-uuid", "4be1d9d2", "-selector", "region=na"}, "json", []string{`  "uuid": "4be1d9d2",`}},
+		{"placement yaml", []string{"dry-run", "-uuid", "4be1d9d2", "-selector", "region=na"}, "yaml", []string{"uuid: 4be1d9d2", "  name: openstack-blue"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := run(c, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := printOutput(&out, tc.format, result); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(out.String(), "\n")
+			for _, expected := range tc.contains {
+				found := false
+				for _, line := range lines {
+					if line == expected {
+						found = true
+						break
+					}
+				}
+				if ! found {
+					t.Errorf("expected the line %q in the output:\n%s", expected, out.String())
+				}
+			}
+
+			// The JSON and the YAML outputs can be read back
+			switch tc.format {
+			case "json":
+				if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+					t.Errorf("invalid JSON output: %v", err)
+				}
+			case "yaml":
+				var generic interface{}
+				if err := yaml.Unmarshal(out.Bytes(), &generic); err != nil {
+					t.Errorf("invalid YAML output: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintMessage(t *testing.T) {
+	for format, expected := range map[string]string{
+		"table": "Switched to context 'local'.\n",
+		"json": "{\n  \"message\": \"Switched to context 'local'.\"\n}\n",
+		"yaml": "message: Switched to context 'local'.\n",
+	} {
+		var out bytes.Buffer
+		if err := printOutput(&out, format, v1.Message{Message: "Switched to context 'local'."}); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != expected {
+			t.Errorf("%s: expected %q, got %q", format, expected, out.String())
+		}
+	}
+
+	if err := printOutput(&bytes.Buffer{}, "xml", v1.Message{}); err == nil {
+		t.Error("expected an error for an unknown output format")
+	}
+}
@@ -1,77 +1,232 @@
 package main
 
 import(
+	"context"
 	"flag"
+	"fmt"
 	"github.com/redhat-gpe/agnostics/internal/api"
 	"github.com/redhat-gpe/agnostics/internal/console"
 	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/events"
 	"github.com/redhat-gpe/agnostics/internal/git"
 	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/ratelimit"
+	"github.com/redhat-gpe/agnostics/internal/server"
+	"github.com/redhat-gpe/agnostics/internal/source"
 	"github.com/redhat-gpe/agnostics/internal/watcher"
 	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/tg123/go-htpasswd"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
-// Flags
-var debugFlag bool
-var repositoryURL string
-var sshPrivateKey string
-var redisURL string
-var templateDir string
-var apiAddress string
-var consoleAddress string
-var apiAuth bool
-var apiHtpasswd string
+// newConfigSource returns the source of the config of the 'config-source' setting.
+// The git repository is cloned.
+func newConfigSource() source.Source {
+	switch configSource {
+	case source.KindDir:
+		log.Out.Println("Reading the config from the directory", configDir)
+		return source.NewDir(configDir)
+	case source.KindHTTP:
+		log.Out.Println("Reading the config from the bundle", configURL)
+		return source.NewHTTP(configURL, configURLPollInterval)
+	}
+	password := gitPassword
+	if gitPasswordFile != "" {
+		content, err := ioutil.ReadFile(gitPasswordFile)
+		if err != nil {
+			log.Err.Fatal(err)
+		}
+		password = strings.TrimSpace(string(content))
+	}
+	git.CloneRepository(git.CloneOptions{
+		URL: repositoryURL,
+		Branch: gitBranch,
+		Tag: gitTag,
+		CacheDir: gitCacheDir,
+		SSHPrivateKey: sshPrivateKey,
+		SSHKeyPassphrase: sshKeyPassphrase,
+		SSHKnownHosts: sshKnownHosts,
+		Username: gitUsername,
+		Password: password,
+	})
+	return watcher.NewGitSource()
+}
 
-func parseFlags() {
-	flag.StringVar(&repositoryURL, "git-url", "git@github.com:redhat-gpe/scheduler-config.git", "The URL of the git repository where the scheduler will find its configuration. SSH is assumed, unless the URL starts with 'http'.\nEnvironment variable: GIT_URL\n")
-	flag.StringVar(&sshPrivateKey, "git-ssh-private-key", "", "The path of the SSH private key used to authenticate to the git repository. Used only when 'git-url' is an SSH URL.\nEnvironment variable: GIT_SSH_PRIVATE_KEY\n")
-	flag.StringVar(&redisURL, "redis-url", "redis://localhost:6379", "The URL to access redis. The format is described by the IANA specification for the scheme, see https://www.iana.org/assignments/uri-schemes/prov/redis\nEnvironment variable: REDIS_URL\n")
-	flag.BoolVar(&debugFlag, "debug", false, "Debug mode.\nEnvironment variable: DEBUG\n")
-	flag.StringVar(&templateDir, "template-dir", "templates", "The directory containing the golang templates for the Console.\nEnvironment variable: TEMPLATE_DIR\n")
-	flag.StringVar(&apiAddress, "api-addr", ":8080", "The address API listens to.\nEnvironment variable: API_ADDR\n")
-	flag.StringVar(&consoleAddress, "console-addr", ":8081", "The address the Console listens to.\nEnvironment variable: CONSOLE_ADDR\n")
-	flag.BoolVar(&apiAuth, "api-auth", true, "Enable authentication for the API.\nEnvironment variable: API_AUTH  ('true' or 'false')\n")
-	flag.StringVar(&apiHtpasswd, "api-htpasswd", "api-htpasswd", "The path of the htpasswd file to use for authentication for the API.\nEnvironment variable: API_HTPASSWD\n")
+// parseSettings reads the settings from the flags, the environment variables
+// and the config file, and checks them. It exits if a setting is invalid.
+// It returns the path of the config file, if any.
+func parseSettings() string {
+	s := newSettings()
+	if err := s.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	errs := validateSettings(s)
 
-	flag.Parse()
-	if e := os.Getenv("GIT_URL"); e != "" {
-		repositoryURL = e
+	if s.PrintConfig {
+		s.Print(os.Stdout)
+		if len(errs) > 0 {
+			fmt.Fprintln(os.Stderr, "Invalid config:\n  "+strings.Join(errs, "\n  "))
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
-	if e := os.Getenv("GIT_SSH_PRIVATE_KEY"); e != "" {
-		sshPrivateKey = e
+	if len(errs) > 0 {
+		fmt.Fprintln(os.Stderr, "Invalid config:\n  "+strings.Join(errs, "\n  "))
+		os.Exit(2)
 	}
-	if e := os.Getenv("REDIS_URL"); e != "" {
-		redisURL = e
+	return s.ConfigPath
+}
+
+// shutdown stops the servers once their requests in progress are done, then the consumers.
+// It gives up after shutdownTimeout.
+func shutdown(servers []*http.Server, stopConsumers context.CancelFunc, consumers *sync.WaitGroup) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// The event streams never end on their own
+	events.Close()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Err.Println("Shutdown", srv.Addr, err)
+			}
+		}(srv)
 	}
-	if e := os.Getenv("API_ADDR"); e != "" {
-		apiAddress = e
+	wg.Wait()
+	log.Out.Println("Servers stopped")
+
+	stopConsumers()
+	done := make(chan struct{})
+	go func() {
+		consumers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Out.Println("Consumers stopped")
+	case <-ctx.Done():
+		log.Err.Println("Shutdown timeout, the consumers are still running")
 	}
-	if e := os.Getenv("CONSOLE_ADDR"); e != "" {
-		consoleAddress = e
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
 	}
-	if e := os.Getenv("TEMPLATE_DIR"); e != "" {
-		templateDir = e
+	configPath := parseSettings()
+	log.InitLoggers(debugFlag)
+	if configPath != "" {
+		log.Out.Println("Config file loaded:", configPath)
 	}
-	if e := os.Getenv("API_AUTH"); e == "false" {
-		apiAuth = false
+	validationMode, err := api.ParseValidationMode(apiValidation)
+	if err != nil {
+		log.Err.Fatal(err)
 	}
-	if e := os.Getenv("API_HTPASSWD"); e != "" {
-		apiHtpasswd = e
+	rateLimitRoutes, err := ratelimit.ParseRoutes(apiRateLimitRoutes)
+	if err != nil {
+		log.Err.Fatal(err)
 	}
-	if e := os.Getenv("DEBUG"); e != "" && e != "false" {
-		debugFlag = true
+	limiter := ratelimit.NewLimiter(ratelimit.Rules{
+		Default: apiRateLimit,
+		Routes: rateLimitRoutes,
+		MaxConcurrent: apiMaxConcurrent,
+	})
+	tlsConfig, err := server.NewTLSConfig(server.TLSOptions{
+		CertFile: tlsCert,
+		KeyFile: tlsKey,
+		ClientCAFile: tlsClientCA,
+		ClientCertRequired: tlsClientCertRequired,
+	})
+	if err != nil {
+		log.Err.Fatal("TLS: ", err)
 	}
-}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-func main() {
-	parseFlags()
-	log.InitLoggers(debugFlag)
 	db.InitContext(redisURL)
-	git.CloneRepository(repositoryURL, sshPrivateKey)
-	go watcher.ConsumePullQueue()
-	go watcher.ConsumeTaintSyncQueue()
-	config.Load()
-	go console.Serve(templateDir, consoleAddress)
-	api.Serve(apiAddress, apiAuth, apiHtpasswd)
+	db.SetConnectTimeout(redisConnectTimeout)
+	src := newConfigSource()
+	// The first version of the config. For git, another replica may have pinned it to a commit.
+	if err := src.Update(""); err != nil {
+		log.Debug.Println("Config source update:", err)
+	}
+	config.SetSource(src)
+
+	consumersCtx, stopConsumers := context.WithCancel(context.Background())
+	var consumers sync.WaitGroup
+	for _, consume := range []func(context.Context){
+		func(ctx context.Context) { watcher.ConsumeSource(ctx, src) },
+		watcher.ConsumeTaintSyncQueue,
+		watcher.ConsumeEventQueue,
+		watcher.ConsumeWebhookQueue,
+		func(ctx context.Context) {
+			if src.Kind() == source.KindGit {
+				watcher.PollRepository(ctx, gitPollInterval)
+			}
+		},
+	} {
+		consumers.Add(1)
+		go func(consume func(context.Context)) {
+			defer consumers.Done()
+			consume(consumersCtx)
+		}(consume)
+	}
+	if err := config.Load(); err != nil {
+		// Don't crash-loop on a bad commit: the scheduler is not ready until a valid config is pulled.
+		log.Err.Println("No valid config loaded, the scheduler is not ready")
+	}
+
+	api.SetGitWebhookSecret(gitWebhookSecret)
+	apiServer, err := api.NewServer(apiAddress, apiAuth, apiHtpasswd, validationMode, limiter, tlsConfig, httpTimeouts)
+	if err != nil {
+		log.Err.Fatal(err)
+	}
+	// The taint forms of the console authenticate like the API, they are disabled without authentication
+	var consoleAuth *htpasswd.File
+	if apiAuth || tlsClientCA != "" {
+		consoleAuth, err = api.LoadHtpasswd(apiAuth, apiHtpasswd)
+		if err != nil {
+			log.Err.Fatal(err)
+		}
+	}
+	servers := []*http.Server{
+		console.NewServer(templateDir, consoleAddress, tlsConfig, httpTimeouts, consoleAuth),
+		apiServer,
+	}
+	serverErrors := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			serverErrors <- server.Run(srv)
+		}(srv)
+	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		// A second signal stops the scheduler immediately
+		stop()
+		log.Out.Println("Stopping, the health checks fail for", shutdownDelay)
+		server.Drain()
+		time.Sleep(shutdownDelay)
+	case err := <-serverErrors:
+		log.Err.Println(err)
+		exitCode = 1
+	}
+	shutdown(servers, stopConsumers, &consumers)
+	os.Exit(exitCode)
 }
@@ -0,0 +1,210 @@
+package main
+
+import(
+	"fmt"
+	"github.com/redhat-gpe/agnostics/internal/api"
+	"github.com/redhat-gpe/agnostics/internal/ratelimit"
+	"github.com/redhat-gpe/agnostics/internal/server"
+	"github.com/redhat-gpe/agnostics/internal/settings"
+	"github.com/redhat-gpe/agnostics/internal/source"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Settings
+var debugFlag bool
+var configSource string
+var configDir string
+var configURL string
+var configURLPollInterval time.Duration
+var repositoryURL string
+var sshPrivateKey string
+var sshKeyPassphrase string
+var sshKnownHosts string
+var gitUsername string
+var gitPassword string
+var gitPasswordFile string
+var gitBranch string
+var gitCacheDir string
+var gitTag string
+var gitWebhookSecret string
+var gitPollInterval time.Duration
+var redisURL string
+var redisConnectTimeout time.Duration
+var templateDir string
+var apiAddress string
+var consoleAddress string
+var apiAuth bool
+var apiHtpasswd string
+var apiValidation string
+var apiRateLimit int
+var apiRateLimitRoutes string
+var apiMaxConcurrent int
+var tlsCert string
+var tlsKey string
+var tlsClientCA string
+var tlsClientCertRequired bool
+var httpTimeouts server.Timeouts
+var shutdownDelay time.Duration
+var shutdownTimeout time.Duration
+
+// newSettings returns all the settings of the scheduler.
+// Each setting is a flag, an environment variable and a key of the config file.
+func newSettings() *settings.Set {
+	s := settings.New("scheduler")
+	s.String(&configSource, "config-source", "CONFIG_SOURCE", source.KindGit, "Where the config is read from: 'git' (the repository of 'git-url'), 'dir' (the local directory of 'config-dir') or 'http' (the tarball of 'config-url').")
+	s.String(&configDir, "config-dir", "CONFIG_DIR", "", "The local directory containing the config, when 'config-source' is 'dir', like a working copy of the config repository or a ConfigMap volume. The config is reloaded when the files change.")
+	s.String(&configURL, "config-url", "CONFIG_URL", "", "The URL of the tarball containing the config, optionally gzipped, when 'config-source' is 'http'. The files can be at the root of the tarball or in a single directory.")
+	s.Duration(&configURLPollInterval, "config-url-poll-interval", "CONFIG_URL_POLL_INTERVAL", 5*time.Minute, "The interval between the checks of the tarball of 'config-url' for changes. 0 disables the checks.")
+	s.String(&repositoryURL, "git-url", "GIT_URL", "git@github.com:redhat-gpe/scheduler-config.git", "The URL of the git repository where the scheduler will find its configuration. SSH is assumed, unless the URL starts with 'http'.")
+	s.String(&sshPrivateKey, "git-ssh-private-key", "GIT_SSH_PRIVATE_KEY", "", "The path of the SSH private key used to authenticate to the git repository. Used only when 'git-url' is an SSH URL.")
+	s.String(&sshKeyPassphrase, "git-ssh-key-passphrase", "GIT_SSH_KEY_PASSPHRASE", "", "The passphrase of 'git-ssh-private-key', if it's encrypted.").Secret()
+	s.String(&sshKnownHosts, "git-ssh-known-hosts", "GIT_SSH_KNOWN_HOSTS", "", "The path of the known_hosts file verifying the host key of the git server. The connection fails if the host is unknown or its key doesn't match. If empty, the files of the SSH_KNOWN_HOSTS environment variable are used, or ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts.")
+	s.String(&gitUsername, "git-username", "GIT_USERNAME", "oauth2", "The username to authenticate to the git repository when 'git-url' is an HTTPS URL. Any username works with a GitHub token, GitLab expects 'oauth2'.")
+	s.String(&gitPassword, "git-password", "GIT_PASSWORD", "", "The password or the token to authenticate to the git repository when 'git-url' is an HTTPS URL. The repository is cloned anonymously if neither 'git-password' nor 'git-password-file' is set.").Secret()
+	s.String(&gitPasswordFile, "git-password-file", "GIT_PASSWORD_FILE", "", "The path of the file containing the password or the token of 'git-password', like a mounted Kubernetes Secret.")
+	s.String(&gitBranch, "git-branch", "GIT_BRANCH", "", "The branch of the git repository to track. The default branch of the repository is used if neither 'git-branch' nor 'git-tag' is set.")
+	s.String(&gitCacheDir, "git-cache-dir", "GIT_CACHE_DIR", "", "The directory of the clone of the git repository, kept between the restarts, like a persistent volume. The clone is reused and fetched at startup, and the scheduler starts from the cached commit if the repository is unreachable. A temporary directory is used if empty.")
+	s.String(&gitTag, "git-tag", "GIT_TAG", "", "The tag of the git repository to track, instead of a branch. The config is reloaded when the tag is moved.")
+	s.String(&gitWebhookSecret, "git-webhook-secret", "GIT_WEBHOOK_SECRET", "", "The secret of the push webhook of the config repository, POST /api/v1/hooks/git: the HMAC secret for GitHub, the secret token for GitLab. The webhook is disabled if empty.").Secret()
+	s.Duration(&gitPollInterval, "git-poll-interval", "GIT_POLL_INTERVAL", 5*time.Minute, "The interval between the checks of the git repository for new commits. Only one replica checks, and all the replicas reload the config. 0 disables the checks.")
+	s.String(&redisURL, "redis-url", "REDIS_URL", "redis://localhost:6379", "The URL to access redis. The format is described by the IANA specification for the scheme, see https://www.iana.org/assignments/uri-schemes/prov/redis").SecretURL()
+	s.Duration(&redisConnectTimeout, "redis-connect-timeout", "REDIS_CONNECT_TIMEOUT", 10*time.Second, "The maximum time to establish a connection to redis. 0 means no timeout.")
+	s.Bool(&debugFlag, "debug", "DEBUG", false, "Debug mode.")
+	s.String(&templateDir, "template-dir", "TEMPLATE_DIR", "templates", "The directory containing the golang templates for the Console.")
+	s.String(&apiAddress, "api-addr", "API_ADDR", ":8080", "The address API listens to.")
+	s.String(&consoleAddress, "console-addr", "CONSOLE_ADDR", ":8081", "The address the Console listens to.")
+	s.Bool(&apiAuth, "api-auth", "API_AUTH", true, "Enable authentication for the API.")
+	s.String(&apiHtpasswd, "api-htpasswd", "API_HTPASSWD", "api-htpasswd", "The path of the htpasswd file to use for authentication for the API.")
+	s.String(&apiValidation, "api-validation", "API_VALIDATION", "log", "Validation of the API requests and responses against the OpenAPI documents: 'enforce' (development), 'log' or 'off'.")
+	s.Int(&apiRateLimit, "api-rate-limit", "API_RATE_LIMIT", 0, "The number of requests per minute a user can send to each route of the API. The IP address is used instead of the user when authentication is disabled. 0 means no limit.")
+	s.String(&apiRateLimitRoutes, "api-rate-limit-routes", "API_RATE_LIMIT_ROUTES", "", "The number of requests per minute for some routes, overriding 'api-rate-limit'. Format: 'METHOD /path=limit,...', for example 'POST /api/v1/schedule=30,POST /api/v2/placements=30'.")
+	s.Int(&apiMaxConcurrent, "api-max-concurrent", "API_MAX_CONCURRENT", 0, "The number of requests a user can have in progress at the same time. 0 means no limit.")
+	s.String(&tlsCert, "tls-cert", "TLS_CERT", "", "The path of the PEM certificate used to serve the API and the Console with HTTPS. The file is reloaded when it changes.")
+	s.String(&tlsKey, "tls-key", "TLS_KEY", "", "The path of the PEM private key of 'tls-cert'.")
+	s.String(&tlsClientCA, "tls-client-ca", "TLS_CLIENT_CA", "", "The path of the PEM CA bundle used to verify the client certificates. The common name of a verified client certificate is the user, no other authentication is needed.")
+	s.Bool(&tlsClientCertRequired, "tls-client-cert-required", "TLS_CLIENT_CERT_REQUIRED", false, "Reject the connections without a valid client certificate.")
+	s.Duration(&httpTimeouts.ReadHeader, "http-read-header-timeout", "HTTP_READ_HEADER_TIMEOUT", server.DefaultTimeouts.ReadHeader, "The maximum duration for reading the headers of a request of the API or the Console.")
+	s.Duration(&httpTimeouts.Read, "http-read-timeout", "HTTP_READ_TIMEOUT", server.DefaultTimeouts.Read, "The maximum duration for reading a request of the API or the Console, including the body.")
+	s.Duration(&httpTimeouts.Write, "http-write-timeout", "HTTP_WRITE_TIMEOUT", server.DefaultTimeouts.Write, "The maximum duration of a response of the API or the Console. The event streams are not limited.")
+	s.Duration(&httpTimeouts.Idle, "http-idle-timeout", "HTTP_IDLE_TIMEOUT", server.DefaultTimeouts.Idle, "The maximum time to wait for the next request on a keep-alive connection.")
+	s.Duration(&shutdownDelay, "shutdown-delay", "SHUTDOWN_DELAY", 5*time.Second, "On SIGTERM, the time during which the health checks fail before the servers stop accepting requests, so the load balancers stop sending new requests.")
+	s.Duration(&shutdownTimeout, "shutdown-timeout", "SHUTDOWN_TIMEOUT", 30*time.Second, "On SIGTERM, the maximum time to wait for the requests in progress and the config reloads to finish.")
+	return s
+}
+
+// validateSettings checks the values that are valid for their type but not for the scheduler.
+// It returns all the errors found.
+func validateSettings(s *settings.Set) []string {
+	errs := []string{}
+	invalid := func(name string, format string, a ...interface{}) {
+		errs = append(errs, fmt.Sprintf("%s: ", name)+fmt.Sprintf(format, a...))
+	}
+	fileExists := func(name string, path string) {
+		if _, err := os.Stat(path); err != nil {
+			invalid(name, "%v", err)
+		}
+	}
+
+	switch configSource {
+	case source.KindGit:
+		if repositoryURL == "" {
+			invalid("git-url", "must not be empty")
+		}
+	case source.KindDir:
+		if info, err := os.Stat(configDir); err != nil || ! info.IsDir() {
+			invalid("config-dir", "must be a directory when config-source is 'dir'")
+		}
+	case source.KindHTTP:
+		if u, err := url.Parse(configURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			invalid("config-url", "must be an http:// or https:// URL when config-source is 'http'")
+		}
+	default:
+		invalid("config-source", "must be 'git', 'dir' or 'http'")
+	}
+	if sshPrivateKey != "" && ! strings.HasPrefix(repositoryURL, "http") {
+		fileExists("git-ssh-private-key", sshPrivateKey)
+	}
+	if sshKnownHosts != "" && ! strings.HasPrefix(repositoryURL, "http") {
+		fileExists("git-ssh-known-hosts", sshKnownHosts)
+	}
+	if gitPasswordFile != "" {
+		if gitPassword != "" {
+			invalid("git-password-file", "must not be set with git-password")
+		}
+		fileExists("git-password-file", gitPasswordFile)
+	}
+	if gitBranch != "" && gitTag != "" {
+		invalid("git-tag", "must not be set with git-branch")
+	}
+	if u, err := url.Parse(redisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+		invalid("redis-url", "must be a redis:// or rediss:// URL")
+	}
+	if info, err := os.Stat(templateDir); err != nil || ! info.IsDir() {
+		invalid("template-dir", "must be a directory")
+	}
+	if apiAddress == "" {
+		invalid("api-addr", "must not be empty")
+	}
+	if consoleAddress == "" {
+		invalid("console-addr", "must not be empty")
+	}
+	if apiAddress == consoleAddress {
+		invalid("console-addr", "must be different from api-addr")
+	}
+	if apiAuth {
+		fileExists("api-htpasswd", apiHtpasswd)
+	}
+	if _, err := api.ParseValidationMode(apiValidation); err != nil {
+		invalid("api-validation", "%v", err)
+	}
+	if _, err := ratelimit.ParseRoutes(apiRateLimitRoutes); err != nil {
+		invalid("api-rate-limit-routes", "%v", err)
+	}
+	if (tlsCert == "") != (tlsKey == "") {
+		invalid("tls-cert", "tls-cert and tls-key must be set together")
+	}
+	if tlsClientCA != "" && tlsCert == "" {
+		invalid("tls-client-ca", "requires tls-cert and tls-key")
+	}
+	if tlsClientCertRequired && tlsClientCA == "" {
+		invalid("tls-client-cert-required", "requires tls-client-ca")
+	}
+	for _, name := range []string{"tls-cert", "tls-key", "tls-client-ca"} {
+		if v := s.Lookup(name).Value(); v != "" {
+			fileExists(name, v)
+		}
+	}
+
+	for name, v := range map[string]int{"api-rate-limit": apiRateLimit, "api-max-concurrent": apiMaxConcurrent} {
+		if v < 0 {
+			invalid(name, "must not be negative")
+		}
+	}
+	for name, d := range map[string]time.Duration{
+		"redis-connect-timeout": redisConnectTimeout,
+		"http-read-header-timeout": httpTimeouts.ReadHeader,
+		"http-read-timeout": httpTimeouts.Read,
+		"http-write-timeout": httpTimeouts.Write,
+		"http-idle-timeout": httpTimeouts.Idle,
+		"shutdown-delay": shutdownDelay,
+	} {
+		if d < 0 {
+			invalid(name, "must not be negative")
+		}
+	}
+	if configURLPollInterval != 0 && configURLPollInterval < 10*time.Second {
+		invalid("config-url-poll-interval", "must be 0 (disabled) or at least 10s")
+	}
+	if gitPollInterval != 0 && gitPollInterval < 10*time.Second {
+		invalid("git-poll-interval", "must be 0 (disabled) or at least 10s")
+	}
+	if shutdownTimeout <= 0 {
+		invalid("shutdown-timeout", "must be more than 0")
+	}
+	sort.Strings(errs)
+	return errs
+}
@@ -0,0 +1,48 @@
+package main
+
+import(
+	"flag"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"os"
+)
+
+// validate is the 'validate' command: it checks the config in a directory, without redis,
+// for example in the CI of the config repository.
+// It returns the exit code: 0 if the config is valid, 1 if it's invalid, 2 for a usage error.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("scheduler validate", flag.ContinueOnError)
+	debug := flags.Bool("debug", false, "Debug mode.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: scheduler validate [-debug] <dir>\n\nCheck the config in dir, a checkout of the config repository, without redis.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	dir := flags.Arg(0)
+	if info, err := os.Stat(dir); err != nil || ! info.IsDir() {
+		fmt.Fprintln(os.Stderr, dir, "is not a directory")
+		return 2
+	}
+
+	log.InitLoggers(*debug)
+	problems := config.Validate(dir)
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Invalid config in %s:\n", dir)
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, "  "+p)
+		}
+		return 1
+	}
+	fmt.Println("Valid config in", dir)
+	return 0
+}
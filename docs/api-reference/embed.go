@@ -0,0 +1,17 @@
+// Package apireference embeds the OpenAPI documents of the API and Swagger UI,
+// so the scheduler can serve them without network access.
+package apireference
+
+import "embed"
+
+// FS contains the OpenAPI documents, index.html and the Swagger UI files.
+//go:embed swagger.yaml swagger-v2.yaml index.html
+//go:embed dist/swagger-ui-bundle.js dist/swagger-ui-standalone-preset.js dist/swagger-ui.css
+//go:embed dist/favicon-16x16.png dist/favicon-32x32.png
+var FS embed.FS
+
+// Specs maps the version of the API to its OpenAPI document in FS.
+var Specs = map[string]string{
+	"v1": "swagger.yaml",
+	"v2": "swagger-v2.yaml",
+}
@@ -1,12 +1,18 @@
 module github.com/redhat-gpe/agnostics
 
-go 1.14
+go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/getkin/kin-openapi v0.94.0
 	github.com/go-git/go-git/v5 v5.1.0
 	github.com/gomodule/redigo v1.8.2
+	github.com/google/go-cmp v0.5.1 // indirect
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/tg123/go-htpasswd v1.0.0
-	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	golang.org/x/sys v0.0.0-20200803210538-64077c9b5642 // indirect
+	golang.org/x/text v0.3.3 // indirect
 	gopkg.in/yaml.v2 v2.3.0
 )
@@ -1,10 +1,17 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"github.com/julienschmidt/httprouter"
 	"github.com/redhat-gpe/agnostics/internal/db"
 	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/metrics"
+	"github.com/redhat-gpe/agnostics/internal/ratelimit"
+	"github.com/redhat-gpe/agnostics/internal/server"
+	"github.com/redhat-gpe/agnostics/pkg/api/v2"
 	"io"
 	"strings"
 	"encoding/base64"
@@ -14,6 +21,12 @@ import (
 )
 
 func healthHandler (w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain")
+	if server.Draining() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "ERROR: the scheduler is stopping\n")
+		return
+	}
 	conn, err := db.Dial()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -25,82 +38,257 @@ func healthHandler (w http.ResponseWriter, req *http.Request, _ httprouter.Param
 	io.WriteString(w, "OK\n")
 }
 
+// basicAuthUser returns the user if the request has valid Basic Authentication credentials.
+func basicAuthUser(r *http.Request, myauth *htpasswd.File) (string, bool) {
+	const basicAuthPrefix string = "Basic "
+
+	// Get the Basic Authentication credentials
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, basicAuthPrefix) {
+		// Check credentials
+		payload, err := base64.StdEncoding.DecodeString(auth[len(basicAuthPrefix):])
+		if err == nil {
+			pair := bytes.SplitN(payload, []byte(":"), 2)
+			if len(pair) == 2 && myauth.Match(string(pair[0]), string(pair[1])) {
+				return string(pair[0]), true
+			}
+		}
+	}
+	return "", false
+}
+
+type contextKey string
+
+// userContextKey is the key of the authenticated user in the context of the request.
+const userContextKey contextKey = "user"
+
+// UserFromContext returns the authenticated user of the request, if any.
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userContextKey).(string)
+	return user, ok
+}
+
+// authenticate returns the request with the authenticated user in its context.
+// The user is the common name of the verified client certificate, or the user
+// of the Basic Authentication credentials.
+// It returns false if authentication is enabled and the request has no valid credentials.
+func authenticate(r *http.Request, myauth *htpasswd.File, authEnabled bool) (*http.Request, bool) {
+	user, ok := server.CertificateUser(r)
+	if ! ok && authEnabled {
+		user, ok = basicAuthUser(r, myauth)
+	}
+	if ok {
+		return r.WithContext(context.WithValue(r.Context(), userContextKey, user)), true
+	}
+	return r, ! authEnabled
+}
+
 func BasicAuth(h httprouter.Handle, myauth *htpasswd.File, authEnabled bool) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-
-		if ! authEnabled {
+		if r, ok := authenticate(r, myauth, authEnabled); ok {
 			// Delegate request to the given handle
 			h(w, r, ps)
 			return
 		}
 
-		const basicAuthPrefix string = "Basic "
-
-		// Get the Basic Authentication credentials
-		auth := r.Header.Get("Authorization")
-		if strings.HasPrefix(auth, basicAuthPrefix) {
-			// Check credentials
-			payload, err := base64.StdEncoding.DecodeString(auth[len(basicAuthPrefix):])
-			if err == nil {
-				pair := bytes.SplitN(payload, []byte(":"), 2)
-				if len(pair) == 2 && myauth.Match(string(pair[0]), string(pair[1])) {
-					// Delegate request to the given handle
-					h(w, r, ps)
-					return
-				}
-			}
-		}
-
 		// Request Basic Authentication otherwise
 		w.Header().Set("WWW-Authenticate", "Basic realm=Restricted")
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 	}
 }
 
-func Serve(addr string, apiAuth bool, apiHtpasswd string) {
+// v2BasicAuth is like BasicAuth but the error is a problem, see writeProblem.
+func v2BasicAuth(h httprouter.Handle, myauth *htpasswd.File, authEnabled bool) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if r, ok := authenticate(r, myauth, authEnabled); ok {
+			h(w, r, ps)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", "Basic realm=Restricted")
+		writeProblem(w, r, http.StatusUnauthorized, v2.ReasonUnauthorized, "")
+	}
+}
+
+// route is an entry of the route table of the API.
+type route struct {
+	method string
+	path string
+	handle httprouter.Handle
+}
+
+// v1Routes are the routes of the v1 API. They are all documented in docs/api-reference/swagger.yaml
+var v1Routes = []route{
+	{"GET", "/api/v1/health", healthHandler},
+	{"GET", "/api/v1/healthz", healthHandler},
+	{"GET", "/api/v1/status", v1GetStatus},
+	{"GET", "/api/v1/clouds", v1GetClouds},
+	{"GET", "/api/v1/clouds/:name", v1GetCloudByName},
+	{"POST", "/api/v1/taint/:cloudname", v1PostTaintByCloudName},
+	{"POST", "/api/v1/taint/:cloudname/delete", v1DeleteTaintByCloudName},
+	{"DELETE", "/api/v1/taint/:cloudname/:taintindex", v1DeleteTaintByIndex},
+	{"DELETE", "/api/v1/taints/:cloudname", v1DeleteTaintsByCloudName},
+	{"GET", "/api/v1/repo", v1GetRepository},
+	{"PUT", "/api/v1/repo", v1PullRepository},
+	{"GET", "/api/v1/repo/changes", v1GetRepoChanges},
+	{"PUT", "/api/v1/repo/pin", v1PutRepoPin},
+	{"DELETE", "/api/v1/repo/pin", v1DeleteRepoPin},
+	{"POST", "/api/v1/schedule", v1PostSchedule},
+	{"GET", "/api/v1/placements", v1GetPlacements},
+	{"GET", "/api/v1/placements/:uuid", v1GetPlacement},
+	{"DELETE", "/api/v1/placements/:uuid", v1DeletePlacement},
+	{"PUT", "/api/v1/counters", v1PutCounters},
+	{"GET", "/api/v1/events", v1GetEvents},
+	{"GET", "/api/v1/webhooks", v1GetWebhooks},
+	{"POST", "/api/v1/webhooks", v1PostWebhook},
+	{"DELETE", "/api/v1/webhooks/:id", v1DeleteWebhook},
+	{"GET", "/api/v1/webhooks/deadletter", v1GetWebhookDeadLetters},
+	{"POST", "/api/v1/hooks/git", v1PostGitHook},
+}
+
+// signedRoutes are authenticated by a signature of the payload instead of the credentials of a user.
+var signedRoutes = map[string]bool{
+	"POST /api/v1/hooks/git": true,
+}
+
+// v2Routes are the routes of the v2 API. They are all documented in docs/api-reference/swagger-v2.yaml
+var v2Routes = []route{
+	{"GET", "/api/v2/health", healthHandler},
+	{"GET", "/api/v2/clouds", v2GetClouds},
+	{"GET", "/api/v2/clouds/:name", v2GetCloud},
+	{"GET", "/api/v2/clouds/:name/taints", v2GetCloudTaints},
+	{"POST", "/api/v2/clouds/:name/taints", v2PostCloudTaint},
+	{"DELETE", "/api/v2/clouds/:name/taints", v2DeleteCloudTaints},
+	{"DELETE", "/api/v2/clouds/:name/taints/:key", v2DeleteCloudTaint},
+	{"GET", "/api/v2/clouds/:name/placements", v2GetCloudPlacements},
+	{"GET", "/api/v2/repo", v2GetRepository},
+	{"POST", "/api/v2/repo/pull", v2PullRepository},
+	{"GET", "/api/v2/placements", v2GetPlacements},
+	{"POST", "/api/v2/placements", v2PostPlacement},
+	{"GET", "/api/v2/placements/:uuid", v2GetPlacement},
+	{"DELETE", "/api/v2/placements/:uuid", v2DeletePlacement},
+	{"POST", "/api/v2/counters/refresh", v2RefreshCounters},
+	{"GET", "/api/v2/events", v2GetEvents},
+	{"GET", "/api/v2/webhooks", v2GetWebhooks},
+	{"POST", "/api/v2/webhooks", v2PostWebhook},
+	{"DELETE", "/api/v2/webhooks/:id", v2DeleteWebhook},
+	{"GET", "/api/v2/deadletters", v2GetDeadLetters},
+}
+
+// hasRoute returns true if route, for example 'POST /api/v1/schedule', is in the route tables.
+func hasRoute(name string) bool {
+	for _, r := range append(append([]route{}, v1Routes...), v2Routes...) {
+		if r.method+" "+r.path == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadHtpasswd loads the htpasswd file of the Basic Authentication, see BasicAuth.
+// If apiAuth is false, the file is empty: only the client certificates can authenticate.
+func LoadHtpasswd(apiAuth bool, apiHtpasswd string) (*htpasswd.File, error) {
+	if ! apiAuth {
+		apiHtpasswd = "/dev/null"
+	}
+	absAPIHtpasswdPath, err := filepath.Abs(apiHtpasswd)
+	if err != nil {
+		log.Err.Println("ERROR with api-htpasswd-path")
+		return nil, err
+	}
+	myauth, err := htpasswd.New(absAPIHtpasswdPath, htpasswd.DefaultSystems, nil)
+	if err != nil {
+		log.Err.Println("ERROR loading htpasswd", absAPIHtpasswdPath)
+		return nil, err
+	}
+	if apiAuth {
+		log.Out.Println("htpasswd found:", absAPIHtpasswdPath)
+	}
+	return myauth, nil
+}
+
+// NewRouter returns the router of the API with all the routes.
+// apiHtpasswd is the path of the htpasswd file used when apiAuth is true.
+// validation defines how the requests and responses are checked against the OpenAPI documents.
+// limiter applies the rate and concurrency limits to the authenticated routes, it can be nil.
+func NewRouter(apiAuth bool, apiHtpasswd string, validation ValidationMode, limiter *ratelimit.Limiter) (*httprouter.Router, error) {
 	router := httprouter.New()
+	router.NotFound = http.HandlerFunc(v2NotFound)
+	router.MethodNotAllowed = http.HandlerFunc(v2MethodNotAllowed)
 
 	// Health and status checks
 	router.GET("/health", healthHandler)
 	router.GET("/healthz", healthHandler)
-	router.GET("/api/v1/health", healthHandler)
-	router.GET("/api/v1/healthz", healthHandler)
+	router.GET("/livez", livezHandler)
+	router.GET("/readyz", readyzHandler)
+	router.Handler("GET", "/metrics", metrics.Handler())
+
+	// OpenAPI documents and Swagger UI, public
+	router.GET("/api/docs", func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		http.Redirect(w, req, "/api/docs/", http.StatusMovedPermanently)
+	})
+	router.GET("/api/docs/*filepath", docsHandler())
 
 	// htpasswd authentication
 	if ! apiAuth {
-		apiHtpasswd = "/dev/null"
 		log.Out.Println("API authentication disabled")
 	}
-	absAPIHtpasswdPath, err := filepath.Abs(apiHtpasswd)
+	myauth, err := LoadHtpasswd(apiAuth, apiHtpasswd)
 	if err != nil {
-		log.Err.Println("ERROR with api-htpasswd-path")
-		log.Err.Fatal(err)
+		return nil, err
 	}
-	myauth, err := htpasswd.New(absAPIHtpasswdPath, htpasswd.DefaultSystems, nil)
+
+	v, err := newValidator(validation)
 	if err != nil {
-		log.Err.Println("ERROR loading htpasswd", absAPIHtpasswdPath)
-		log.Err.Fatal(err)
-	} else {
-		if apiAuth {
-			log.Out.Println("htpasswd found:", absAPIHtpasswdPath)
+		log.Err.Println("ERROR loading the OpenAPI documents")
+		return nil, err
+	}
+
+	if limiter != nil {
+		for r := range limiter.Rules.Routes {
+			if ! hasRoute(r) {
+				return nil, fmt.Errorf("Unknown route '%s' in the rate limits", r)
+			}
+		}
+	}
+	if limiter != nil && limiter.Rules.Enabled() {
+		log.Out.Printf("API rate limits: %d requests per %s per route (overrides: %v), %d concurrent requests",
+			limiter.Rules.Default, limiter.Rules.Window, limiter.Rules.Routes, limiter.Rules.MaxConcurrent)
+	}
+
+	for _, r := range v1Routes {
+		handle := v.wrap("v1", r.handle)
+		if ! strings.HasPrefix(r.path, "/api/v1/health") {
+			handle = rateLimit(limiter, "v1", r.method+" "+r.path, handle)
+			if ! signedRoutes[r.method+" "+r.path] {
+				handle = BasicAuth(handle, myauth, apiAuth)
+			}
+		}
+		router.Handle(r.method, r.path, handle)
+	}
+	for _, r := range v2Routes {
+		handle := v.wrap("v2", r.handle)
+		if r.path != "/api/v2/health" {
+			handle = rateLimit(limiter, "v2", r.method+" "+r.path, handle)
+			handle = v2BasicAuth(handle, myauth, apiAuth)
 		}
+		router.Handle(r.method, r.path, handle)
+	}
+
+	return router, nil
+}
+
+// NewServer returns the server of the API on addr, with HTTPS if tlsConfig is not nil.
+func NewServer(addr string, apiAuth bool, apiHtpasswd string, validation ValidationMode, limiter *ratelimit.Limiter, tlsConfig *tls.Config, timeouts server.Timeouts) (*http.Server, error) {
+	router, err := NewRouter(apiAuth, apiHtpasswd, validation, limiter)
+	if err != nil {
+		return nil, err
 	}
 
-	// v1
-	router.GET("/api/v1/clouds", BasicAuth(v1GetClouds, myauth, apiAuth))
-	router.GET("/api/v1/clouds/:name", BasicAuth(v1GetCloudByName, myauth, apiAuth))
-	router.POST("/api/v1/taint/:cloudname", BasicAuth(v1PostTaintByCloudName, myauth, apiAuth))
-	router.POST("/api/v1/taint/:cloudname/delete", BasicAuth(v1DeleteTaintByCloudName, myauth, apiAuth))
-	router.DELETE("/api/v1/taint/:cloudname/:taintindex", BasicAuth(v1DeleteTaintByIndex, myauth, apiAuth))
-	router.DELETE("/api/v1/taints/:cloudname", BasicAuth(v1DeleteTaintsByCloudName, myauth, apiAuth))
-	router.GET("/api/v1/repo", BasicAuth(v1GetRepository, myauth, apiAuth))
-	router.PUT("/api/v1/repo", BasicAuth(v1PullRepository, myauth, apiAuth))
-	router.POST("/api/v1/schedule", BasicAuth(v1PostSchedule, myauth, apiAuth))
-	router.GET("/api/v1/placements", BasicAuth(v1GetPlacements, myauth, apiAuth))
-	router.GET("/api/v1/placements/:uuid", BasicAuth(v1GetPlacement, myauth, apiAuth))
-	router.DELETE("/api/v1/placements/:uuid", BasicAuth(v1DeletePlacement, myauth, apiAuth))
-	router.PUT("/api/v1/counters", BasicAuth(v1PutCounters, myauth, apiAuth))
-
-	log.Out.Println("API listen on port", addr)
-	log.Err.Fatal(http.ListenAndServe(addr, router))
+	if tlsConfig != nil {
+		log.Out.Println("API listen on port", addr, "(HTTPS)")
+	} else {
+		log.Out.Println("API listen on port", addr)
+	}
+	return server.New(addr, router, tlsConfig, timeouts), nil
 }
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"github.com/tg123/go-htpasswd"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	sum := sha1.Sum([]byte("secret"))
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := ioutil.WriteFile(path, []byte("admin:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	myauth, err := htpasswd.New(path, htpasswd.DefaultSystems, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withCert := httptest.NewRequest("GET", "/api/v1/clouds", nil)
+	withCert.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "alice"}}}},
+	}
+	withBasic := httptest.NewRequest("GET", "/api/v1/clouds", nil)
+	withBasic.SetBasicAuth("admin", "secret")
+	wrongPassword := httptest.NewRequest("GET", "/api/v1/clouds", nil)
+	wrongPassword.SetBasicAuth("admin", "wrong")
+	// A certificate that was not verified is ignored
+	unverified := httptest.NewRequest("GET", "/api/v1/clouds", nil)
+	unverified.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "mallory"}}},
+	}
+
+	testCases := []struct {
+		description string
+		authEnabled bool
+		req *http.Request
+		ok bool
+		user string
+	}{
+		{"client certificate", true, withCert, true, "alice"},
+		{"client certificate without auth", false, withCert, true, "alice"},
+		{"basic auth", true, withBasic, true, "admin"},
+		{"wrong password", true, wrongPassword, false, ""},
+		{"unverified certificate", true, unverified, false, ""},
+		{"auth disabled", false, withBasic, true, ""},
+	}
+	for _, tc := range testCases {
+		req, ok := authenticate(tc.req, myauth, tc.authEnabled)
+		user, _ := UserFromContext(req.Context())
+		if ok != tc.ok || user != tc.user {
+			t.Errorf("%s: expected %v %q, got %v %q", tc.description, tc.ok, tc.user, ok, user)
+		}
+	}
+}
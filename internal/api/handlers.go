@@ -6,15 +6,13 @@ import (
 	"github.com/redhat-gpe/agnostics/internal/config"
 	"github.com/redhat-gpe/agnostics/internal/git"
 	"github.com/redhat-gpe/agnostics/internal/log"
-	"github.com/redhat-gpe/agnostics/internal/modules"
 	"github.com/redhat-gpe/agnostics/internal/watcher"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"github.com/redhat-gpe/agnostics/internal/placement"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
-	"time"
 )
 
 func v1GetClouds(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
@@ -74,93 +72,45 @@ func v1PostSchedule(w http.ResponseWriter, req *http.Request, params httprouter.
 		return
 	}
 
-	if scheduleQuery.UUID == "" {
+	// With dry_run, the placement is computed but not saved.
+	dryRun := req.URL.Query().Get("dry_run") == "true"
+
+	result, err := schedule(*scheduleQuery, dryRun)
+	if errs, ok := err.(v1.FieldErrors); ok {
 		w.WriteHeader(http.StatusBadRequest)
 		enc.Encode(v1.Error{
 			Code: http.StatusBadRequest,
-			Message: "uuid must be provided",
+			Message: "Invalid schedule request: " + errs.Error(),
+			Errors: errs,
 		})
 		return
 	}
-
-	if len(scheduleQuery.Annotations) > 0 {
-		for k, v := range scheduleQuery.Annotations {
-			if k == "" || v == "" {
-				w.WriteHeader(http.StatusBadRequest)
-				enc.Encode(v1.Error{
-					Code: http.StatusBadRequest,
-					Message: "Annotations keys and values cannot be empty string.",
-				})
-				return
-			}
-		}
-	}
-
-	if _, err := placement.Get(scheduleQuery.UUID) ; err != placement.ErrPlacementNotFound {
-		if err == nil {
-			w.WriteHeader(http.StatusBadRequest)
-			enc.Encode(v1.Error{
-				Code: http.StatusBadRequest,
-				Message: "This service uuid already has a placement",
-			})
-			return
-
-		}
-		// Else something went wrong
-		log.Err.Println("POST schedule", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	switch err {
+	case nil:
+	case errAlreadyPlaced:
+		w.WriteHeader(http.StatusBadRequest)
 		enc.Encode(v1.Error{
-			Code: http.StatusInternalServerError,
-			Message: "Internal Server Error",
+			Code: http.StatusBadRequest,
+			Message: err.Error(),
 		})
 		return
-	}
-
-	policy := config.GetPolicy()
-	clouds := []v1.Cloud{}
-	for _, c := range config.GetClouds() {
-		clouds = append(clouds, c)
-	}
-
-	for _, predicate := range policy.Predicates {
-		switch predicate.Name {
-		case "LabelPredicates":
-			clouds = modules.LabelPredicates(clouds, scheduleQuery.CloudSelector)
-		case "TaintPredicates":
-			clouds = modules.TaintPredicates(clouds, scheduleQuery.Tolerations)
-		default:
-			log.Err.Println("Predicate", predicate.Name, "not supported")
-		}
-	}
-
-	for _, priority := range policy.Priorities {
-		switch priority.Name {
-		case "LabelPriorities":
-			clouds = modules.LabelPriorities(clouds, scheduleQuery.CloudPreference, priority.Weight)
-		case "TaintPriorities":
-			clouds = modules.TaintPriorities(clouds, scheduleQuery.Tolerations, priority.Weight)
-		default:
-			log.Err.Println("Priority", priority.Name, "not supported")
-		}
-	}
-
-	if len(clouds) == 0 {
+	case errNoCloudFound:
 		log.Out.Println("POST schedule", err)
 		w.WriteHeader(http.StatusNotFound)
 		enc.Encode(v1.Error{
 			Code: http.StatusNotFound,
-			Message: "No cloud found.",
+			Message: err.Error(),
+		})
+		return
+	default:
+		log.Err.Println("POST schedule", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "Internal Server Error",
 		})
 		return
 	}
-	// pick the first one
-	result := v1.Placement{
-		UUID: scheduleQuery.UUID,
-		Cloud: clouds[0],
-		CreationTimestamp: time.Now().UTC().Round(time.Second),
-		Annotations: scheduleQuery.Annotations,
-	}
-	placement.Save(result)
 	if err := enc.Encode(result) ; err != nil {
 		log.Err.Println("POST schedule", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -308,7 +258,7 @@ func v1GetRepository(w http.ResponseWriter, req *http.Request, _ httprouter.Para
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", " ")
-	if gitCommit, err := v1.NewGitCommit(git.GetRepo()); err == nil {
+	if gitCommit, err := git.NewGitCommit(git.GetRepo()); err == nil {
 		enc.Encode(gitCommit)
 	} else {
 		w.WriteHeader(http.StatusInternalServerError)
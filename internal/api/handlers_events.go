@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/events"
+	"net/http"
+	"strings"
+)
+
+// parseEventsQuery returns the event ID to resume after and the event types requested.
+// The ID is read from the 'Last-Event-ID' header or the 'since' query parameter,
+// the types from the 'type' query parameter, repeated or comma-separated.
+func parseEventsQuery(req *http.Request) (string, []string, error) {
+	since := req.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = req.URL.Query().Get("since")
+	}
+
+	if since != "" {
+		if err := events.ValidateID(since); err != nil {
+			return "", nil, errors.New("Invalid event ID '" + since + "'.")
+		}
+	}
+
+	types := []string{}
+	for _, param := range req.URL.Query()["type"] {
+		for _, t := range strings.Split(param, ",") {
+			if ! v1.IsEventType(t) {
+				return "", nil, errors.New("Unknown event type '" + t + "'. Must be one of: " + strings.Join(v1.EventTypes, ", "))
+			}
+			types = append(types, t)
+		}
+	}
+	return since, types, nil
+}
+
+// v1GetEvents streams the events using Server-Sent Events.
+// The stream can be resumed after an event using the 'Last-Event-ID' header
+// or the 'since' query parameter, and filtered using the 'type' query parameter.
+func v1GetEvents(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	since, types, err := parseEventsQuery(req)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	events.ServeSSE(w, req, since, types)
+}
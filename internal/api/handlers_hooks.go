@@ -0,0 +1,134 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Headers of the push webhooks of GitHub and GitLab
+const (
+	githubEventHeader = "X-GitHub-Event"
+	githubSignatureHeader = "X-Hub-Signature-256"
+	gitlabEventHeader = "X-Gitlab-Event"
+	gitlabTokenHeader = "X-Gitlab-Token"
+)
+
+// maxGitHookPayload is the maximum size of a push payload, the limit of GitHub.
+const maxGitHookPayload = 25 << 20
+
+// gitWebhookSecret is the secret shared with GitHub or GitLab, see SetGitWebhookSecret.
+var gitWebhookSecret string
+
+// SetGitWebhookSecret enables POST /api/v1/hooks/git. The requests must be signed with
+// the secret (GitHub) or have it as token (GitLab). An empty secret disables the route.
+func SetGitWebhookSecret(secret string) {
+	gitWebhookSecret = secret
+}
+
+// Replaced in the tests
+var (
+	requestPullCommit = watcher.RequestPullCommit
+	trackedRef = git.TrackedRef
+)
+
+// gitPush is the part of the push payload of GitHub and GitLab used by the scheduler.
+type gitPush struct {
+	// Ref is the pushed reference, for example 'refs/heads/main'.
+	Ref string `json:"ref"`
+	// After is the commit the reference points to after the push.
+	After string `json:"after"`
+}
+
+// verifyGitHook checks that the request comes from GitHub or GitLab with the secret.
+// It returns the event, for example 'push' or 'Push Hook', and false if the request is not authentic.
+func verifyGitHook(req *http.Request, payload []byte, secret string) (string, bool) {
+	if event := req.Header.Get(githubEventHeader); event != "" {
+		signature := req.Header.Get(githubSignatureHeader)
+		if ! strings.HasPrefix(signature, "sha256=") {
+			return event, false
+		}
+		expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return event, false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		return event, hmac.Equal(mac.Sum(nil), expected)
+	}
+	if event := req.Header.Get(gitlabEventHeader); event != "" {
+		token := req.Header.Get(gitlabTokenHeader)
+		return event, hmac.Equal([]byte(token), []byte(secret))
+	}
+	return "", false
+}
+
+// v1PostGitHook receives the push webhooks of GitHub and GitLab and requests a pull of the
+// pushed commit when the tracked branch or tag is pushed. The other events and refs are ignored.
+func v1PostGitHook(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	reply := func(code int, message string) {
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			enc.Encode(v1.Error{Code: int32(code), Message: message})
+			return
+		}
+		enc.Encode(v1.Message{Message: message})
+	}
+
+	if gitWebhookSecret == "" {
+		reply(http.StatusNotFound, "The git webhook is disabled, see the 'git-webhook-secret' setting.")
+		return
+	}
+	payload, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxGitHookPayload))
+	if err != nil {
+		reply(http.StatusBadRequest, "Cannot read the payload.")
+		return
+	}
+	event, ok := verifyGitHook(req, payload, gitWebhookSecret)
+	if ! ok {
+		log.Err.Println("git webhook: invalid signature or token from", req.RemoteAddr)
+		reply(http.StatusUnauthorized, "Invalid signature or token.")
+		return
+	}
+
+	switch event {
+	case "push", "Push Hook", "Tag Push Hook":
+	case "ping":
+		reply(http.StatusOK, "pong")
+		return
+	default:
+		reply(http.StatusOK, "Event '"+event+"' ignored.")
+		return
+	}
+
+	push := gitPush{}
+	if err := json.Unmarshal(payload, &push); err != nil || push.Ref == "" {
+		reply(http.StatusBadRequest, "Invalid push payload.")
+		return
+	}
+	ref := trackedRef()
+	if ref == "" {
+		reply(http.StatusServiceUnavailable, "The config repository is not cloned yet.")
+		return
+	}
+	if push.Ref != ref {
+		reply(http.StatusOK, "Push to "+push.Ref+" ignored, the scheduler tracks "+ref+".")
+		return
+	}
+
+	log.Out.Println("git webhook: push to", push.Ref, "at", push.After, "requesting a pull")
+	go requestPullCommit(push.After)
+	reply(http.StatusOK, "Request to update git repository received.")
+}
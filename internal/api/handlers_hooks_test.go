@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Recorded deliveries, signed with the secret of the GitHub documentation
+const (
+	testGitHookSecret = "It's a Secret to Everybody"
+	githubPushSignature = "sha256=ebcbeb01e295dcf3ceb9f3f26cec102c2e4abb59fd70c80c89bf251857f1e25b"
+	githubPingSignature = "sha256=c259d4ca8144482bf4d3635f0c75412c666aa929fe98855a642fc6c7d2b209d3"
+)
+
+func TestGitHook(t *testing.T) {
+	setupConfig(t)
+	pulls := make(chan string, 10)
+	requestPullCommit = func(hash string) { pulls <- hash }
+	trackedRef = func() string { return "refs/heads/main" }
+	t.Cleanup(func() {
+		SetGitWebhookSecret("")
+		requestPullCommit = watcher.RequestPullCommit
+		trackedRef = git.TrackedRef
+	})
+
+	// Basic authentication is enabled, but not needed for the signed route
+	htpasswd := filepath.Join(t.TempDir(), "htpasswd")
+	if err := ioutil.WriteFile(htpasswd, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewRouter(true, htpasswd, ValidationEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(file string, headers map[string]string) *httptest.ResponseRecorder {
+		payload, err := ioutil.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/hooks/git", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	githubPush := map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": githubPushSignature}
+
+	if w := send("github-push.json", githubPush); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when the secret is not set, got %d %s", w.Code, w.Body.String())
+	}
+	SetGitWebhookSecret(testGitHookSecret)
+
+	testCases := []struct {
+		name string
+		file string
+		headers map[string]string
+		code int
+		message string
+		pull string
+	}{
+		{"github push", "github-push.json", githubPush, http.StatusOK, "Request to update git repository received.", "76ae82f3a0cb5c3a2c6ef1e27c43c6b8a0a1b1c4"},
+		{"github ping", "github-ping.json", map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": githubPingSignature}, http.StatusOK, "pong", ""},
+		{"github invalid signature", "github-push.json", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": githubPingSignature}, http.StatusUnauthorized, "Invalid signature", ""},
+		{"github missing signature", "github-push.json", map[string]string{"X-GitHub-Event": "push"}, http.StatusUnauthorized, "Invalid signature", ""},
+		{"gitlab other branch", "gitlab-push.json", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": testGitHookSecret}, http.StatusOK, "Push to refs/heads/feature/new-cloud ignored", ""},
+		{"gitlab invalid token", "gitlab-push.json", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "wrong"}, http.StatusUnauthorized, "Invalid signature", ""},
+		{"gitlab other event", "gitlab-push.json", map[string]string{"X-Gitlab-Event": "Merge Request Hook", "X-Gitlab-Token": testGitHookSecret}, http.StatusOK, "ignored", ""},
+		{"unknown sender", "github-push.json", map[string]string{}, http.StatusUnauthorized, "Invalid signature", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := send(tc.file, tc.headers)
+			if w.Code != tc.code || ! strings.Contains(w.Body.String(), tc.message) {
+				t.Errorf("expected %d %q, got %d %s", tc.code, tc.message, w.Code, w.Body.String())
+			}
+			select {
+			case hash := <-pulls:
+				if hash != tc.pull {
+					t.Errorf("expected a pull of %q, got %s", tc.pull, hash)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tc.pull != "" {
+					t.Error("expected a pull")
+				}
+			}
+		})
+	}
+
+	// Not cloned yet
+	trackedRef = func() string { return "" }
+	if w := send("github-push.json", githubPush); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the clone, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// Replaced in the tests
+var (
+	setPinned = watcher.SetPinned
+	hasCommit = git.HasCommit
+)
+
+// commitHashRegexp matches a full commit hash. The short hashes are ambiguous.
+var commitHashRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// v1PutRepoPin pins the config of all the replicas to a commit of the config repository,
+// to roll back a bad change without changing the repository. The pulls are ignored until
+// the config is unpinned.
+func v1PutRepoPin(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	var pin v1.RepoPin
+	if err := dec.Decode(&pin); err != io.EOF && err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: "Error reading data from body. "+err.Error(),
+		})
+		return
+	}
+	if ! commitHashRegexp.MatchString(pin.Commit) {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: "'commit' must be the full hash of a commit, 40 lowercase hexadecimal characters.",
+		})
+		return
+	}
+	// The repository isn't fetched here: the pulls of the watcher fetch it.
+	if ! hasCommit(pin.Commit) {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(v1.Error{
+			Code: http.StatusNotFound,
+			Message: "Commit not found in the tracked ref of the config repository. If it was pushed since the last pull, pull the config first with PUT /api/v1/repo.",
+		})
+		return
+	}
+
+	if err := setPinned(pin.Commit); err != nil {
+		log.Err.Println("v1PutRepoPin", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "ERROR while pinning the config.",
+		})
+		return
+	}
+	log.Out.Println("Config pinned to", pin.Commit)
+	enc.Encode(v1.Message{
+		Message: "Request to pin the config to " + pin.Commit + " received.",
+	})
+}
+
+// v1DeleteRepoPin unpins the config: all the replicas pull the tracked ref again.
+func v1DeleteRepoPin(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+
+	if err := setPinned(""); err != nil {
+		log.Err.Println("v1DeleteRepoPin", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "ERROR while unpinning the config.",
+		})
+		return
+	}
+	log.Out.Println("Config unpinned")
+	enc.Encode(v1.Message{
+		Message: "Request to unpin the config received.",
+	})
+}
+
+// v1GetRepoChanges returns what the last reload of the replica changed in the config.
+func v1GetRepoChanges(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+
+	changes := config.LastChanges()
+	if changes == nil {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(v1.Error{
+			Code: http.StatusNotFound,
+			Message: "The config was not reloaded since the start of the scheduler.",
+		})
+		return
+	}
+	enc.Encode(changes)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepoPin(t *testing.T) {
+	setupConfig(t)
+	known := strings.Repeat("a", 40)
+	pinned := "none"
+	setPinned = func(hash string) error {
+		pinned = hash
+		return nil
+	}
+	hasCommit = func(hash string) bool { return hash == known }
+	t.Cleanup(func() {
+		setPinned = watcher.SetPinned
+		hasCommit = git.HasCommit
+	})
+
+	testCases := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"short hash", `{"commit": "aaaaaaa"}`, http.StatusBadRequest},
+		{"missing commit", `{}`, http.StatusBadRequest},
+		{"unknown commit", `{"commit": "` + strings.Repeat("b", 40) + `"}`, http.StatusNotFound},
+		{"known commit", `{"commit": "` + known + `"}`, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := request(t, "PUT", "/api/v1/repo/pin", tc.body); w.Code != tc.code {
+				t.Errorf("expected %d, got %d %s", tc.code, w.Code, w.Body.String())
+			}
+		})
+	}
+	if pinned != known {
+		t.Errorf("expected the config to be pinned to %s, got %s", known, pinned)
+	}
+
+	if w := request(t, "DELETE", "/api/v1/repo/pin", ""); w.Code != http.StatusOK || pinned != "" {
+		t.Errorf("expected the config to be unpinned, got %d %s pinned %q", w.Code, w.Body.String(), pinned)
+	}
+}
+
+func TestRepoChanges(t *testing.T) {
+	dir := setupConfig(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "clouds", "openstack-red.yml"), []byte("name: openstack-red\nenabled: false\nlabels:\n  region: emea\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadFromDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	w := request(t, "GET", "/api/v1/repo/changes", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var changes v1.ConfigChanges
+	if err := json.Unmarshal(w.Body.Bytes(), &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.CloudsChanged) != 1 || changes.CloudsChanged[0].Name != "openstack-red" || changes.CloudsChanged[0].Enabled == nil || *changes.CloudsChanged[0].Enabled {
+		t.Errorf("expected openstack-red to be disabled, got %+v", changes)
+	}
+}
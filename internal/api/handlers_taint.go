@@ -7,15 +7,48 @@ import (
 	"github.com/redhat-gpe/agnostics/internal/config"
 	"github.com/redhat-gpe/agnostics/internal/log"
 	"github.com/redhat-gpe/agnostics/internal/watcher"
-	"github.com/redhat-gpe/agnostics/internal/db"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"fmt"
+	"errors"
 )
 
+// writeTaintError writes the response corresponding to an error returned
+// by one of the config taint functions.
+func writeTaintError(w http.ResponseWriter, enc *json.Encoder, functionName string, cloudName string, err error) {
+	switch {
+	case err == config.ErrCloudNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(v1.Error{
+			Code: http.StatusNotFound,
+			Message: "Cloud Not Found.",
+		})
+	case err == v1.ErrTaintKeyEffectRequired, err == v1.ErrTaintInvalidEffect,
+		errors.Is(err, config.ErrTaintIndexOutOfRange):
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+	case err == config.ErrCloudHasNoTaint:
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: fmt.Sprintf("Cloud %s has no taint.", cloudName),
+		})
+	default:
+		log.Err.Println(functionName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "Error saving taints.",
+		})
+	}
+}
+
 func v1PostTaintByCloudName(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	functionName := "v1PostTaintByCloudName:"
 	w.Header().Set("Content-Type", "application/json")
@@ -65,37 +98,20 @@ func v1PostTaintByCloudName(w http.ResponseWriter, req *http.Request, params htt
 		return
 	}
 
-	if t.Key == "" || t.Effect == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		enc.Encode(v1.Error{
-			Code: http.StatusBadRequest,
-			Message: "Taint must have 'key' and 'effect'. ",
-		})
-		return
-	}
-
-	if t.Effect != v1.TaintEffectNoSchedule && t.Effect != v1.TaintEffectPreferNoSchedule {
+	if err := t.Validate(); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		enc.Encode(v1.Error{
 			Code: http.StatusBadRequest,
-			Message: "Taint.effect must be 'NoSchedule' or 'PreferNoSchedule'. ",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	clouds := config.GetClouds()
-	cloud, ok := clouds[cloudName]
-	if ! ok {
-		w.WriteHeader(http.StatusNotFound)
-		enc.Encode(v1.Error{
-			Code: http.StatusNotFound,
-			Message: "Cloud Not Found.",
-		})
+	cloud, err := config.TaintCloud(cloudName, t)
+	if err != nil {
+		writeTaintError(w, enc, functionName, cloudName, err)
 		return
 	}
-	cloud.Taint(t)
-	db.SaveTaints(cloud)
-	clouds[cloudName] = cloud
 	watcher.RequestTaintSync()
 	if err := enc.Encode(cloud); err != nil {
 		log.Err.Println(functionName, err)
@@ -124,49 +140,21 @@ func v1DeleteTaintByIndex(w http.ResponseWriter, req *http.Request, params httpr
 		return
 	}
 
-	clouds := config.GetClouds()
-	cloud, ok := clouds[cloudName]
-	if ! ok {
-		w.WriteHeader(http.StatusNotFound)
-		enc.Encode(v1.Error{
-			Code: http.StatusNotFound,
-			Message: "Cloud Not Found.",
-		})
-		return
-	}
-
 	taintIndex, err := strconv.Atoi(params.ByName("taintindex"))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		enc.Encode(v1.Error{
-			Code: http.StatusInternalServerError,
-			Message: "Can't convert index to integer",
-		})
-		return
-	}
-
-	if len(cloud.Taints) == 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		enc.Encode(v1.Error{
 			Code: http.StatusBadRequest,
-			Message: fmt.Sprintf("Cloud %s has no taint.", cloud.Name),
+			Message: "Taint index must be an integer",
 		})
 		return
 	}
 
-
-	if taintIndex >= len(cloud.Taints) || taintIndex < 0{
-		w.WriteHeader(http.StatusBadRequest)
-		enc.Encode(v1.Error{
-			Code: http.StatusBadRequest,
-			Message: fmt.Sprintf("Taint index out of range (must be 0..%d)", len(cloud.Taints)-1),
-		})
+	cloud, err := config.UntaintCloudByIndex(cloudName, taintIndex)
+	if err != nil {
+		writeTaintError(w, enc, functionName, cloudName, err)
 		return
 	}
-
-	cloud.Taints = append(cloud.Taints[:taintIndex], cloud.Taints[taintIndex+1:]...)
-	clouds[cloudName] = cloud
-	db.SaveTaints(cloud)
 	watcher.RequestTaintSync()
 	if err := enc.Encode(cloud); err != nil {
 		log.Err.Println(functionName, err)
@@ -193,17 +181,6 @@ func v1DeleteTaintByCloudName(w http.ResponseWriter, req *http.Request, params h
 		})
 		return
 	}
-	clouds := config.GetClouds()
-	cloud, ok := clouds[cloudName]
-	if ! ok {
-		w.WriteHeader(http.StatusNotFound)
-		enc.Encode(v1.Error{
-			Code: http.StatusNotFound,
-			Message: "Cloud Not Found.",
-		})
-		return
-	}
-
 	body, err := ioutil.ReadAll(req.Body)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -238,43 +215,20 @@ func v1DeleteTaintByCloudName(w http.ResponseWriter, req *http.Request, params h
 		return
 	}
 
-	if t.Key == "" || t.Effect == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		enc.Encode(v1.Error{
-			Code: http.StatusBadRequest,
-			Message: "Taint must have 'key' and 'effect'. ",
-		})
-		return
-	}
-
-	if t.Effect != v1.TaintEffectNoSchedule && t.Effect != v1.TaintEffectPreferNoSchedule {
+	if err := t.Validate(); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		enc.Encode(v1.Error{
 			Code: http.StatusBadRequest,
-			Message: "Taint.effect must be 'NoSchedule' or 'PreferNoSchedule'. ",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	if len(cloud.Taints) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		enc.Encode(v1.Error{
-			Code: http.StatusBadRequest,
-			Message: fmt.Sprintf("Cloud %s has no taint.", cloud.Name),
-		})
+	cloud, err := config.UntaintCloud(cloudName, t)
+	if err != nil {
+		writeTaintError(w, enc, functionName, cloudName, err)
 		return
 	}
-
-	// Remove the taint, build new taint list
-	result := []v1.Taint{}
-	for _, taint := range cloud.Taints {
-		if ! taint.MatchTaint(t) {
-			result = append(result, taint)
-		}
-	}
-	cloud.Taints = result
-	clouds[cloudName] = cloud
-	db.SaveTaints(cloud)
 	watcher.RequestTaintSync()
 	if err := enc.Encode(cloud); err != nil {
 		log.Err.Println(functionName, err)
@@ -302,29 +256,11 @@ func v1DeleteTaintsByCloudName(w http.ResponseWriter, req *http.Request, params
 		return
 	}
 
-	clouds := config.GetClouds()
-	cloud, ok := clouds[cloudName]
-	if ! ok {
-		w.WriteHeader(http.StatusNotFound)
-		enc.Encode(v1.Error{
-			Code: http.StatusNotFound,
-			Message: "Cloud Not Found.",
-		})
-		return
-	}
-
-	if len(cloud.Taints) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		enc.Encode(v1.Error{
-			Code: http.StatusBadRequest,
-			Message: fmt.Sprintf("Cloud %s has no taint.", cloud.Name),
-		})
+	cloud, err := config.UntaintCloudAll(cloudName)
+	if err != nil {
+		writeTaintError(w, enc, functionName, cloudName, err)
 		return
 	}
-
-	cloud.Taints = []v1.Taint{}
-	clouds[cloudName] = cloud
-	db.SaveTaints(cloud)
 	watcher.RequestTaintSync()
 	if err := enc.Encode(cloud); err != nil {
 		log.Err.Println(functionName, err)
@@ -0,0 +1,395 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/events"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/placement"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/redhat-gpe/agnostics/internal/webhook"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v2"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// problemTitles is the short summary of each reason, see v2.Problem.
+var problemTitles = map[string]string{
+	v2.ReasonInvalidBody: "The body of the request is invalid.",
+	v2.ReasonInvalidParameter: "A parameter of the request is invalid.",
+	v2.ReasonInvalidTaint: "The taint is invalid.",
+	v2.ReasonInvalidWebhook: "The webhook is invalid.",
+	v2.ReasonUnauthorized: "Authentication is required.",
+	v2.ReasonNotFound: "The resource does not exist.",
+	v2.ReasonMethodNotAllowed: "The method is not allowed on this resource.",
+	v2.ReasonCloudNotFound: "The cloud does not exist.",
+	v2.ReasonTaintNotFound: "The cloud has no matching taint.",
+	v2.ReasonPlacementNotFound: "The placement does not exist.",
+	v2.ReasonWebhookNotFound: "The webhook does not exist.",
+	v2.ReasonWebhookReadOnly: "The webhook is defined in the config repository.",
+	v2.ReasonAlreadyPlaced: "The uuid already has a placement.",
+	v2.ReasonNoCloudAvailable: "No cloud matches the query.",
+	v2.ReasonTooManyRequests: "Too many requests, retry later.",
+	v2.ReasonInternalError: "Internal Server Error.",
+}
+
+// writeJSON writes v as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(v); err != nil {
+		log.Err.Println("writeJSON", err)
+	}
+}
+
+// writeProblem writes the error as problem+json, see RFC 7807.
+func writeProblem(w http.ResponseWriter, req *http.Request, status int, reason string, detail string) {
+	encodeProblem(w, req, v2.NewProblem(status, reason, problemTitles[reason], detail))
+}
+
+// writeFieldErrors writes the problem listing all the invalid fields of the request.
+func writeFieldErrors(w http.ResponseWriter, req *http.Request, errs v1.FieldErrors) {
+	p := v2.NewProblem(http.StatusBadRequest, v2.ReasonInvalidBody, problemTitles[v2.ReasonInvalidBody], errs.Error())
+	p.Errors = errs
+	encodeProblem(w, req, p)
+}
+
+func encodeProblem(w http.ResponseWriter, req *http.Request, p v2.Problem) {
+	p.Instance = req.URL.Path
+	w.Header().Set("Content-Type", v2.ProblemContentType)
+	w.WriteHeader(p.Status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	enc.Encode(p)
+}
+
+// writeInternalError logs err and writes a generic problem, the detail
+// of internal errors is not returned to the client.
+func writeInternalError(w http.ResponseWriter, req *http.Request, functionName string, err error) {
+	log.Err.Println(functionName, err)
+	writeProblem(w, req, http.StatusInternalServerError, v2.ReasonInternalError, "")
+}
+
+// v2NotFound is used by the router for the unknown routes. The routes of v2 get a problem.
+func v2NotFound(w http.ResponseWriter, req *http.Request) {
+	if strings.HasPrefix(req.URL.Path, "/api/v2/") {
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonNotFound, "")
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// v2MethodNotAllowed is used by the router when the route exists with other methods.
+func v2MethodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	if strings.HasPrefix(req.URL.Path, "/api/v2/") {
+		writeProblem(w, req, http.StatusMethodNotAllowed, v2.ReasonMethodNotAllowed,
+			"Allowed methods: " + w.Header().Get("Allow"))
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// decodeBody decodes the JSON body of the request in v. Unknown fields are rejected.
+func decodeBody(req *http.Request, v interface{}) error {
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// writeV2TaintError writes the problem corresponding to an error returned
+// by one of the config taint functions.
+func writeV2TaintError(w http.ResponseWriter, req *http.Request, functionName string, err error) {
+	switch err {
+	case config.ErrCloudNotFound:
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonCloudNotFound, "")
+	case config.ErrTaintNotFound:
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonTaintNotFound, "")
+	case v1.ErrTaintKeyEffectRequired, v1.ErrTaintInvalidEffect:
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidTaint, err.Error())
+	default:
+		writeInternalError(w, req, functionName, err)
+	}
+}
+
+func v2GetClouds(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	clouds := []v1.Cloud{}
+	for _, v := range config.GetClouds() {
+		clouds = append(clouds, v)
+	}
+	sort.Slice(clouds, func(i, j int) bool { return clouds[i].Name < clouds[j].Name })
+	writeJSON(w, http.StatusOK, clouds)
+}
+
+func v2GetCloud(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	cloud, ok := config.GetClouds()[params.ByName("name")]
+	if ! ok {
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonCloudNotFound, "")
+		return
+	}
+	writeJSON(w, http.StatusOK, cloud)
+}
+
+func v2GetCloudTaints(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	cloud, ok := config.GetClouds()[params.ByName("name")]
+	if ! ok {
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonCloudNotFound, "")
+		return
+	}
+	taints := cloud.Taints
+	if taints == nil {
+		taints = []v1.Taint{}
+	}
+	writeJSON(w, http.StatusOK, taints)
+}
+
+// v2PostCloudTaint adds a taint to the cloud. If a taint with the same key:effect
+// already exists, it's replaced.
+func v2PostCloudTaint(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	functionName := "v2PostCloudTaint:"
+	name := params.ByName("name")
+	t := v1.NewTaint()
+	if err := decodeBody(req, &t); err != nil {
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidBody, err.Error())
+		return
+	}
+
+	cloud, err := config.TaintCloud(name, t)
+	if err != nil {
+		writeV2TaintError(w, req, functionName, err)
+		return
+	}
+	watcher.RequestTaintSync()
+	log.Out.Println(functionName, "cloud", name, "tainted", t)
+	w.Header().Set("Location", "/api/v2/clouds/" + url.PathEscape(name) + "/taints/" + url.PathEscape(t.Key))
+	writeJSON(w, http.StatusCreated, cloud)
+}
+
+// v2DeleteCloudTaints removes all the taints of the cloud.
+func v2DeleteCloudTaints(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	functionName := "v2DeleteCloudTaints:"
+	cloud, err := config.UntaintCloudAll(params.ByName("name"))
+	switch err {
+	case nil:
+		watcher.RequestTaintSync()
+	case config.ErrCloudHasNoTaint:
+		// Nothing to remove
+	default:
+		writeV2TaintError(w, req, functionName, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cloud)
+}
+
+// v2DeleteCloudTaint removes the taints of the cloud with the key.
+// The 'effect' query parameter restricts to the taints with that effect.
+func v2DeleteCloudTaint(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	functionName := "v2DeleteCloudTaint:"
+	effect := req.URL.Query().Get("effect")
+	if effect != "" && effect != v1.TaintEffectNoSchedule && effect != v1.TaintEffectPreferNoSchedule {
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidParameter, v1.ErrTaintInvalidEffect.Error())
+		return
+	}
+
+	cloud, err := config.UntaintCloudByKey(params.ByName("name"), params.ByName("key"), effect)
+	if err != nil {
+		writeV2TaintError(w, req, functionName, err)
+		return
+	}
+	watcher.RequestTaintSync()
+	writeJSON(w, http.StatusOK, cloud)
+}
+
+func v2GetCloudPlacements(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	name := params.ByName("name")
+	if _, ok := config.GetClouds()[name]; ! ok {
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonCloudNotFound, "")
+		return
+	}
+	placements, err := placement.GetByCloud(name, 0)
+	if err != nil {
+		writeInternalError(w, req, "v2GetCloudPlacements:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, placements)
+}
+
+func v2GetRepository(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	gitCommit, err := git.NewGitCommit(git.GetRepo())
+	if err != nil {
+		writeInternalError(w, req, "v2GetRepository:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, gitCommit)
+}
+
+// v2PullRepository requests a pull of the config repository. It's asynchronous.
+func v2PullRepository(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	go watcher.RequestPull()
+	writeJSON(w, http.StatusAccepted, v1.Message{
+		Message: "Request to update git repository received.",
+	})
+}
+
+func v2GetPlacements(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	placements, err := placement.GetAll(0)
+	if err != nil {
+		writeInternalError(w, req, "v2GetPlacements:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, placements)
+}
+
+// v2PostPlacement schedules the query and creates the placement.
+// With the 'dry_run=true' query parameter, the placement is returned but not saved.
+func v2PostPlacement(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	functionName := "v2PostPlacement:"
+	var scheduleQuery v1.ScheduleQuery
+	if err := decodeBody(req, &scheduleQuery); err != nil {
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidBody, err.Error())
+		return
+	}
+	dryRun := req.URL.Query().Get("dry_run") == "true"
+
+	result, err := schedule(scheduleQuery, dryRun)
+	if errs, ok := err.(v1.FieldErrors); ok {
+		writeFieldErrors(w, req, errs)
+		return
+	}
+	switch err {
+	case nil:
+	case errAlreadyPlaced:
+		writeProblem(w, req, http.StatusConflict, v2.ReasonAlreadyPlaced, "")
+		return
+	case errNoCloudFound:
+		writeProblem(w, req, http.StatusUnprocessableEntity, v2.ReasonNoCloudAvailable, "")
+		return
+	default:
+		writeInternalError(w, req, functionName, err)
+		return
+	}
+
+	if dryRun {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	w.Header().Set("Location", "/api/v2/placements/" + url.PathEscape(result.UUID))
+	writeJSON(w, http.StatusCreated, result)
+}
+
+func v2GetPlacement(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	p, err := placement.Get(params.ByName("uuid"))
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, p)
+	case placement.ErrPlacementNotFound:
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonPlacementNotFound, "")
+	default:
+		writeInternalError(w, req, "v2GetPlacement:", err)
+	}
+}
+
+func v2DeletePlacement(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	uuid := params.ByName("uuid")
+	if _, err := placement.Get(uuid); err == placement.ErrPlacementNotFound {
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonPlacementNotFound, "")
+		return
+	} else if err != nil {
+		writeInternalError(w, req, "v2DeletePlacement:", err)
+		return
+	}
+	if err := placement.Delete(uuid); err != nil {
+		writeInternalError(w, req, "v2DeletePlacement:", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// v2RefreshCounters recalculates all the placement counters.
+func v2RefreshCounters(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	log.Out.Println("Refresh all counters")
+	if err := placement.RefreshAllCounters(); err != nil {
+		writeInternalError(w, req, "v2RefreshCounters:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v1.Message{
+		Message: "All counters updated",
+	})
+}
+
+func v2GetEvents(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	since, types, err := parseEventsQuery(req)
+	if err != nil {
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidParameter, err.Error())
+		return
+	}
+	events.ServeSSE(w, req, since, types)
+}
+
+func v2GetWebhooks(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	webhooks, err := webhook.GetAll()
+	if err != nil {
+		writeInternalError(w, req, "v2GetWebhooks:", err)
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+func v2PostWebhook(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	functionName := "v2PostWebhook:"
+	var wh v1.Webhook
+	if err := decodeBody(req, &wh); err != nil {
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidBody, err.Error())
+		return
+	}
+	if err := wh.Validate(); err != nil {
+		writeProblem(w, req, http.StatusBadRequest, v2.ReasonInvalidWebhook, err.Error())
+		return
+	}
+
+	wh, err := webhook.Create(wh)
+	if err != nil {
+		writeInternalError(w, req, functionName, err)
+		return
+	}
+	log.Out.Println(functionName, "webhook", wh.ID, "created for", wh.URL)
+	wh.Secret = ""
+	w.Header().Set("Location", "/api/v2/webhooks/" + url.PathEscape(wh.ID))
+	writeJSON(w, http.StatusCreated, wh)
+}
+
+func v2DeleteWebhook(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	switch err := deleteWebhook(params.ByName("id")); {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case err == errWebhookReadOnly:
+		writeProblem(w, req, http.StatusConflict, v2.ReasonWebhookReadOnly, err.Error())
+	case errors.Is(err, webhook.ErrWebhookNotFound):
+		writeProblem(w, req, http.StatusNotFound, v2.ReasonWebhookNotFound, "")
+	default:
+		writeInternalError(w, req, "v2DeleteWebhook:", err)
+	}
+}
+
+func v2GetDeadLetters(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	deadLetters, err := webhook.GetDeadLetters()
+	if err != nil {
+		writeInternalError(w, req, "v2GetDeadLetters:", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deadLetters)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v2"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupConfig loads a config with two clouds and returns its directory. Redis is not available
+// in the tests, so only the requests that don't write can succeed.
+func setupConfig(t *testing.T) string {
+	log.InitLoggers(false)
+	dir, err := ioutil.TempDir("", "agnostics-api-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	files := map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicates\n  - name: TaintPredicates\npriorities:\n  - name: LabelPriorities\n    weight: 1\n",
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels:\n  region: na\n",
+		"clouds/openstack-red.yml": "name: openstack-red\nlabels:\n  region: emea\n",
+	}
+	os.Mkdir(filepath.Join(dir, "clouds"), 0755)
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config.LoadFromDir(dir)
+	return dir
+}
+
+// request sends the request to the router, with the validation enforced
+// so the responses that don't match the OpenAPI documents fail the tests.
+func request(t *testing.T, method string, path string, body string) *httptest.ResponseRecorder {
+	return requestWithValidation(t, ValidationEnforce, method, path, body)
+}
+
+func requestWithValidation(t *testing.T, mode ValidationMode, method string, path string, body string) *httptest.ResponseRecorder {
+	router, err := NewRouter(false, "", mode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func checkProblem(t *testing.T, w *httptest.ResponseRecorder, status int, reason string) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != v2.ProblemContentType {
+		t.Errorf("expected Content-Type %s, got %s", v2.ProblemContentType, ct)
+	}
+	var p v2.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Reason != reason || p.Type != v2.ProblemTypePrefix+reason || p.Status != status || p.Title == "" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
+
+func TestV2Clouds(t *testing.T) {
+	setupConfig(t)
+
+	w := request(t, "GET", "/api/v2/clouds", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var clouds []v1.Cloud
+	if err := json.Unmarshal(w.Body.Bytes(), &clouds); err != nil {
+		t.Fatal(err)
+	}
+	if len(clouds) != 2 || clouds[0].Name != "openstack-blue" || clouds[1].Name != "openstack-red" {
+		t.Errorf("unexpected clouds %v", clouds)
+	}
+
+	w = request(t, "GET", "/api/v2/clouds/unknown", "")
+	checkProblem(t, w, http.StatusNotFound, v2.ReasonCloudNotFound)
+	var p v2.Problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if p.Instance != "/api/v2/clouds/unknown" {
+		t.Errorf("expected instance to be the path, got %s", p.Instance)
+	}
+
+	w = request(t, "GET", "/api/v2/clouds/openstack-red/taints", "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected empty list of taints, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestV2Taints(t *testing.T) {
+	setupConfig(t)
+
+	testCases := []struct {
+		method string
+		path string
+		body string
+		status int
+		reason string
+	}{
+		{"POST", "/api/v2/clouds/unknown/taints", `{"key": "foo", "effect": "NoSchedule"}`, http.StatusNotFound, v2.ReasonCloudNotFound},
+		{"POST", "/api/v2/clouds/openstack-blue/taints", `{"key": "foo", "effect": "NoExecute"}`, http.StatusBadRequest, v2.ReasonInvalidTaint},
+		{"POST", "/api/v2/clouds/openstack-blue/taints", `{"key": "foo"}`, http.StatusBadRequest, v2.ReasonInvalidTaint},
+		{"POST", "/api/v2/clouds/openstack-blue/taints", `{"key": "foo", "unknown": 1}`, http.StatusBadRequest, v2.ReasonInvalidBody},
+		{"POST", "/api/v2/clouds/openstack-blue/taints", `{"key": `, http.StatusBadRequest, v2.ReasonInvalidBody},
+		{"DELETE", "/api/v2/clouds/openstack-blue/taints/foo", "", http.StatusNotFound, v2.ReasonTaintNotFound},
+		{"DELETE", "/api/v2/clouds/unknown/taints/foo", "", http.StatusNotFound, v2.ReasonCloudNotFound},
+		{"DELETE", "/api/v2/clouds/openstack-blue/taints/foo?effect=NoExecute", "", http.StatusBadRequest, v2.ReasonInvalidParameter},
+		{"DELETE", "/api/v2/clouds/unknown/taints", "", http.StatusNotFound, v2.ReasonCloudNotFound},
+	}
+
+	// The handlers validate the requests on their own, without the OpenAPI documents.
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			checkProblem(t, requestWithValidation(t, ValidationLog, tc.method, tc.path, tc.body), tc.status, tc.reason)
+		})
+	}
+
+	// Nothing to remove
+	if w := request(t, "DELETE", "/api/v2/clouds/openstack-blue/taints", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestV2DryRun(t *testing.T) {
+	setupConfig(t)
+
+	w := request(t, "POST", "/api/v2/placements?dry_run=true", `{"cloud_selector": {"region": "emea"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var p v1.Placement
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Cloud.Name != "openstack-red" {
+		t.Errorf("expected openstack-red, got %s", p.Cloud.Name)
+	}
+
+	w = request(t, "POST", "/api/v2/placements?dry_run=true", `{"cloud_selector": {"region": "apac"}}`)
+	checkProblem(t, w, http.StatusUnprocessableEntity, v2.ReasonNoCloudAvailable)
+
+	w = request(t, "POST", "/api/v2/placements", `{"cloud_selector": {"region": "emea"}}`)
+	checkProblem(t, w, http.StatusBadRequest, v2.ReasonInvalidBody)
+}
+
+func TestV2Router(t *testing.T) {
+	setupConfig(t)
+
+	checkProblem(t, request(t, "GET", "/api/v2/unknown", ""), http.StatusNotFound, v2.ReasonNotFound)
+	checkProblem(t, request(t, "PUT", "/api/v2/clouds", ""), http.StatusMethodNotAllowed, v2.ReasonMethodNotAllowed)
+
+	// v1 is unchanged
+	if w := request(t, "GET", "/api/v1/unknown", ""); w.Code != http.StatusNotFound || w.Header().Get("Content-Type") == v2.ProblemContentType {
+		t.Errorf("expected plain 404 for v1, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestV1TaintIndexNotInteger(t *testing.T) {
+	setupConfig(t)
+
+	w := request(t, "DELETE", "/api/v1/taint/openstack-blue/abc", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+	var e v1.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Code != http.StatusBadRequest {
+		t.Errorf("unexpected error body %s", w.Body.String())
+	}
+}
+
+func TestScheduleFieldErrors(t *testing.T) {
+	setupConfig(t)
+
+	body := `{"cloud_selector": {"bad key": "x"}, "tolerations": [{"operator": "Equal"}, {"key": "maintenance", "operator": "Unknown"}]}`
+
+	// The handlers report all the invalid fields at once
+	w := requestWithValidation(t, ValidationLog, "POST", "/api/v2/placements", body)
+	checkProblem(t, w, http.StatusBadRequest, v2.ReasonInvalidBody)
+	var p v2.Problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if len(p.Errors) != 4 {
+		t.Errorf("expected 4 field errors (uuid, selector, 2 tolerations), got %v", p.Errors)
+	}
+
+	w = requestWithValidation(t, ValidationLog, "POST", "/api/v1/schedule", body)
+	var e v1.Error
+	json.Unmarshal(w.Body.Bytes(), &e)
+	if w.Code != http.StatusBadRequest || len(e.Errors) != 4 {
+		t.Errorf("expected 400 with 4 field errors, got %d %v", w.Code, e.Errors)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/webhook"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// errWebhookReadOnly is returned when deleting a webhook defined in the config repository.
+var errWebhookReadOnly = errors.New("This webhook is defined in the config repository and cannot be deleted through the API.")
+
+// deleteWebhook deletes the webhook, unless it's defined in the config repository.
+func deleteWebhook(id string) error {
+	if strings.HasPrefix(id, "config-") {
+		return errWebhookReadOnly
+	}
+	return webhook.Delete(id)
+}
+
+func v1GetWebhooks(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	webhooks, err := webhook.GetAll()
+	if err != nil {
+		log.Err.Println("GET webhooks", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "Internal Server Error",
+		})
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	enc.Encode(webhooks)
+}
+
+func v1PostWebhook(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	functionName := "v1PostWebhook:"
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Err.Println(functionName, err)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: "Error reading body from request.",
+		})
+		return
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.DisallowUnknownFields()
+	var wh v1.Webhook
+	if err := dec.Decode(&wh); err != io.EOF && err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: "Error reading data from body. "+err.Error(),
+		})
+		return
+	}
+
+	if err := wh.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	wh, err = webhook.Create(wh)
+	if err != nil {
+		log.Err.Println(functionName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "Error saving webhook.",
+		})
+		return
+	}
+	log.Out.Println(functionName, "webhook", wh.ID, "created for", wh.URL)
+	wh.Secret = ""
+	enc.Encode(wh)
+}
+
+func v1DeleteWebhook(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	functionName := "v1DeleteWebhook:"
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	id := params.ByName("id")
+
+	if err := deleteWebhook(id); err == errWebhookReadOnly {
+		w.WriteHeader(http.StatusBadRequest)
+		enc.Encode(v1.Error{
+			Code: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	} else if err == webhook.ErrWebhookNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		enc.Encode(v1.Error{
+			Code: http.StatusNotFound,
+			Message: "Webhook not found.",
+		})
+		return
+	} else if err != nil {
+		log.Err.Println(functionName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "Internal Server Error",
+		})
+		return
+	}
+	enc.Encode(v1.Message{
+		Message: "webhook deleted",
+	})
+}
+
+func v1GetWebhookDeadLetters(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	deadLetters, err := webhook.GetDeadLetters()
+	if err != nil {
+		log.Err.Println("GET webhooks dead letters", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		enc.Encode(v1.Error{
+			Code: http.StatusInternalServerError,
+			Message: "Internal Server Error",
+		})
+		return
+	}
+	enc.Encode(deadLetters)
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/docs/api-reference"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v2"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ValidationMode defines what happens when a request or a response
+// doesn't match the OpenAPI document of the API.
+type ValidationMode string
+
+const (
+	// ValidationEnforce rejects the invalid requests with 400 and replaces
+	// the invalid responses with 500. Use it for development and tests.
+	ValidationEnforce ValidationMode = "enforce"
+	// ValidationLog only logs the invalid requests and responses.
+	ValidationLog ValidationMode = "log"
+	// ValidationOff disables the validation.
+	ValidationOff ValidationMode = "off"
+)
+
+// ParseValidationMode returns the ValidationMode corresponding to s.
+func ParseValidationMode(s string) (ValidationMode, error) {
+	switch m := ValidationMode(s); m {
+	case ValidationEnforce, ValidationLog, ValidationOff:
+		return m, nil
+	}
+	return "", fmt.Errorf("Unknown validation mode '%s'. Must be one of: enforce, log, off", s)
+}
+
+// loadSpec loads the OpenAPI document of the version of the API from the embedded files.
+// The servers of the document are replaced by the path of the version, so
+// requests match whatever the host is.
+func loadSpec(version string) (*openapi3.T, error) {
+	name, ok := apireference.Specs[version]
+	if ! ok {
+		return nil, fmt.Errorf("no OpenAPI document for %s", version)
+	}
+	data, err := fs.ReadFile(apireference.FS, name)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := openapi3.NewLoader()
+	// The v2 document references the schemas of v1
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(_ *openapi3.Loader, location *url.URL) ([]byte, error) {
+		return fs.ReadFile(apireference.FS, strings.TrimPrefix(location.Path, "/"))
+	}
+	doc, err := loader.LoadFromDataWithPath(data, &url.URL{Path: name})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	doc.Servers = openapi3.Servers{{URL: "/api/" + version}}
+	return doc, nil
+}
+
+// validator checks the requests and responses of the API against the OpenAPI documents.
+type validator struct {
+	mode ValidationMode
+	routers map[string]routers.Router
+}
+
+func newValidator(mode ValidationMode) (*validator, error) {
+	v := &validator{
+		mode: mode,
+		routers: map[string]routers.Router{},
+	}
+	if mode == ValidationOff {
+		return v, nil
+	}
+	for version := range apireference.Specs {
+		doc, err := loadSpec(version)
+		if err != nil {
+			return nil, err
+		}
+		if v.routers[version], err = gorillamux.NewRouter(doc); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// validationMessage returns a short message for err, without the schema.
+func validationMessage(err error) string {
+	var schemaError *openapi3.SchemaError
+	if ! errors.As(err, &schemaError) {
+		return err.Error()
+	}
+	message := schemaError.Reason
+	if field := strings.Join(schemaError.JSONPointer(), "."); field != "" {
+		message = "'" + field + "': " + message
+	}
+	var requestError *openapi3filter.RequestError
+	if errors.As(err, &requestError) {
+		if requestError.Parameter != nil {
+			return "Invalid parameter '" + requestError.Parameter.Name + "': " + message
+		}
+		return "Invalid body: " + message
+	}
+	return "Invalid response: " + message
+}
+
+// writeValidationError writes the error using the format of the version of the API.
+func writeValidationError(w http.ResponseWriter, req *http.Request, version string, status int, err error) {
+	if version == "v1" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v1.Error{
+			Code: int32(status),
+			Message: validationMessage(err),
+		})
+		return
+	}
+
+	reason := v2.ReasonInternalError
+	if status == http.StatusBadRequest {
+		reason = v2.ReasonInvalidBody
+		var requestError *openapi3filter.RequestError
+		if errors.As(err, &requestError) && requestError.Parameter != nil {
+			reason = v2.ReasonInvalidParameter
+		}
+	}
+	writeProblem(w, req, status, reason, validationMessage(err))
+}
+
+// responseBuffer keeps the response until it's validated.
+// Streams (text/event-stream) are not buffered nor validated.
+type responseBuffer struct {
+	w http.ResponseWriter
+	status int
+	body bytes.Buffer
+	streaming bool
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.w.Header()
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status != 0 {
+		return
+	}
+	b.status = status
+	if strings.HasPrefix(b.w.Header().Get("Content-Type"), "text/event-stream") {
+		b.streaming = true
+		b.w.WriteHeader(status)
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if b.streaming {
+		return b.w.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+func (b *responseBuffer) Flush() {
+	if f, ok := b.w.(http.Flusher); ok && b.streaming {
+		f.Flush()
+	}
+}
+
+// flush writes the buffered response.
+func (b *responseBuffer) flush() {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	b.w.WriteHeader(b.status)
+	b.w.Write(b.body.Bytes())
+}
+
+// wrap returns the handle validating the request before calling h, and the response after.
+func (v *validator) wrap(version string, h httprouter.Handle) httprouter.Handle {
+	router, ok := v.routers[version]
+	if v.mode == ValidationOff || ! ok {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		route, pathParams, err := router.FindRoute(req)
+		if err != nil {
+			log.Err.Println("OpenAPI:", req.Method, req.URL.Path, "is not documented:", err)
+			if v.mode == ValidationEnforce {
+				writeValidationError(w, req, version, http.StatusInternalServerError,
+					fmt.Errorf("%s %s is not in the OpenAPI document", req.Method, req.URL.Path))
+				return
+			}
+			h(w, req, params)
+			return
+		}
+
+		// The handlers decode the body as JSON whatever the Content-Type is,
+		// for example 'curl -d' sends application/x-www-form-urlencoded.
+		// Validate a copy of the request with the Content-Type expected by the document.
+		validated := req
+		if ct := req.Header.Get("Content-Type"); ct == "" || ct == "application/x-www-form-urlencoded" {
+			validated = req.Clone(req.Context())
+			validated.Header.Set("Content-Type", "application/json")
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request: validated,
+			PathParams: pathParams,
+			Route: route,
+			Options: &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		err = openapi3filter.ValidateRequest(req.Context(), input)
+		// The body was read by the validation
+		req.Body = validated.Body
+		if err != nil {
+			log.Out.Println("OpenAPI: invalid request", req.Method, req.URL.Path, validationMessage(err))
+			if v.mode == ValidationEnforce {
+				writeValidationError(w, req, version, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		buffer := &responseBuffer{w: w}
+		h(buffer, req, params)
+		if buffer.streaming {
+			return
+		}
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status: buffer.status,
+			Header: buffer.Header(),
+			Options: input.Options,
+		}
+		if buffer.status == 0 {
+			responseInput.Status = http.StatusOK
+		}
+		responseInput.SetBodyBytes(buffer.body.Bytes())
+		if err := openapi3filter.ValidateResponse(req.Context(), responseInput); err != nil {
+			log.Err.Println("OpenAPI: invalid response", req.Method, req.URL.Path, validationMessage(err))
+			if v.mode == ValidationEnforce {
+				for k := range w.Header() {
+					delete(w.Header(), k)
+				}
+				writeValidationError(w, req, version, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		buffer.flush()
+	}
+}
+
+// docsHandler serves Swagger UI and the OpenAPI documents.
+func docsHandler() httprouter.Handle {
+	fileServer := http.StripPrefix("/api/docs", http.FileServer(http.FS(apireference.FS)))
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		fileServer.ServeHTTP(w, req)
+	}
+}
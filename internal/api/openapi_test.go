@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestRoutesDocumented checks that the route table and the OpenAPI documents match.
+// The names of the path parameters are ignored.
+func TestRoutesDocumented(t *testing.T) {
+	routeParam := regexp.MustCompile(`:[a-z]+`)
+	specParam := regexp.MustCompile(`\{[^}]+\}`)
+
+	for version, routes := range map[string][]route{"v1": v1Routes, "v2": v2Routes} {
+		doc, err := loadSpec(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		documented := map[string]bool{}
+		for path, item := range doc.Paths {
+			for method := range item.Operations() {
+				documented[method+" "+specParam.ReplaceAllString(path, "{}")] = true
+			}
+		}
+
+		for _, r := range routes {
+			op := r.method + " " + routeParam.ReplaceAllString(strings.TrimPrefix(r.path, "/api/"+version), "{}")
+			if ! documented[op] {
+				t.Errorf("%s %s is not in the OpenAPI document of %s", r.method, r.path, version)
+			}
+			delete(documented, op)
+		}
+		for op := range documented {
+			t.Errorf("%s is in the OpenAPI document of %s but has no route", op, version)
+		}
+	}
+}
+
+func TestDocs(t *testing.T) {
+	setupConfig(t)
+
+	w := request(t, "GET", "/api/docs", "")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/api/docs/" {
+		t.Errorf("expected redirect to /api/docs/, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	for _, path := range []string{"/api/docs/", "/api/docs/swagger.yaml", "/api/docs/swagger-v2.yaml", "/api/docs/dist/swagger-ui-bundle.js"} {
+		if w := request(t, "GET", path, ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestValidation(t *testing.T) {
+	setupConfig(t)
+
+	// Invalid requests are rejected only when the validation is enforced
+	body := `{"cloud_selector": {"region": 1}}`
+	checkProblem(t, request(t, "POST", "/api/v2/placements?dry_run=true", body), http.StatusBadRequest, "InvalidBody")
+	if w := requestWithValidation(t, ValidationLog, "POST", "/api/v2/placements?dry_run=true", body); w.Code == http.StatusOK {
+		t.Errorf("expected the handler to reject the request")
+	}
+	checkProblem(t, request(t, "POST", "/api/v2/placements?dry_run=maybe", `{}`), http.StatusBadRequest, "InvalidParameter")
+
+	// The health check is documented, even when redis is not available.
+	if w := request(t, "GET", "/api/v1/health", ""); w.Code != http.StatusInternalServerError || ! strings.HasPrefix(w.Body.String(), "ERROR") {
+		t.Errorf("unexpected health response %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	gogit "github.com/go-git/go-git/v5"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentScheduleTaintReload schedules, taints and reloads the config at the same time.
+// Run it with -race to detect the data races.
+func TestConcurrentScheduleTaintReload(t *testing.T) {
+	dir := setupConfig(t)
+	// Without redis
+	config.SaveTaints = func(v1.Cloud) error { return nil }
+	t.Cleanup(func() { config.SaveTaints = db.SaveTaints })
+
+	router, err := NewRouter(false, "", ValidationEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	run(func(int) {
+		query := v1.ScheduleQuery{CloudSelector: map[string]string{"region": "na"}, CloudPreference: map[string]string{"region": "emea"}}
+		if _, err := schedule(query, true); err != nil && err != errNoCloudFound {
+			t.Error(err)
+		}
+	})
+	run(func(i int) {
+		taint := v1.Taint{Key: fmt.Sprintf("key%d", i%5), Effect: v1.TaintEffectPreferNoSchedule}
+		if _, err := config.TaintCloud("openstack-blue", taint); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func(i int) {
+		config.UntaintCloudByKey("openstack-blue", fmt.Sprintf("key%d", i%5), "")
+	})
+	run(func(int) {
+		if err := config.LoadFromDir(dir); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func(int) {
+		config.ReloadTaints()
+	})
+	run(func(int) {
+		for _, c := range config.GetClouds() {
+			_ = len(c.Taints)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v2/clouds", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET /api/v2/clouds: %d %s", w.Code, w.Body.String())
+		}
+	})
+	wg.Wait()
+}
+
+// commitPolicy adds a commit changing policy.yaml to the repository in dir and returns its hash.
+func commitPolicy(t *testing.T, repo *gogit.Repository, dir string, content string) string {
+	if err := ioutil.WriteFile(filepath.Join(dir, "policy.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("policy.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("Update the policy", &gogit.CommitOptions{
+		Author: &gitobject.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+// TestConcurrentPinPull pins and unpins the config through the API while the repository
+// is pulled, like the watcher does. Run it with -race to detect the data races.
+func TestConcurrentPinPull(t *testing.T) {
+	setupConfig(t)
+	origin := t.TempDir()
+	originRepo, err := gogit.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitPolicy(t, originRepo, origin, "predicates: []\n")
+	git.CloneRepository(git.CloneOptions{URL: origin, CacheDir: t.TempDir()})
+	// Fetched by the pulls
+	second := commitPolicy(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+
+	// Without redis, the watcher of this replica receives the pin directly
+	setPinned = func(hash string) error {
+		if hash == "" {
+			git.Unpin()
+			return nil
+		}
+		if err := git.Pin(hash); err != nil && err != gogit.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	}
+	t.Cleanup(func() {
+		setPinned = watcher.SetPinned
+		git.Unpin()
+	})
+
+	router, err := NewRouter(false, "", ValidationEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method string, path string, body string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: %d %s", method, path, w.Code, w.Body.String())
+		}
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		hash := first
+		if i%2 == 0 {
+			hash = second
+		}
+		// The first commit is refused once the second one is checked out
+		switch err := git.UpdateTo(hash); {
+		case err == nil, err == gogit.NoErrAlreadyUpToDate, err == git.ErrPinned, errors.Is(err, git.ErrStaleCommit):
+		default:
+			t.Error(err)
+		}
+		switch err := git.RefreshRepository(); err {
+		case nil, gogit.NoErrAlreadyUpToDate, git.ErrPinned, git.ErrUpdatedTooRecently:
+		default:
+			t.Error(err)
+		}
+	})
+	run(func(int) {
+		serve("PUT", "/api/v1/repo/pin", `{"commit": "`+first+`"}`)
+		serve("DELETE", "/api/v1/repo/pin", "")
+	})
+	run(func(int) {
+		serve("GET", "/api/v1/repo", "")
+	})
+	wg.Wait()
+}
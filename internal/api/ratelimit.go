@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/ratelimit"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v2"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// clientID returns the identity the limits are applied to: the authenticated user,
+// or the IP address when the request is not authenticated.
+func clientID(req *http.Request) string {
+	if user, ok := UserFromContext(req.Context()); ok {
+		return "user:" + user
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// writeTooManyRequests writes the 429 response using the format of the version of the API.
+func writeTooManyRequests(w http.ResponseWriter, req *http.Request, version string, retryAfter time.Duration, detail string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	detail = fmt.Sprintf("%s, retry in %d seconds.", detail, seconds)
+
+	if version == "v1" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(v1.Error{
+			Code: http.StatusTooManyRequests,
+			Message: detail,
+		})
+		return
+	}
+	writeProblem(w, req, http.StatusTooManyRequests, v2.ReasonTooManyRequests, detail)
+}
+
+// rateLimit returns the handle applying the limits of the limiter to the route before calling h.
+// route is the method and the path of the route table, for example 'POST /api/v1/schedule'.
+func rateLimit(limiter *ratelimit.Limiter, version string, route string, h httprouter.Handle) httprouter.Handle {
+	if limiter == nil || ! limiter.Rules.Enabled() {
+		return h
+	}
+
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		client := clientID(req)
+		if ok, retryAfter := limiter.Allow(client, route); ! ok {
+			log.Debug.Println("ratelimit:", client, "exceeded the rate limit of", route)
+			writeTooManyRequests(w, req, version, retryAfter, "Rate limit exceeded for "+route)
+			return
+		}
+
+		release, ok := limiter.Acquire(client)
+		if ! ok {
+			log.Debug.Println("ratelimit:", client, "has too many requests in progress")
+			writeTooManyRequests(w, req, version, time.Second,
+				fmt.Sprintf("Too many requests in progress, the maximum is %d", limiter.Rules.MaxConcurrent))
+			return
+		}
+		defer release()
+
+		h(w, req, params)
+	}
+}
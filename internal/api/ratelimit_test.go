@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/internal/ratelimit"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingStore is a ratelimit.Store counting the requests without expiration.
+type countingStore map[string]int64
+
+func (s countingStore) Incr(key string, window time.Duration) (int64, time.Duration, error) {
+	s[key]++
+	return s[key], 30 * time.Second, nil
+}
+
+func (s countingStore) Acquire(key string, id string, max int, timeout time.Duration) (bool, error) {
+	return s[key] < int64(max), nil
+}
+
+func (s countingStore) Release(key string, id string) error {
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	setupConfig(t)
+
+	limiter := &ratelimit.Limiter{
+		Rules: ratelimit.Rules{
+			Default: 1,
+			Routes: map[string]int{"GET /api/v2/clouds": 2},
+			Window: time.Minute,
+		},
+		Store: countingStore{},
+	}
+	router, err := NewRouter(false, "", ValidationEnforce, limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(path string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("/api/v1/clouds", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	w := send("/api/v1/clouds", "10.0.0.1:1235")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected 429 with Retry-After 30, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	var e v1.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Code != http.StatusTooManyRequests {
+		t.Errorf("unexpected error body %s", w.Body.String())
+	}
+
+	// Another client
+	if w := send("/api/v1/clouds", "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for another client, got %d", w.Code)
+	}
+
+	// Per-route limit
+	for i := 0; i < 2; i++ {
+		if w := send("/api/v2/clouds", "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+	w = send("/api/v2/clouds", "10.0.0.1:1234")
+	checkProblem(t, w, http.StatusTooManyRequests, v2.ReasonTooManyRequests)
+	if w.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected Retry-After 30, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// Health is not limited
+	for i := 0; i < 3; i++ {
+		if w := send("/api/v2/health", "10.0.0.1:1234"); w.Code == http.StatusTooManyRequests {
+			t.Fatal("expected health not to be rate limited")
+		}
+	}
+}
+
+func TestRateLimitUnknownRoute(t *testing.T) {
+	setupConfig(t)
+
+	limiter := &ratelimit.Limiter{
+		Rules: ratelimit.Rules{Routes: map[string]int{"POST /api/v1/unknown": 1}},
+		Store: countingStore{},
+	}
+	if _, err := NewRouter(false, "", ValidationOff, limiter); err == nil {
+		t.Error("expected error for an unknown route")
+	}
+}
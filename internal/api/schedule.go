@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/modules"
+	"github.com/redhat-gpe/agnostics/internal/placement"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"time"
+)
+
+var errAlreadyPlaced = errors.New("This service uuid already has a placement")
+var errNoCloudFound = errors.New("No cloud found.")
+
+// schedule validates the query, then runs the predicates and priorities of the policy to pick a cloud
+// for the query and saves the placement. With dryRun, the placement
+// is computed but not saved, and the uuid is optional.
+// If the query is invalid, the error is a v1.FieldErrors with all the invalid fields.
+// It's shared by all the versions of the API.
+func schedule(scheduleQuery v1.ScheduleQuery, dryRun bool) (v1.Placement, error) {
+	errs := v1.FieldErrors{}
+	if scheduleQuery.UUID == "" && ! dryRun {
+		errs = append(errs, v1.FieldError{Field: "uuid", Message: "must be provided"})
+	}
+	errs = append(errs, scheduleQuery.Validate()...)
+	if len(errs) > 0 {
+		return v1.Placement{}, errs
+	}
+
+	if ! dryRun {
+		if _, err := placement.Get(scheduleQuery.UUID) ; err != placement.ErrPlacementNotFound {
+			if err == nil {
+				return v1.Placement{}, errAlreadyPlaced
+			}
+			// Else something went wrong
+			return v1.Placement{}, err
+		}
+	}
+
+	// The same config for the whole computation, even if it's reloaded meanwhile
+	snapshot := config.Current()
+	policy := snapshot.Policy
+	clouds := []v1.Cloud{}
+	for _, c := range snapshot.Clouds {
+		clouds = append(clouds, c)
+	}
+
+	for _, predicate := range policy.Predicates {
+		switch predicate.Name {
+		case "LabelPredicates":
+			clouds = modules.LabelPredicates(clouds, scheduleQuery.CloudSelector)
+		case "TaintPredicates":
+			clouds = modules.TaintPredicates(clouds, scheduleQuery.Tolerations)
+		default:
+			log.Err.Println("Predicate", predicate.Name, "not supported")
+		}
+	}
+
+	for _, priority := range policy.Priorities {
+		switch priority.Name {
+		case "LabelPriorities":
+			clouds = modules.LabelPriorities(clouds, scheduleQuery.CloudPreference, priority.Weight)
+		case "TaintPriorities":
+			clouds = modules.TaintPriorities(clouds, scheduleQuery.Tolerations, priority.Weight)
+		default:
+			log.Err.Println("Priority", priority.Name, "not supported")
+		}
+	}
+
+	if len(clouds) == 0 {
+		return v1.Placement{}, errNoCloudFound
+	}
+	// pick the first one
+	result := v1.Placement{
+		UUID: scheduleQuery.UUID,
+		Cloud: clouds[0],
+		CreationTimestamp: time.Now().UTC().Round(time.Second),
+		Annotations: scheduleQuery.Annotations,
+	}
+	if dryRun {
+		log.Out.Println("schedule, dry run:", result.Cloud.Name)
+		return result, nil
+	}
+	placement.Save(result)
+	return result, nil
+}
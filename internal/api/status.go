@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/server"
+	"github.com/redhat-gpe/agnostics/internal/source"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// getStatus returns the status of the components of the scheduler.
+func getStatus() v1.Status {
+	status := v1.Status{
+		Stopping: server.Draining(),
+		Subscriptions: watcher.Subscriptions(),
+	}
+
+	if conn, err := db.Dial(); err == nil {
+		if _, err := conn.Do("PING"); err == nil {
+			status.Redis.Connected = true
+		} else {
+			status.Redis.Error = err.Error()
+		}
+		conn.Close()
+	} else {
+		status.Redis.Error = err.Error()
+	}
+
+	if repo := git.GetRepo(); repo != nil {
+		status.Git.Cloned = true
+		status.Git.Cached = git.Cached()
+		if head, err := git.NewGitCommit(repo); err == nil {
+			status.Git.Head = &head
+		}
+	}
+	if t, err := git.LastPull(); ! t.IsZero() {
+		t = t.UTC()
+		status.Git.LastPull = &t
+		if err != nil {
+			status.Git.LastPullError = err.Error()
+		}
+	}
+
+	if src := config.GetSource(); src != nil {
+		status.Config.Source = src.Kind()
+	}
+	status.Config.Revision = config.Current().Revision
+	if t := config.LoadTimestamp(); ! t.IsZero() {
+		t = t.UTC()
+		status.Config.Loaded = true
+		status.Config.LoadTimestamp = &t
+	}
+	status.Config.Clouds = len(config.GetClouds())
+	if t, err := config.LastLoadError(); err != nil {
+		t = t.UTC()
+		status.Config.Error = err.Error()
+		status.Config.ErrorTimestamp = &t
+	}
+
+	if replicas, err := watcher.Replicas(); err == nil {
+		status.Replicas = replicas
+	}
+
+	status.Ready = len(notReady(status)) == 0
+	status.Warnings = degraded(status)
+	status.Degraded = len(status.Warnings) > 0
+	return status
+}
+
+// degraded returns the reasons why the scheduler serves the requests with a possibly
+// outdated config, none if the config is up-to-date.
+func degraded(status v1.Status) []string {
+	warnings := []string{}
+	if status.Git.LastPullError != "" {
+		warnings = append(warnings, "the config repository can't be pulled: "+status.Git.LastPullError)
+	}
+	if status.Config.Error != "" {
+		warnings = append(warnings, "the last config load failed, the previous config is kept")
+	}
+	diverged := []string{}
+	for _, r := range status.Replicas {
+		if r.Diverged {
+			diverged = append(diverged, r.Name)
+		}
+	}
+	if len(diverged) > 0 {
+		warnings = append(warnings, "replicas serve a different config revision: "+strings.Join(diverged, ", "))
+	}
+	return warnings
+}
+
+// notReady returns the reasons why the scheduler can't serve the requests, none if it's ready.
+func notReady(status v1.Status) []string {
+	reasons := []string{}
+	if status.Stopping {
+		reasons = append(reasons, "the scheduler is stopping")
+	}
+	if ! status.Redis.Connected {
+		reasons = append(reasons, "can't connect to redis")
+	}
+	if status.Config.Source == source.KindGit && ! status.Git.Cloned {
+		reasons = append(reasons, "the config repository is not cloned")
+	}
+	if ! status.Config.Loaded {
+		reasons = append(reasons, "the config is not loaded")
+	}
+	for _, s := range status.Subscriptions {
+		if ! s.Connected {
+			reasons = append(reasons, fmt.Sprintf("the %s %s is not connected", s.Kind, s.Name))
+		}
+	}
+	return reasons
+}
+
+// livezHandler returns OK as long as the process serves requests, even when it's stopping.
+func livezHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, "OK\n")
+}
+
+// readyzHandler returns OK if the scheduler can serve the requests, see notReady.
+func readyzHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain")
+	if reasons := notReady(getStatus()); len(reasons) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "ERROR: "+strings.Join(reasons, "\nERROR: ")+"\n")
+		return
+	}
+	io.WriteString(w, "OK\n")
+}
+
+func v1GetStatus(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	enc.Encode(getStatus())
+}
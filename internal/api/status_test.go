@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	setupConfig(t)
+
+	// Redis is not available in the tests
+	w := request(t, "GET", "/api/v1/status", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var status v1.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Ready || status.Redis.Connected || status.Redis.Error == "" {
+		t.Errorf("expected redis not to be connected, got %+v", status)
+	}
+	if ! status.Config.Loaded || status.Config.LoadTimestamp == nil || status.Config.Clouds != 2 {
+		t.Errorf("expected the config to be loaded with 2 clouds, got %+v", status.Config)
+	}
+
+	if w := request(t, "GET", "/readyz", ""); w.Code != http.StatusServiceUnavailable || ! strings.Contains(w.Body.String(), "ERROR: can't connect to redis") {
+		t.Errorf("unexpected readyz response %d %s", w.Code, w.Body.String())
+	}
+	if w := request(t, "GET", "/livez", ""); w.Code != http.StatusOK {
+		t.Errorf("unexpected livez response %d %s", w.Code, w.Body.String())
+	}
+	if w := request(t, "GET", "/metrics", ""); w.Code != http.StatusOK || ! strings.Contains(w.Body.String(), `agnostics_config_loads_total{result="success"}`) {
+		t.Errorf("unexpected metrics response %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNotReady(t *testing.T) {
+	ready := v1.Status{
+		Redis: v1.RedisStatus{Connected: true},
+		Git: v1.GitStatus{Cloned: true},
+		Config: v1.ConfigStatus{Loaded: true, Source: "git"},
+		Subscriptions: []v1.SubscriptionStatus{{Name: "repoMQ", Kind: "channel", Connected: true}},
+	}
+	if reasons := notReady(ready); len(reasons) != 0 {
+		t.Errorf("expected ready, got %v", reasons)
+	}
+
+	status := ready
+	status.Git.Cloned = false
+	status.Config.Source = "dir"
+	if reasons := notReady(status); len(reasons) != 0 {
+		t.Errorf("expected ready without the git repository, got %v", reasons)
+	}
+
+	status = ready
+	status.Git.LastPullError = "connection refused"
+	if reasons := notReady(status); len(reasons) != 0 {
+		t.Errorf("expected ready with an outdated config, got %v", reasons)
+	}
+	if warnings := degraded(status); len(warnings) != 1 || ! strings.Contains(warnings[0], "connection refused") {
+		t.Errorf("expected degraded, got %v", warnings)
+	}
+
+	status = ready
+	status.Replicas = []v1.ReplicaStatus{{Name: "scheduler-0"}, {Name: "scheduler-1", Diverged: true}}
+	if warnings := degraded(status); len(warnings) != 1 || ! strings.HasSuffix(warnings[0], ": scheduler-1") {
+		t.Errorf("expected scheduler-1 to diverge, got %v", warnings)
+	}
+
+	status = ready
+	status.Stopping = true
+	status.Subscriptions = []v1.SubscriptionStatus{{Name: "repoMQ", Kind: "channel"}}
+	reasons := notReady(status)
+	expected := []string{"the scheduler is stopping", "the channel repoMQ is not connected"}
+	if strings.Join(reasons, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, reasons)
+	}
+}
@@ -0,0 +1,225 @@
+package config
+
+import(
+	"fmt"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lastChanges is what the last reload changed, see LastChanges.
+var lastChanges struct {
+	sync.Mutex
+	changes *v1.ConfigChanges
+}
+
+// LastChanges returns what the last reload of this replica changed in the config,
+// nil if the config was only loaded at startup.
+func LastChanges() *v1.ConfigChanges {
+	lastChanges.Lock()
+	defer lastChanges.Unlock()
+	return lastChanges.changes
+}
+
+func setLastChanges(changes v1.ConfigChanges) {
+	lastChanges.Lock()
+	defer lastChanges.Unlock()
+	lastChanges.changes = &changes
+}
+
+// diffConfig returns what changed in the config from the snapshot from to the snapshot to:
+// the clouds of the files and the policy.
+func diffConfig(from *Snapshot, to *Snapshot) v1.ConfigChanges {
+	changes := v1.ConfigChanges{
+		From: from.Revision,
+		To: to.Revision,
+		CreationTimestamp: time.Now().UTC(),
+	}
+	for name, cloud := range to.fileClouds {
+		previous, ok := from.fileClouds[name]
+		if ! ok {
+			changes.CloudsAdded = append(changes.CloudsAdded, name)
+			continue
+		}
+		if c, changed := diffCloud(previous, cloud); changed {
+			changes.CloudsChanged = append(changes.CloudsChanged, c)
+		}
+	}
+	for name := range from.fileClouds {
+		if _, ok := to.fileClouds[name]; ! ok {
+			changes.CloudsRemoved = append(changes.CloudsRemoved, name)
+		}
+	}
+	sort.Strings(changes.CloudsAdded)
+	sort.Strings(changes.CloudsRemoved)
+	sort.Slice(changes.CloudsChanged, func(i, j int) bool { return changes.CloudsChanged[i].Name < changes.CloudsChanged[j].Name })
+
+	if p, changed := diffPolicy(from.Policy, to.Policy); changed {
+		changes.Policy = &p
+	}
+	return changes
+}
+
+// diffCloud returns what changed in the cloud, and false if nothing did.
+func diffCloud(from v1.Cloud, to v1.Cloud) (v1.CloudChanges, bool) {
+	c := v1.CloudChanges{
+		Name: to.Name,
+		Labels: diffValues(from.Labels, to.Labels),
+	}
+	if from.Enabled != to.Enabled {
+		enabled := to.Enabled
+		c.Enabled = &enabled
+	}
+	c.TaintsAdded = missingTaints(to.Taints, from.Taints)
+	c.TaintsRemoved = missingTaints(from.Taints, to.Taints)
+	changed := len(c.Labels) > 0 || c.Enabled != nil || len(c.TaintsAdded) > 0 || len(c.TaintsRemoved) > 0
+	return c, changed
+}
+
+// missingTaints returns the taints of a that aren't in b. The creation timestamps are ignored.
+func missingTaints(a []v1.Taint, b []v1.Taint) []v1.Taint {
+	var result []v1.Taint
+	for _, ta := range a {
+		found := false
+		for _, tb := range b {
+			if ta.Key == tb.Key && ta.Value == tb.Value && ta.Effect == tb.Effect {
+				found = true
+				break
+			}
+		}
+		if ! found {
+			result = append(result, ta)
+		}
+	}
+	return result
+}
+
+// diffPolicy returns what changed in the policy, and false if nothing did.
+func diffPolicy(from Policy, to Policy) (v1.PolicyChanges, bool) {
+	p := v1.PolicyChanges{}
+	predicates := func(policy Policy) map[string]bool {
+		result := map[string]bool{}
+		for _, predicate := range policy.Predicates {
+			result[predicate.Name] = true
+		}
+		return result
+	}
+	fromPredicates, toPredicates := predicates(from), predicates(to)
+	for name := range toPredicates {
+		if ! fromPredicates[name] {
+			p.PredicatesAdded = append(p.PredicatesAdded, name)
+		}
+	}
+	for name := range fromPredicates {
+		if ! toPredicates[name] {
+			p.PredicatesRemoved = append(p.PredicatesRemoved, name)
+		}
+	}
+	sort.Strings(p.PredicatesAdded)
+	sort.Strings(p.PredicatesRemoved)
+
+	weights := func(policy Policy) map[string]string {
+		result := map[string]string{}
+		for _, priority := range policy.Priorities {
+			result[priority.Name] = strconv.Itoa(priority.Weight)
+		}
+		return result
+	}
+	p.Priorities = diffValues(weights(from), weights(to))
+
+	changed := len(p.PredicatesAdded) > 0 || len(p.PredicatesRemoved) > 0 || len(p.Priorities) > 0
+	return p, changed
+}
+
+// diffValues returns the values added, removed or changed from the map from to the map to,
+// by key, nil if none did.
+func diffValues(from map[string]string, to map[string]string) map[string]v1.ValueChange {
+	var result map[string]v1.ValueChange
+	set := func(key string, c v1.ValueChange) {
+		if result == nil {
+			result = map[string]v1.ValueChange{}
+		}
+		result[key] = c
+	}
+	for key, value := range to {
+		value := value
+		previous, ok := from[key]
+		switch {
+		case ! ok:
+			set(key, v1.ValueChange{New: &value})
+		case previous != value:
+			set(key, v1.ValueChange{Old: &previous, New: &value})
+		}
+	}
+	for key, value := range from {
+		value := value
+		if _, ok := to[key]; ! ok {
+			set(key, v1.ValueChange{Old: &value})
+		}
+	}
+	return result
+}
+
+// describeChanges returns a line per change, for the log.
+func describeChanges(changes v1.ConfigChanges) []string {
+	lines := []string{}
+	for _, name := range changes.CloudsAdded {
+		lines = append(lines, "cloud "+name+" added")
+	}
+	for _, name := range changes.CloudsRemoved {
+		lines = append(lines, "cloud "+name+" removed")
+	}
+	for _, c := range changes.CloudsChanged {
+		lines = append(lines, describeValues("cloud "+c.Name+": label", c.Labels)...)
+		if c.Enabled != nil {
+			lines = append(lines, fmt.Sprintf("cloud %s: enabled set to %v", c.Name, *c.Enabled))
+		}
+		for _, t := range c.TaintsAdded {
+			lines = append(lines, fmt.Sprintf("cloud %s: taint %s=%s:%s added", c.Name, t.Key, t.Value, t.Effect))
+		}
+		for _, t := range c.TaintsRemoved {
+			lines = append(lines, fmt.Sprintf("cloud %s: taint %s=%s:%s removed", c.Name, t.Key, t.Value, t.Effect))
+		}
+	}
+	if p := changes.Policy; p != nil {
+		for _, name := range p.PredicatesAdded {
+			lines = append(lines, "policy: predicate "+name+" added")
+		}
+		for _, name := range p.PredicatesRemoved {
+			lines = append(lines, "policy: predicate "+name+" removed")
+		}
+		lines = append(lines, describeValues("policy: weight of the priority", p.Priorities)...)
+	}
+	return lines
+}
+
+// describeValues returns a line per change of the values, sorted by key.
+func describeValues(prefix string, values map[string]v1.ValueChange) []string {
+	lines := []string{}
+	for key, c := range values {
+		switch {
+		case c.Old == nil:
+			lines = append(lines, fmt.Sprintf("%s %s added: %s", prefix, key, *c.New))
+		case c.New == nil:
+			lines = append(lines, fmt.Sprintf("%s %s removed, was %s", prefix, key, *c.Old))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s changed from %s to %s", prefix, key, *c.Old, *c.New))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// logChanges logs what the reload changed in the config.
+func logChanges(changes v1.ConfigChanges) {
+	lines := describeChanges(changes)
+	if len(lines) == 0 {
+		log.Out.Println("Config reloaded, no change in the clouds or the policy")
+		return
+	}
+	log.Out.Println("Config reloaded, changes:\n  "+strings.Join(lines, "\n  "))
+}
@@ -0,0 +1,60 @@
+package config
+
+import(
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"strings"
+	"testing"
+)
+
+func TestLoadChanges(t *testing.T) {
+	log.InitLoggers(false)
+	if err := LoadFromDir(writeConfig(t, map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicates\npriorities:\n  - name: LabelPriorities\n    weight: 1\n",
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels:\n  region: na\n  purpose: dev\n",
+		"clouds/openstack-red.yml": "name: openstack-red\nlabels:\n  region: emea\n",
+		"clouds/openstack-green.yml": "name: openstack-green\nlabels:\n  region: apac\n",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFromDir(writeConfig(t, map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicates\n  - name: TaintPredicates\npriorities:\n  - name: LabelPriorities\n    weight: 2\n",
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels:\n  region: latam\n  cost: low\n",
+		"clouds/openstack-red.yml": "name: openstack-red\nenabled: false\nlabels:\n  region: emea\ntaints:\n  - key: maintenance\n    effect: NoSchedule\n",
+		"clouds/openstack-gold.yml": "name: openstack-gold\nlabels:\n  region: na\n",
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := LastChanges()
+	if changes == nil {
+		t.Fatal("expected the changes of the reload")
+	}
+	lines := describeChanges(*changes)
+	expected := []string{
+		"cloud openstack-gold added",
+		"cloud openstack-green removed",
+		"cloud openstack-blue: label cost added: low",
+		"cloud openstack-blue: label purpose removed, was dev",
+		"cloud openstack-blue: label region changed from na to latam",
+		"cloud openstack-red: enabled set to false",
+		"cloud openstack-red: taint maintenance=:NoSchedule added",
+		"policy: predicate TaintPredicates added",
+		"policy: weight of the priority LabelPriorities changed from 1 to 2",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected the changes\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+
+	// The same config again
+	dir := writeConfig(t, map[string]string{
+		"policy.yaml": "predicates: []\n",
+		"clouds/openstack-blue.yml": "name: openstack-blue\n",
+	})
+	LoadFromDir(dir)
+	if err := LoadFromDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if lines := describeChanges(*LastChanges()); len(lines) != 0 {
+		t.Errorf("expected no change, got %v", lines)
+	}
+}
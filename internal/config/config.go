@@ -1,21 +1,37 @@
 package config
 
 import(
+	"errors"
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"github.com/redhat-gpe/agnostics/internal/log"
-	"github.com/redhat-gpe/agnostics/internal/git"
 	"github.com/redhat-gpe/agnostics/internal/db"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/metrics"
+	"github.com/redhat-gpe/agnostics/internal/modules"
+	"github.com/redhat-gpe/agnostics/internal/source"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"path/filepath"
 	"path"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
-func loadClouds() map[string]v1.Cloud {
+// loadClouds reads the clouds from the files of the directory 'clouds'.
+// It returns the problems of all the invalid files, and the warnings: the clouds without name
+// or with the name of another cloud are ignored, the invalid static taints are kept.
+func loadClouds(dir string, unmarshal unmarshalFunc) (map[string]v1.Cloud, []string, []string) {
 	cloudFileList := []string{}
-	log.Debug.Println(filepath.Join(git.GetRepoDir(), "/clouds"))
-	err := filepath.Walk(filepath.Join(git.GetRepoDir(), "/clouds"),
+	cloudsDir := filepath.Join(dir, "/clouds")
+	log.Debug.Println(cloudsDir)
+	// The directory can be a link, like in a ConfigMap volume
+	root, err := filepath.EvalSymlinks(cloudsDir)
+	if err != nil {
+		return nil, []string{err.Error()}, nil
+	}
+	err = filepath.Walk(root,
 		func(p string, info os.FileInfo, err error) error {
 			if err != nil {
 				log.Err.Printf("%q: %v\n", p, err)
@@ -24,39 +40,64 @@ func loadClouds() map[string]v1.Cloud {
 
 			switch path.Ext(info.Name()) {
 			case ".yml", ".yaml":
+				if rel, err := filepath.Rel(root, p); err == nil {
+					p = filepath.Join(cloudsDir, rel)
+				}
 				cloudFileList = append(cloudFileList, p)
 			}
 			return nil
 		})
 
 	if err != nil {
-		log.Err.Fatal(err)
+		return nil, []string{err.Error()}, nil
 	}
 	log.Out.Printf("Found %d configuration files for clouds\n",  len(cloudFileList))
 
 	clouds := make(map[string]v1.Cloud)
+	files := make(map[string]string)
+	problems := []string{}
+	warnings := []string{}
 
 	for _, cloudFile := range(cloudFileList) {
+		file := relPath(dir, cloudFile)
 		content, err := ioutil.ReadFile(cloudFile)
 		if err != nil {
-			log.Err.Println("Error in loadClouds()")
-			log.Err.Fatal(err)
+			problems = append(problems, err.Error())
+			continue
 		}
 		cloud := v1.NewCloud()
-		err = yaml.Unmarshal(content, &cloud)
-		if err != nil {
-			log.Err.Println("Cannot read configuration of clouds.yml")
-			log.Err.Fatalf("Cannot unmarshal data: %v", err)
-		} else {
-			log.Debug.Printf("Found cloud %s (enabled=%v)\n", cloud.Name, cloud.Enabled)
-			clouds[cloud.Name] = cloud
+		if err := unmarshal(content, &cloud); err != nil {
+			problems = append(problems, unmarshalProblem(file, err))
+			continue
 		}
+		if cloud.Name == "" {
+			warnings = append(warnings, fmt.Sprintf("%s: missing 'name'", file))
+			continue
+		}
+		if other, ok := files[cloud.Name]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s: duplicate cloud name '%s', also in %s", file, cloud.Name, other))
+			continue
+		}
+		for _, t := range cloud.Taints {
+			if err := t.Validate(); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: invalid taint '%s': %v", file, t, err))
+			}
+		}
+		log.Debug.Printf("Found cloud %s (enabled=%v)\n", cloud.Name, cloud.Enabled)
+		clouds[cloud.Name] = cloud
+		files[cloud.Name] = file
 	}
 
-	return clouds
+	return clouds, problems, warnings
 }
 
-var clouds map[string]v1.Cloud
+// relPath returns the path of file relative to the config directory dir, for the errors.
+func relPath(dir string, file string) string {
+	if rel, err := filepath.Rel(dir, file); err == nil {
+		return rel
+	}
+	return file
+}
 
 type Policy struct {
 	Predicates []struct{
@@ -68,43 +109,159 @@ type Policy struct {
 	} `json:"priorities,omitempty"`
 }
 
-var policy Policy
-
-func loadPolicy() Policy {
+// loadPolicy reads the file 'policy.yaml'.
+// It returns the problems of the file, and the warnings: the unknown predicates and priorities.
+func loadPolicy(dir string, unmarshal unmarshalFunc) (Policy, []string, []string) {
 	result := Policy{}
-	functionName := "LoadPolicy:"
-	policyFile := filepath.Join(git.GetRepoDir(), "/policy.yaml")
+	policyFile := filepath.Join(dir, "/policy.yaml")
+	file := relPath(dir, policyFile)
 	log.Out.Println("Reading policy file", policyFile)
 	content, err := ioutil.ReadFile(policyFile)
 	if err != nil {
-		log.Err.Println("Error in", functionName)
-		log.Err.Fatal(err)
+		return result, []string{err.Error()}, nil
 	}
-	err = yaml.Unmarshal(content, &result)
-	if err != nil {
-		log.Err.Println("Cannot read configuration of policy.yaml")
-		log.Err.Fatalf("Cannot unmarshal data: %v", err)
-	} else {
-		log.Out.Printf("Found policy, %d predicates and %d priorities", len(result.Predicates), len(result.Priorities))
+	if err := unmarshal(content, &result); err != nil {
+		return result, []string{unmarshalProblem(file, err)}, nil
 	}
-	return result
+
+	warnings := []string{}
+	for _, p := range result.Predicates {
+		if ! contains(modules.Predicates, p.Name) {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown predicate '%s', must be one of %s", file, p.Name, strings.Join(modules.Predicates, ", ")))
+		}
+	}
+	for _, p := range result.Priorities {
+		if ! contains(modules.Priorities, p.Name) {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown priority '%s', must be one of %s", file, p.Name, strings.Join(modules.Priorities, ", ")))
+		}
+	}
+	log.Out.Printf("Found policy, %d predicates and %d priorities", len(result.Predicates), len(result.Priorities))
+	return result, nil, warnings
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // Public functions
 
-// Read the config from the local files and save in-memory
-func Load() {
-	policy = loadPolicy()
-	clouds = loadClouds()
-	db.ReloadAllTaints(clouds)
+// configSource is where the config is read from, see SetSource.
+var configSource source.Source
+
+// SetSource sets where Load reads the config from. It must be called before Load.
+func SetSource(s source.Source) {
+	configSource = s
+}
+
+// GetSource returns where the config is read from.
+func GetSource() source.Source {
+	return configSource
+}
+
+// Read the config from the local files of the source and save in-memory.
+// If the config is invalid, the previous config is kept and the error is returned.
+func Load() error {
+	if configSource == nil || configSource.Dir() == "" {
+		err := errors.New("the config source is not available")
+		log.Err.Println("Cannot load the config:", err)
+		setLoadFailed(err)
+		return err
+	}
+	return loadFromDir(configSource.Dir(), configSource.Revision())
+}
+
+// LoadFromDir reads the config from the directory dir and saves it in-memory.
+// If a file is invalid, the previous config is kept and the errors of all the files are returned.
+func LoadFromDir(dir string) error {
+	return loadFromDir(dir, "")
+}
+
+// loadFromDir is LoadFromDir, revision is the version of the files, see Snapshot.
+func loadFromDir(dir string, revision string) error {
+	newPolicy, newClouds, newWebhooks, problems, warnings := readDir(dir, yaml.Unmarshal)
+	if len(problems) > 0 {
+		err := errors.New(strings.Join(problems, "; "))
+		log.Err.Println("Invalid config, the previous config is kept:", err)
+		setLoadFailed(err)
+		return err
+	}
+	// Accepted by the previous versions: only 'scheduler validate' refuses them
+	for _, w := range warnings {
+		log.Err.Println("WARNING config:", w)
+	}
+
+	fileClouds := make(map[string]v1.Cloud, len(newClouds))
+	for name, c := range newClouds {
+		fileClouds[name] = c
+	}
+
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	db.ReloadAllTaints(newClouds)
+	next := &Snapshot{Revision: revision, Policy: newPolicy, Clouds: newClouds, Webhooks: newWebhooks, fileClouds: fileClouds}
+	previous := Current()
+	current.Store(next)
+	// Nothing to compare to at startup
+	if ! LoadTimestamp().IsZero() {
+		changes := diffConfig(previous, next)
+		logChanges(changes)
+		setLastChanges(changes)
+	}
+	setLoaded(time.Now())
+	return nil
+}
+
+// Metrics of the config loads
+var (
+	loadsTotal = metrics.NewCounter("agnostics_config_loads_total", "The number of config loads, by result: success or failure.", "result")
+	lastLoadSuccess = metrics.NewGauge("agnostics_config_last_load_success", "1 if the last config load succeeded, 0 if the previous config is kept.")
+	lastLoadSuccessTimestamp = metrics.NewGauge("agnostics_config_last_load_success_timestamp_seconds", "The time of the last successful config load, in seconds since the epoch.")
+)
+
+// loadStatus is the result of the config loads, see LoadTimestamp and LastLoadError.
+var loadStatus struct {
+	sync.Mutex
+	loaded time.Time
+	err error
+	failed time.Time
+}
+
+func setLoaded(t time.Time) {
+	loadStatus.Lock()
+	defer loadStatus.Unlock()
+	loadStatus.loaded = t
+	loadStatus.err = nil
+	loadStatus.failed = time.Time{}
+	loadsTotal.Inc("success")
+	lastLoadSuccess.Set(1)
+	lastLoadSuccessTimestamp.Set(float64(t.Unix()))
+}
+
+func setLoadFailed(err error) {
+	loadStatus.Lock()
+	defer loadStatus.Unlock()
+	loadStatus.err = err
+	loadStatus.failed = time.Now()
+	loadsTotal.Inc("failure")
+	lastLoadSuccess.Set(0)
 }
 
-// GetClouds Returns the in-memory list of clouds (v1)
-func GetClouds() map[string]v1.Cloud {
-	return clouds
+// LoadTimestamp returns when the config was last loaded successfully, zero if it never was.
+func LoadTimestamp() time.Time {
+	loadStatus.Lock()
+	defer loadStatus.Unlock()
+	return loadStatus.loaded
 }
 
-// GetPolicy returns the in-memory policy
-func GetPolicy() Policy {
-	return policy
+// LastLoadError returns when the last load failed and its error.
+// The error is nil and the time is zero if the last load succeeded.
+func LastLoadError() (time.Time, error) {
+	loadStatus.Lock()
+	defer loadStatus.Unlock()
+	return loadStatus.failed, loadStatus.err
 }
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes the files of a config repository in a temporary directory.
+func writeConfig(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "clouds"), 0755)
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadKeepsLastGoodConfig(t *testing.T) {
+	log.InitLoggers(false)
+	policy := "predicates:\n  - name: LabelPredicates\n"
+
+	good := writeConfig(t, map[string]string{
+		"policy.yaml": policy,
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels:\n  region: na\n",
+	})
+	if err := LoadFromDir(good); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadTimestamp()
+	if loaded.IsZero() {
+		t.Fatal("expected the load timestamp to be set")
+	}
+
+	bad := writeConfig(t, map[string]string{
+		"policy.yaml": policy,
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels: [\n",
+		"clouds/openstack-red.yml": "name: openstack-red\n",
+		"webhooks.yaml": "name: not-a-list\n",
+	})
+	err := LoadFromDir(bad)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	// All the invalid files are reported
+	for _, expected := range []string{"clouds/openstack-blue.yml", "webhooks.yaml"} {
+		if ! strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %s in the error, got %v", expected, err)
+		}
+	}
+	if _, ok := GetClouds()["openstack-blue"]; ! ok || len(GetClouds()) != 1 {
+		t.Errorf("expected the previous clouds to be kept, got %v", GetClouds())
+	}
+	if failed, lastErr := LastLoadError(); lastErr != err || failed.IsZero() {
+		t.Errorf("expected the last load error, got %v at %v", lastErr, failed)
+	}
+	if ! LoadTimestamp().Equal(loaded) {
+		t.Error("expected the load timestamp not to change")
+	}
+
+	// A missing policy is an error
+	if err := LoadFromDir(writeConfig(t, map[string]string{})); err == nil {
+		t.Error("expected an error without policy.yaml")
+	}
+
+	if err := LoadFromDir(good); err != nil {
+		t.Fatal(err)
+	}
+	if _, lastErr := LastLoadError(); lastErr != nil {
+		t.Errorf("expected the error to be cleared, got %v", lastErr)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	log.InitLoggers(false)
+
+	dir := writeConfig(t, map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicate\npriorities:\n  - name: TaintPriorities\n    weight: 1\n  - name: Unknown\n",
+		"clouds/a.yml": "name: openstack-blue\nregion: na\n",
+		"clouds/b.yml": "name: openstack-red\ntaints:\n  - key: maintenance\n    effect: Nope\n",
+		"clouds/c.yml": "name: openstack-red\n",
+		"clouds/d.yml": "labels:\n  region: na\n",
+		"webhooks.yaml": "- name: chat\n- name: chat\n  url: https://chat.example.com\n- name: ftp\n  url: ftp://files.example.com\n- name: audit\n  url: https://audit.example.com\n  events: [taint.added, cloud.deleted]\n",
+	})
+	problems := Validate(dir)
+	// The problems, then the warnings
+	expected := []string{
+		"clouds/a.yml: cannot unmarshal data: yaml: unmarshal errors: line 2: field region not found in type v1.Cloud",
+		"webhooks.yaml: webhook 0 must have 'name' and 'url'",
+		"webhooks.yaml: webhook 'ftp': Webhook must have a valid 'url' (http or https).",
+		"webhooks.yaml: webhook 'audit': Unknown event type 'cloud.deleted'. Must be one of: config.reloaded, config.load_failed, taint.added, taint.removed, placement.created, placement.deleted",
+		"policy.yaml: unknown predicate 'LabelPredicate', must be one of LabelPredicates, TaintPredicates",
+		"policy.yaml: unknown priority 'Unknown', must be one of LabelPriorities, TaintPriorities",
+		"clouds/b.yml: invalid taint 'maintenance:Nope': Taint.effect must be 'NoSchedule' or 'PreferNoSchedule'.",
+		"clouds/c.yml: duplicate cloud name 'openstack-red', also in clouds/b.yml",
+		"clouds/d.yml: missing 'name'",
+	}
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+
+	// The unknown fields are ignored when loading
+	valid := writeConfig(t, map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicates\n",
+		"clouds/a.yml": "name: openstack-blue\nregion: na\n",
+	})
+	if problems := Validate(valid); len(problems) != 1 {
+		t.Errorf("expected the unknown field to be reported, got %v", problems)
+	}
+	if err := LoadFromDir(valid); err != nil {
+		t.Errorf("expected the unknown field to be ignored, got %v", err)
+	}
+
+	// The warnings are only logged when loading, like before 'scheduler validate'
+	warned := writeConfig(t, map[string]string{
+		"policy.yaml": "predicates:\n  - name: LabelPredicate\n",
+		"clouds/b.yml": "name: openstack-red\ntaints:\n  - key: maintenance\n    effect: Nope\n",
+		"clouds/c.yml": "name: openstack-red\n",
+		"clouds/d.yml": "labels:\n  region: na\n",
+	})
+	if problems := Validate(warned); len(problems) != 4 {
+		t.Errorf("expected the warnings to be reported, got %v", problems)
+	}
+	if err := LoadFromDir(warned); err != nil {
+		t.Fatalf("expected the warnings to be ignored, got %v", err)
+	}
+	if _, ok := GetClouds()["openstack-red"]; ! ok || len(GetClouds()) != 1 {
+		t.Errorf("expected the first cloud openstack-red only, got %v", GetClouds())
+	}
+}
+
+// TestLoadLinkedClouds checks the layout of a ConfigMap volume, where 'clouds' is a link.
+func TestLoadLinkedClouds(t *testing.T) {
+	log.InitLoggers(false)
+	data := writeConfig(t, map[string]string{
+		"policy.yaml": "predicates: []\n",
+		"clouds/openstack-blue.yml": "name: openstack-blue\nlabels: [\n",
+	})
+	dir := t.TempDir()
+	for _, name := range []string{"policy.yaml", "clouds"} {
+		if err := os.Symlink(filepath.Join(data, name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, _, problems, _ := readDir(dir, yaml.Unmarshal)
+	if len(problems) != 1 || ! strings.HasPrefix(problems[0], "clouds/openstack-blue.yml:") {
+		t.Errorf("expected the cloud to be read through the link, got %v", problems)
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import(
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"sync"
+	"sync/atomic"
+)
+
+// Snapshot is a version of the in-memory config. It's immutable: a reload or a taint
+// update swaps a new snapshot, so the readers always see a consistent config
+// without locking, even if they use it for a while, like the scheduling.
+type Snapshot struct {
+	// Revision is the version of the config source the config was loaded from,
+	// for example the commit hash, see source.Source.
+	Revision string
+	Policy Policy
+	// Clouds by name, with their current taints. The map and the clouds must not be modified.
+	Clouds map[string]v1.Cloud
+	// Webhooks defined in the config repository.
+	Webhooks []v1.Webhook
+	// fileClouds are the clouds as defined in the files, with their static taints, see diffConfig.
+	fileClouds map[string]v1.Cloud
+}
+
+// current is the *Snapshot in use, see Current.
+var current atomic.Value
+
+// writeMutex serializes the changes of the snapshot, so no update is lost.
+var writeMutex sync.Mutex
+
+func init() {
+	current.Store(&Snapshot{Clouds: map[string]v1.Cloud{}})
+}
+
+// Current returns the config in use. It's empty until the config is loaded.
+func Current() *Snapshot {
+	return current.Load().(*Snapshot)
+}
+
+// withClouds returns a copy of the snapshot with the clouds.
+func (s *Snapshot) withClouds(clouds map[string]v1.Cloud) *Snapshot {
+	result := *s
+	result.Clouds = clouds
+	return &result
+}
+
+// copyClouds returns a copy of the map of the clouds, that can be modified.
+func (s *Snapshot) copyClouds() map[string]v1.Cloud {
+	clouds := make(map[string]v1.Cloud, len(s.Clouds))
+	for name, c := range s.Clouds {
+		clouds[name] = c
+	}
+	return clouds
+}
+
+// ReloadTaints reads the taints of all the clouds from the database,
+// for example when another replica changed them.
+func ReloadTaints() {
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	s := Current()
+	clouds := s.copyClouds()
+	db.ReloadAllTaints(clouds)
+	current.Store(s.withClouds(clouds))
+}
+
+// GetClouds returns the in-memory clouds (v1). The map must not be modified.
+func GetClouds() map[string]v1.Cloud {
+	return Current().Clouds
+}
+
+// GetPolicy returns the in-memory policy
+func GetPolicy() Policy {
+	return Current().Policy
+}
+
+// GetWebhooks returns the in-memory list of the webhooks defined in the config repository.
+func GetWebhooks() []v1.Webhook {
+	return Current().Webhooks
+}
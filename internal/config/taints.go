@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/events"
+)
+
+// ErrCloudNotFound is returned when the cloud is not part of the in-memory config.
+var ErrCloudNotFound = errors.New("Cloud Not Found.")
+
+// ErrCloudHasNoTaint is returned when trying to remove a taint from a cloud that has none.
+var ErrCloudHasNoTaint = errors.New("cloud has no taint")
+
+// ErrTaintNotFound is returned by UntaintCloudByKey when no taint matches.
+var ErrTaintNotFound = errors.New("Taint not found")
+
+// ErrTaintIndexOutOfRange is returned by UntaintCloudByIndex when the index doesn't exist.
+var ErrTaintIndexOutOfRange = errors.New("Taint index out of range")
+
+// SaveTaints saves the taints of the cloud in the database. It's replaced in the tests, without redis.
+var SaveTaints = db.SaveTaints
+
+// updateTaints replaces the taints of the cloud name by the result of change,
+// in-memory and in the database, and publishes the corresponding events.
+// change gets the current cloud and returns its new taints, or an error to keep them.
+// The updates are serialized, so no concurrent update is lost.
+// Callers are responsible for requesting a taint sync to the other replicas.
+func updateTaints(name string, change func(v1.Cloud) ([]v1.Taint, error)) (v1.Cloud, error) {
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	s := Current()
+	cloud, ok := s.Clouds[name]
+	if !ok {
+		return v1.Cloud{}, ErrCloudNotFound
+	}
+	taints, err := change(cloud)
+	if err != nil {
+		return cloud, err
+	}
+
+	previous := cloud.Taints
+	cloud.Taints = taints
+	if err := SaveTaints(cloud); err != nil {
+		return cloud, err
+	}
+	// Copy on write, the current snapshot may be in use
+	clouds := s.copyClouds()
+	clouds[name] = cloud
+	current.Store(s.withClouds(clouds))
+	publishTaintEvents(cloud, previous)
+	return cloud, nil
+}
+
+// containsTaint returns true if a taint with the same key, value and effect is in taints.
+func containsTaint(taints []v1.Taint, t v1.Taint) bool {
+	for _, taint := range taints {
+		if taint.MatchTaint(t) && taint.Value == t.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// publishTaintEvents publishes an event for each taint added or removed from the cloud.
+func publishTaintEvents(cloud v1.Cloud, previous []v1.Taint) {
+	for i := range cloud.Taints {
+		if !containsTaint(previous, cloud.Taints[i]) {
+			e := events.New(v1.EventTaintAdded)
+			e.Cloud = &cloud
+			e.Taint = &cloud.Taints[i]
+			events.Publish(e)
+		}
+	}
+	for i := range previous {
+		if !containsTaint(cloud.Taints, previous[i]) {
+			e := events.New(v1.EventTaintRemoved)
+			e.Cloud = &cloud
+			e.Taint = &previous[i]
+			events.Publish(e)
+		}
+	}
+}
+
+// TaintCloud adds the taint t to the cloud. If a taint with the same key:effect
+// already exists, it's replaced.
+func TaintCloud(name string, t v1.Taint) (v1.Cloud, error) {
+	if err := t.Validate(); err != nil {
+		return v1.Cloud{}, err
+	}
+	return updateTaints(name, func(cloud v1.Cloud) ([]v1.Taint, error) {
+		// Work on a copy, the taints of the cloud are shared with the current snapshot
+		cloud.Taints = append([]v1.Taint{}, cloud.Taints...)
+		cloud.Taint(t)
+		return cloud.Taints, nil
+	})
+}
+
+// UntaintCloud removes all the taints of the cloud matching key:effect of t.
+func UntaintCloud(name string, t v1.Taint) (v1.Cloud, error) {
+	if err := t.Validate(); err != nil {
+		return v1.Cloud{}, err
+	}
+	return updateTaints(name, func(cloud v1.Cloud) ([]v1.Taint, error) {
+		if len(cloud.Taints) == 0 {
+			return nil, ErrCloudHasNoTaint
+		}
+
+		// Remove the taint, build new taint list
+		result := []v1.Taint{}
+		for _, taint := range cloud.Taints {
+			if !taint.MatchTaint(t) {
+				result = append(result, taint)
+			}
+		}
+		return result, nil
+	})
+}
+
+// UntaintCloudByKey removes all the taints of the cloud with the key.
+// If effect is not empty, only the taints with that effect are removed.
+func UntaintCloudByKey(name string, key string, effect string) (v1.Cloud, error) {
+	return updateTaints(name, func(cloud v1.Cloud) ([]v1.Taint, error) {
+		result := []v1.Taint{}
+		for _, taint := range cloud.Taints {
+			if taint.Key != key || (effect != "" && taint.Effect != effect) {
+				result = append(result, taint)
+			}
+		}
+		if len(result) == len(cloud.Taints) {
+			return nil, ErrTaintNotFound
+		}
+		return result, nil
+	})
+}
+
+// UntaintCloudByIndex removes the taint at position index in the list of taints of the cloud.
+func UntaintCloudByIndex(name string, index int) (v1.Cloud, error) {
+	return updateTaints(name, func(cloud v1.Cloud) ([]v1.Taint, error) {
+		if len(cloud.Taints) == 0 {
+			return nil, ErrCloudHasNoTaint
+		}
+		if index >= len(cloud.Taints) || index < 0 {
+			return nil, fmt.Errorf("%w (must be 0..%d)", ErrTaintIndexOutOfRange, len(cloud.Taints)-1)
+		}
+
+		result := []v1.Taint{}
+		result = append(result, cloud.Taints[:index]...)
+		result = append(result, cloud.Taints[index+1:]...)
+		return result, nil
+	})
+}
+
+// UntaintCloudAll removes all the taints of the cloud.
+func UntaintCloudAll(name string) (v1.Cloud, error) {
+	return updateTaints(name, func(cloud v1.Cloud) ([]v1.Taint, error) {
+		if len(cloud.Taints) == 0 {
+			return nil, ErrCloudHasNoTaint
+		}
+		return []v1.Taint{}, nil
+	})
+}
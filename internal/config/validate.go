@@ -0,0 +1,38 @@
+package config
+
+import(
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"strings"
+)
+
+// unmarshalFunc is yaml.Unmarshal, or yaml.UnmarshalStrict to also reject the unknown fields.
+type unmarshalFunc func([]byte, interface{}) error
+
+// unmarshalProblem returns the problem of a file that can't be unmarshaled, on one line.
+func unmarshalProblem(file string, err error) string {
+	return fmt.Sprintf("%s: cannot unmarshal data: %s", file, strings.Join(strings.Fields(err.Error()), " "))
+}
+
+// readDir reads all the files of the config in dir.
+// It returns the problems of all the files, the config is valid if there is none,
+// and the warnings, the problems of the configs loaded by the previous versions.
+func readDir(dir string, unmarshal unmarshalFunc) (Policy, map[string]v1.Cloud, []v1.Webhook, []string, []string) {
+	newPolicy, problems, warnings := loadPolicy(dir, unmarshal)
+	newClouds, cloudsProblems, cloudsWarnings := loadClouds(dir, unmarshal)
+	newWebhooks, webhooksProblems := loadWebhooks(dir, unmarshal)
+	problems = append(append(problems, cloudsProblems...), webhooksProblems...)
+	warnings = append(warnings, cloudsWarnings...)
+	return newPolicy, newClouds, newWebhooks, problems, warnings
+}
+
+// Validate checks the config in dir like Load, without saving it and without redis,
+// for example in the CI of the config repository.
+// The unknown fields and the warnings are also reported, while Load ignores the unknown fields
+// and only logs the warnings.
+// It returns all the problems found, none if the config is valid.
+func Validate(dir string) []string {
+	_, _, _, problems, warnings := readDir(dir, yaml.UnmarshalStrict)
+	return append(problems, warnings...)
+}
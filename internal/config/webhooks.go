@@ -0,0 +1,78 @@
+package config
+
+import(
+	"fmt"
+	"io/ioutil"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"path/filepath"
+	"os"
+)
+
+// webhookConfig is the format of a webhook in the file 'webhooks.yaml'
+// of the config repository.
+type webhookConfig struct {
+	Name string `yaml:"name"`
+	URL string `yaml:"url"`
+	Events []string `yaml:"events"`
+	// Secret is the HMAC secret. Prefer SecretEnv to avoid committing secrets.
+	Secret string `yaml:"secret"`
+	// SecretEnv is the name of the environment variable containing the HMAC secret.
+	SecretEnv string `yaml:"secret_env"`
+}
+
+// loadWebhooks reads the optional file 'webhooks.yaml' from the config repository.
+// It returns the problems of the file, like the webhooks without name, with an invalid URL
+// or an unknown event type.
+func loadWebhooks(dir string, unmarshal unmarshalFunc) ([]v1.Webhook, []string) {
+	result := []v1.Webhook{}
+	functionName := "loadWebhooks:"
+	webhooksFile := filepath.Join(dir, "/webhooks.yaml")
+	file := relPath(dir, webhooksFile)
+	content, err := ioutil.ReadFile(webhooksFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debug.Println(functionName, "no webhooks.yaml in config")
+			return result, nil
+		}
+		return nil, []string{err.Error()}
+	}
+
+	configs := []webhookConfig{}
+	if err := unmarshal(content, &configs); err != nil {
+		return nil, []string{unmarshalProblem(file, err)}
+	}
+
+	problems := []string{}
+	names := map[string]bool{}
+	for i, c := range configs {
+		if c.Name == "" || c.URL == "" {
+			problems = append(problems, fmt.Sprintf("%s: webhook %d must have 'name' and 'url'", file, i))
+			continue
+		}
+		if names[c.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate webhook name '%s'", file, c.Name))
+			continue
+		}
+		names[c.Name] = true
+		secret := c.Secret
+		if c.SecretEnv != "" {
+			secret = os.Getenv(c.SecretEnv)
+		}
+		w := v1.Webhook{
+			ID: "config-" + c.Name,
+			URL: c.URL,
+			Events: c.Events,
+			Secret: secret,
+			Source: "config",
+		}
+		// The same checks as the webhooks of the API
+		if err := w.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: webhook '%s': %v", file, c.Name, err))
+			continue
+		}
+		result = append(result, w)
+	}
+	log.Out.Printf("Found %d webhooks in config\n", len(result))
+	return result, problems
+}
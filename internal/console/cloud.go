@@ -0,0 +1,142 @@
+package console
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/placement"
+	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// recentPlacements is the number of placements displayed on the cloud page.
+const recentPlacements = 20
+
+// renderCloud displays the page of the cloud 'name'.
+// formError, if not empty, is displayed above the taint forms.
+func renderCloud(w http.ResponseWriter, name string, status int, formError string) {
+	w.Header().Set("Content-Type", "text/html")
+	cloud, ok := config.GetClouds()[name]
+	if ! ok {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Cloud Not Found.")
+		return
+	}
+
+	placements, err := placement.GetByCloud(name, recentPlacements)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "ERROR")
+		log.Err.Println(err)
+		return
+	}
+
+	type CloudData struct {
+		Cloud v1.Cloud
+		Placements []v1.Placement
+		Effects []string
+		Error string
+		// TaintForms is false if the forms are disabled, see NewServer.
+		TaintForms bool
+	}
+
+	t := parseTemplates()
+
+	w.WriteHeader(status)
+	t.ExecuteTemplate(w, "cloud.tmpl", CloudData{
+		Cloud: cloud,
+		Placements: placements,
+		Effects: []string{v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule},
+		Error: formError,
+		TaintForms: taintForms,
+	})
+}
+
+func getCloud(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	renderCloud(w, params.ByName("name"), http.StatusOK, "")
+}
+
+// taintFromForm builds the taint from the submitted form, same fields as the API.
+func taintFromForm(req *http.Request) v1.Taint {
+	t := v1.NewTaint()
+	t.Key = req.PostFormValue("key")
+	t.Value = req.PostFormValue("value")
+	t.Effect = req.PostFormValue("effect")
+	return t
+}
+
+// handleTaintResult redirects to the cloud page on success,
+// or displays the page with the error otherwise.
+func handleTaintResult(w http.ResponseWriter, req *http.Request, name string, err error) {
+	switch err {
+	case nil:
+		watcher.RequestTaintSync()
+		http.Redirect(w, req, "/clouds/"+url.PathEscape(name), http.StatusSeeOther)
+	case config.ErrCloudNotFound:
+		renderCloud(w, name, http.StatusNotFound, err.Error())
+	case v1.ErrTaintKeyEffectRequired, v1.ErrTaintInvalidEffect:
+		renderCloud(w, name, http.StatusBadRequest, err.Error())
+	case config.ErrCloudHasNoTaint:
+		renderCloud(w, name, http.StatusBadRequest, fmt.Sprintf("Cloud %s has no taint.", name))
+	default:
+		log.Err.Println("console taint", name, err)
+		renderCloud(w, name, http.StatusInternalServerError, "Error saving taints.")
+	}
+}
+
+// sameOrigin returns true if the form was submitted from a page of the console: the Origin
+// header, or the Referer if the browser doesn't send it, must be the host of the console.
+// It protects the forms changing the taints against the cross-site request forgery.
+func sameOrigin(req *http.Request) bool {
+	source := req.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = req.Header.Get("Referer")
+	}
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	return u.Host != "" && strings.EqualFold(u.Host, req.Host)
+}
+
+// refuseCrossSite replies 403 and returns true if the form wasn't submitted from the console.
+func refuseCrossSite(w http.ResponseWriter, req *http.Request) bool {
+	if sameOrigin(req) {
+		return false
+	}
+	log.Err.Println("console:", requestUser(req), "cross-site request refused", req.Method, req.URL.Path, "origin", req.Header.Get("Origin"), "referer", req.Header.Get("Referer"))
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusForbidden)
+	io.WriteString(w, "Cross-site request refused.\n")
+	return true
+}
+
+func postCloudTaint(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	if refuseCrossSite(w, req) {
+		return
+	}
+	name := params.ByName("name")
+	t := taintFromForm(req)
+	log.Out.Println("console:", requestUser(req), "taint cloud", name, t)
+	_, err := config.TaintCloud(name, t)
+	handleTaintResult(w, req, name, err)
+}
+
+func postCloudUntaint(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	if refuseCrossSite(w, req) {
+		return
+	}
+	name := params.ByName("name")
+	t := taintFromForm(req)
+	log.Out.Println("console:", requestUser(req), "untaint cloud", name, t)
+	_, err := config.UntaintCloud(name, t)
+	handleTaintResult(w, req, name, err)
+}
@@ -0,0 +1,100 @@
+package console
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/tg123/go-htpasswd"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	testCases := []struct {
+		name string
+		origin string
+		referer string
+		expected bool
+	}{
+		{"same origin", "https://console.example.com", "", true},
+		{"referer without origin", "", "https://console.example.com/clouds/openstack-blue", true},
+		{"opaque origin, same referer", "null", "https://console.example.com/clouds/openstack-blue", true},
+		{"other origin", "https://evil.example.org", "https://console.example.com/clouds/openstack-blue", false},
+		{"other referer", "", "https://evil.example.org/page", false},
+		{"other port", "https://console.example.com:8443", "", false},
+		{"no header", "", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "https://console.example.com/clouds/openstack-blue/taint", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.referer != "" {
+				req.Header.Set("Referer", tc.referer)
+			}
+			if sameOrigin(req) != tc.expected {
+				t.Errorf("expected sameOrigin to be %v", tc.expected)
+			}
+		})
+	}
+}
+
+func TestTaintFormsAuth(t *testing.T) {
+	log.InitLoggers(false)
+	sum := sha1.Sum([]byte("secret"))
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := ioutil.WriteFile(path, []byte("admin:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	myauth, err := htpasswd.New(path, htpasswd.DefaultSystems, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		auth *htpasswd.File
+		user string
+		password string
+		cert string
+		origin string
+		expected int
+	}{
+		{"no authentication configured", nil, "", "", "", "https://console.example.com", http.StatusNotFound},
+		{"anonymous", myauth, "", "", "", "https://console.example.com", http.StatusUnauthorized},
+		{"wrong password", myauth, "admin", "wrong", "", "https://console.example.com", http.StatusUnauthorized},
+		{"htpasswd user, cross-site", myauth, "admin", "secret", "", "https://evil.example.org", http.StatusForbidden},
+		// The cloud doesn't exist, the taint is refused after the authentication
+		{"htpasswd user", myauth, "admin", "secret", "", "https://console.example.com", http.StatusNotFound},
+		{"client certificate", myauth, "", "", "alice", "https://console.example.com", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newRouter(tc.auth)
+			for _, action := range []string{"taint", "untaint"} {
+				req := httptest.NewRequest("POST", "https://console.example.com/clouds/openstack-blue/"+action, nil)
+				req.Header.Set("Origin", tc.origin)
+				if tc.user != "" {
+					req.SetBasicAuth(tc.user, tc.password)
+				}
+				if tc.cert != "" {
+					req.TLS = &tls.ConnectionState{
+						VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: tc.cert}}}},
+					}
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != tc.expected {
+					t.Errorf("%s: expected %d, got %d %s", action, tc.expected, w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
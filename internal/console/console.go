@@ -1,15 +1,19 @@
 package console
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"gopkg.in/yaml.v2"
 	"github.com/julienschmidt/httprouter"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/api"
 	"github.com/redhat-gpe/agnostics/internal/config"
 	"github.com/redhat-gpe/agnostics/internal/git"
 	"github.com/redhat-gpe/agnostics/internal/log"
 	"github.com/redhat-gpe/agnostics/internal/placement"
+	"github.com/redhat-gpe/agnostics/internal/server"
 	"github.com/redhat-gpe/agnostics/internal/watcher"
+	"github.com/tg123/go-htpasswd"
 	"html/template"
 	"io"
 	"net/http"
@@ -40,6 +44,19 @@ func countPlacements(name string) string{
 	return reply
 }
 
+// parseTemplates loads all the templates from templateDir.
+func parseTemplates() *template.Template {
+	var fm = template.FuncMap{
+		"marshal": marshal,
+		"countPlacements": countPlacements,
+		"toYaml": toYaml,
+	}
+
+	return template.Must(
+		template.New("layout.tmpl").Funcs(fm).ParseGlob(
+			filepath.Join(templateDir,"/*.tmpl")))
+}
+
 func getDashboard(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	w.Header().Set("Content-Type", "text/html")
 	placements, err := placement.GetAll(100)
@@ -50,15 +67,9 @@ func getDashboard(w http.ResponseWriter, req *http.Request, params httprouter.Pa
 		return
 	}
 
-	var fm = template.FuncMap{
-		"marshal": marshal,
-		"countPlacements": countPlacements,
-		"toYaml": toYaml,
-	}
-
 	clouds := config.GetClouds()
 
-	commitInfo, _ := v1.NewGitCommit(git.GetRepo())
+	commitInfo, _ := git.NewGitCommit(git.GetRepo())
 
 	type HomeData struct {
 		Clouds map[string]v1.Cloud
@@ -66,9 +77,7 @@ func getDashboard(w http.ResponseWriter, req *http.Request, params httprouter.Pa
 		GitCommit v1.GitCommit
 	}
 
-	t := template.Must(
-		template.New("layout.tmpl").Funcs(fm).ParseGlob(
-			filepath.Join(templateDir,"/*.tmpl")))
+	t := parseTemplates()
 
 	t.ExecuteTemplate(w, "layout.tmpl", HomeData {
 		clouds,
@@ -85,22 +94,60 @@ func getReloadConfig(w http.ResponseWriter, req *http.Request, _ httprouter.Para
 
 func getConfig(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	w.Header().Set("Content-Type", "text/plain")
-	commitInfo, _ := v1.NewGitCommit(git.GetRepo())
+	commitInfo, _ := git.NewGitCommit(git.GetRepo())
 	io.WriteString(w, toYaml(commitInfo))
 }
 
 var templateDir string
 
 // Serve function is
-func Serve(t string, addr string) {
+// taintForms is true if the forms changing the taints are served, see NewServer.
+var taintForms bool
+
+// requestUser returns the authenticated user, or the common name of the client certificate, for the logs.
+func requestUser(req *http.Request) string {
+	if user, ok := api.UserFromContext(req.Context()); ok {
+		return user
+	}
+	if user, ok := server.CertificateUser(req); ok {
+		return user
+	}
+	return "anonymous"
+}
+
+// NewServer returns the server of the console on addr, with HTTPS if tlsConfig is not nil.
+// The forms changing the taints require the same authentication as the API: the users of auth,
+// or a verified client certificate. They are disabled if auth is nil.
+func NewServer(t string, addr string, tlsConfig *tls.Config, timeouts server.Timeouts, auth *htpasswd.File) *http.Server {
 	templateDir = t
+	router := newRouter(auth)
+
+	if tlsConfig != nil {
+		log.Out.Println("Console listen on port", addr, "(HTTPS)")
+	} else {
+		log.Out.Println("Console listen on port", addr)
+	}
+	return server.New(addr, router, tlsConfig, timeouts)
+}
+
+// newRouter returns the router of the console, see NewServer.
+func newRouter(auth *htpasswd.File) *httprouter.Router {
 	router := httprouter.New()
 
 	// Protected
 	router.GET("/", getDashboard)
 	router.GET("/get_config", getConfig)
 	router.GET("/reload_config", getReloadConfig)
-
-	log.Out.Println("Console listen on port", addr)
-	log.Err.Fatal(http.ListenAndServe(addr, router))
+	router.GET("/events", getEvents)
+	router.GET("/fragments/clouds", getCloudsFragment)
+	router.GET("/fragments/placements", getPlacementsFragment)
+	router.GET("/clouds/:name", getCloud)
+	taintForms = auth != nil
+	if taintForms {
+		router.POST("/clouds/:name/taint", api.BasicAuth(postCloudTaint, auth, true))
+		router.POST("/clouds/:name/untaint", api.BasicAuth(postCloudUntaint, auth, true))
+	} else {
+		log.Out.Println("Console taint forms disabled: no authentication configured")
+	}
+	return router
 }
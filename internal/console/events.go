@@ -0,0 +1,35 @@
+package console
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/events"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/placement"
+	"io"
+	"net/http"
+)
+
+// getEvents streams the events received by this replica using Server-Sent Events.
+func getEvents(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	events.ServeSSE(w, req, "", nil)
+}
+
+// getCloudsFragment renders the clouds table only, used by the dashboard to refresh it.
+func getCloudsFragment(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/html")
+	parseTemplates().ExecuteTemplate(w, "clouds.tmpl", config.GetClouds())
+}
+
+// getPlacementsFragment renders the placements table only, used by the dashboard to refresh it.
+func getPlacementsFragment(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/html")
+	placements, err := placement.GetAll(100)
+	if err != nil{
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "ERROR")
+		log.Err.Println(err)
+		return
+	}
+	parseTemplates().ExecuteTemplate(w, "placements.tmpl", placements)
+}
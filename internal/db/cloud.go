@@ -5,7 +5,7 @@ import(
 	"fmt"
 	"errors"
 	"github.com/redhat-gpe/agnostics/internal/log"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"github.com/gomodule/redigo/redis"
 )
 
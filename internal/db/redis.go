@@ -2,6 +2,7 @@ package db
 
 
 import (
+	"context"
 	"github.com/gomodule/redigo/redis"
 	"github.com/redhat-gpe/agnostics/internal/log"
 	"time"
@@ -9,15 +10,21 @@ import (
 )
 
 var redisURL string
+var connectTimeout time.Duration
 
 func InitContext(url string) {
 	redisURL = url
 }
 
+// SetConnectTimeout sets the maximum time to establish a connection. 0 means no timeout.
+func SetConnectTimeout(timeout time.Duration) {
+	connectTimeout = timeout
+}
+
 // Dial returns the redis.Conn.
 // The context must be set before with InitContext
 func Dial() (redis.Conn, error) {
-	conn, err := redis.DialURL(redisURL)
+	conn, err := redis.DialURL(redisURL, redis.DialConnectTimeout(connectTimeout))
 	if err != nil {
 		log.Err.Println(err)
 	}
@@ -36,18 +43,30 @@ func DialPubSub() (redis.PubSubConn, error) {
 // Reconnect calls Dial until the connection to redis is established.
 // This function is blocking and may never end.
 func Reconnect() redis.Conn {
+	conn, _ := ReconnectContext(context.Background())
+
+	return conn
+}
+
+// ReconnectContext is the same as Reconnect except it stops when ctx is done,
+// and returns the error of the context.
+func ReconnectContext(ctx context.Context) (redis.Conn, error) {
 	conn, err :=  Dial()
 	var wait float64 = 1
 	for ; err != nil ; conn, err = Dial() {
 		delay := (time.Duration)(math.Pow(2, wait)) * time.Second
 		log.Err.Println("Cannot connect to redis. Retrying in", delay, "seconds...")
-		time.Sleep(delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
 		if wait < 6 {
 			wait = wait + 1
 		}
 	}
 
-	return conn
+	return conn, nil
 }
 
 // ReconnectPubSub is the same as Reconnect except it returns a redis.PubSubConn connection.
@@ -56,3 +75,10 @@ func ReconnectPubSub() redis.PubSubConn {
 
 	return redis.PubSubConn{Conn: conn}
 }
+
+// ReconnectPubSubContext is the same as ReconnectContext except it returns a redis.PubSubConn connection.
+func ReconnectPubSubContext(ctx context.Context) (redis.PubSubConn, error) {
+	conn, err := ReconnectContext(ctx)
+
+	return redis.PubSubConn{Conn: conn}, err
+}
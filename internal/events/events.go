@@ -0,0 +1,358 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gomodule/redigo/redis"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stream is the redis stream the events are appended to.
+const Stream = "events"
+
+// StreamMaxLen is the approximate number of events kept in the stream.
+// Older events are trimmed and cannot be resumed from.
+const StreamMaxLen = 10000
+
+// subscriberBuffer is the number of events a subscriber can lag behind
+// before events are dropped for that subscriber.
+const subscriberBuffer = 64
+
+// ErrInvalidID is returned when an event ID is not a valid redis stream ID.
+var ErrInvalidID = errors.New("invalid event ID")
+
+// New is the constructor for an Event of type eventType.
+func New(eventType string) v1.Event {
+	return v1.Event{
+		Type: eventType,
+		CreationTimestamp: time.Now().UTC().Round(time.Second),
+	}
+}
+
+// Publish appends the event to the redis stream, so all the replicas receive it
+// and it survives restarts.
+func Publish(e v1.Event) error {
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis. Event", e.Type, "not published.")
+		return err
+	}
+	defer conn.Close()
+
+	// The ID is assigned by redis
+	e.ID = ""
+	jsonText, err := json.Marshal(e)
+	if err != nil {
+		log.Err.Println("events.Publish", err)
+		return err
+	}
+	id, err := redis.String(conn.Do("XADD", Stream, "MAXLEN", "~", StreamMaxLen, "*", "event", jsonText))
+	if err != nil {
+		log.Err.Println("events.Publish", err)
+		return err
+	}
+	log.Debug.Println("events.Publish", e.Type, id)
+	return nil
+}
+
+// parseStreamEntries converts the entries of a stream, as returned by XRANGE or by XREAD
+// for one stream, to events.
+func parseStreamEntries(reply interface{}) ([]v1.Event, error) {
+	result := []v1.Event{}
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return result, err
+	}
+	for _, entry := range entries {
+		fields, err := redis.Values(entry, nil)
+		if err != nil || len(fields) != 2 {
+			return result, errors.New("unexpected stream entry format")
+		}
+		id, err := redis.String(fields[0], nil)
+		if err != nil {
+			return result, err
+		}
+		kv, err := redis.StringMap(fields[1], nil)
+		if err != nil {
+			return result, err
+		}
+		var e v1.Event
+		if err := json.Unmarshal([]byte(kv["event"]), &e); err != nil {
+			log.Err.Println("events: cannot unmarshal event", id, err)
+			continue
+		}
+		e.ID = id
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// parseXRead converts the reply of XREAD on the events stream to events.
+// A nil reply means no new event.
+func parseXRead(reply interface{}) ([]v1.Event, error) {
+	if reply == nil {
+		return []v1.Event{}, nil
+	}
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return []v1.Event{}, err
+	}
+	result := []v1.Event{}
+	for _, stream := range streams {
+		s, err := redis.Values(stream, nil)
+		if err != nil || len(s) != 2 {
+			return result, errors.New("unexpected XREAD reply format")
+		}
+		events, err := parseStreamEntries(s[1])
+		if err != nil {
+			return result, err
+		}
+		result = append(result, events...)
+	}
+	return result, nil
+}
+
+// Read returns the events published after the event lastID, at most count events.
+// The call blocks up to 'block' if there is no event yet. block=0 means don't block.
+func Read(conn redis.Conn, lastID string, count int, block time.Duration) ([]v1.Event, error) {
+	args := redis.Args{"COUNT", count}
+	if block > 0 {
+		args = args.Add("BLOCK", int64(block/time.Millisecond))
+	}
+	args = args.Add("STREAMS", Stream, lastID)
+	reply, err := conn.Do("XREAD", args...)
+	if err != nil {
+		return []v1.Event{}, err
+	}
+	return parseXRead(reply)
+}
+
+// CreateGroup creates the consumer group on the stream, starting with the new events.
+// It does nothing if the group already exists.
+func CreateGroup(conn redis.Conn, group string) error {
+	_, err := conn.Do("XGROUP", "CREATE", Stream, group, "$", "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// ReadGroup returns the new events for the consumer of the group, at most count events.
+// Each event is delivered to only one consumer of the group.
+// The call blocks up to 'block' if there is no event yet.
+func ReadGroup(conn redis.Conn, group string, consumer string, count int, block time.Duration) ([]v1.Event, error) {
+	reply, err := conn.Do("XREADGROUP", "GROUP", group, consumer, "COUNT", count,
+		"BLOCK", int64(block/time.Millisecond), "STREAMS", Stream, ">")
+	if err != nil {
+		return []v1.Event{}, err
+	}
+	return parseXRead(reply)
+}
+
+// Ack acknowledges the event for the group, see XACK.
+func Ack(conn redis.Conn, group string, id string) error {
+	_, err := conn.Do("XACK", Stream, group, id)
+	return err
+}
+
+// Claim takes over for the consumer the events of the group pending for at least minIdle,
+// read by a consumer that stopped or crashed before acknowledging them, at most count events.
+// The events trimmed from the stream meanwhile, or invalid, are acknowledged.
+func Claim(conn redis.Conn, group string, consumer string, minIdle time.Duration, count int) ([]v1.Event, error) {
+	pending, err := redis.Values(conn.Do("XPENDING", Stream, group, "-", "+", count))
+	if err != nil {
+		return []v1.Event{}, err
+	}
+	ids := []string{}
+	for _, p := range pending {
+		fields, err := redis.Values(p, nil)
+		if err != nil || len(fields) != 4 {
+			return []v1.Event{}, errors.New("unexpected XPENDING reply format")
+		}
+		id, err := redis.String(fields[0], nil)
+		if err != nil {
+			return []v1.Event{}, err
+		}
+		idle, err := redis.Int64(fields[2], nil)
+		if err != nil {
+			return []v1.Event{}, err
+		}
+		if time.Duration(idle) * time.Millisecond >= minIdle {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return []v1.Event{}, nil
+	}
+
+	entries, err := redis.Values(conn.Do("XCLAIM", redis.Args{Stream, group, consumer, minIdle.Milliseconds()}.AddFlat(ids)...))
+	if err != nil {
+		return []v1.Event{}, err
+	}
+	result, invalid, err := parseXClaim(entries)
+	if err != nil {
+		return result, err
+	}
+	// They would be claimed again forever
+	for _, id := range invalid {
+		Ack(conn, group, id)
+	}
+	return result, nil
+}
+
+// parseXClaim converts the entries returned by XCLAIM to events. It also returns the IDs of the
+// entries that aren't events: trimmed from the stream, without field, or that can't be parsed.
+func parseXClaim(entries []interface{}) ([]v1.Event, []string, error) {
+	present := []interface{}{}
+	ids := []string{}
+	for _, entry := range entries {
+		fields, err := redis.Values(entry, nil)
+		if err != nil || len(fields) != 2 {
+			continue
+		}
+		id, err := redis.String(fields[0], nil)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		if fields[1] != nil {
+			present = append(present, entry)
+		}
+	}
+	result, err := parseStreamEntries(present)
+	if err != nil {
+		return result, nil, err
+	}
+	parsed := map[string]bool{}
+	for _, e := range result {
+		parsed[e.ID] = true
+	}
+	invalid := []string{}
+	for _, id := range ids {
+		if ! parsed[id] {
+			invalid = append(invalid, id)
+		}
+	}
+	return result, invalid, nil
+}
+
+// LastID returns the ID of the last event of the stream, or "0-0" if the stream is empty.
+func LastID(conn redis.Conn) (string, error) {
+	reply, err := conn.Do("XREVRANGE", Stream, "+", "-", "COUNT", 1)
+	if err != nil {
+		return "", err
+	}
+	events, err := parseStreamEntries(reply)
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		return "0-0", nil
+	}
+	return events[0].ID, nil
+}
+
+// parseID splits a stream ID '<milliseconds>-<sequence>' in its two parts.
+func parseID(id string) (uint64, uint64, error) {
+	parts := strings.SplitN(id, "-", 2)
+	ms, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidID
+	}
+	if len(parts) == 1 {
+		return ms, 0, nil
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidID
+	}
+	return ms, seq, nil
+}
+
+// ValidateID returns ErrInvalidID if id is not a valid stream ID.
+func ValidateID(id string) error {
+	_, _, err := parseID(id)
+	return err
+}
+
+// CompareIDs returns -1, 0 or 1 if the event ID a is before, equal or after the event ID b.
+// Invalid IDs are considered before any valid ID.
+func CompareIDs(a, b string) int {
+	aMs, aSeq, aErr := parseID(a)
+	bMs, bSeq, bErr := parseID(b)
+	switch {
+	case aErr != nil && bErr != nil:
+		return 0
+	case aErr != nil:
+		return -1
+	case bErr != nil:
+		return 1
+	case aMs < bMs || (aMs == bMs && aSeq < bSeq):
+		return -1
+	case aMs == bMs && aSeq == bSeq:
+		return 0
+	default:
+		return 1
+	}
+}
+
+var mutex sync.Mutex
+var subscribers = map[chan v1.Event]bool{}
+var closed bool
+
+// Subscribe returns a channel receiving all the events received by this replica.
+// The channel must be released with Unsubscribe.
+// After Close, the channel returned is already closed.
+func Subscribe() chan v1.Event {
+	mutex.Lock()
+	defer mutex.Unlock()
+	ch := make(chan v1.Event, subscriberBuffer)
+	if closed {
+		close(ch)
+		return ch
+	}
+	subscribers[ch] = true
+	return ch
+}
+
+// Unsubscribe stops sending events to ch and closes it.
+func Unsubscribe(ch chan v1.Event) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := subscribers[ch]; ok {
+		delete(subscribers, ch)
+		close(ch)
+	}
+}
+
+// Close closes the channels of all the subscribers, so the event streams end
+// when the scheduler stops.
+func Close() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	closed = true
+	for ch := range subscribers {
+		delete(subscribers, ch)
+		close(ch)
+	}
+}
+
+// Broadcast sends the event to all the local subscribers.
+// A subscriber that is too slow to consume its events misses the event.
+func Broadcast(e v1.Event) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Err.Println("events.Broadcast: subscriber too slow, event", e.Type, "dropped")
+		}
+	}
+}
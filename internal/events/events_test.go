@@ -0,0 +1,155 @@
+package events
+
+import (
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"strings"
+	"testing"
+)
+
+func TestBroadcast(t *testing.T) {
+	log.InitLoggers(false)
+	ch1 := Subscribe()
+	ch2 := Subscribe()
+	defer Unsubscribe(ch2)
+
+	Broadcast(New(v1.EventPlacementCreated))
+
+	for i, ch := range []chan v1.Event{ch1, ch2} {
+		e := <-ch
+		if e.Type != v1.EventPlacementCreated {
+			t.Errorf("[%d] expected event %s, got %s", i, v1.EventPlacementCreated, e.Type)
+		}
+	}
+
+	Unsubscribe(ch1)
+	if _, ok := <-ch1; ok {
+		t.Error("expected channel to be closed after Unsubscribe")
+	}
+
+	Broadcast(New(v1.EventPlacementDeleted))
+	if e := <-ch2; e.Type != v1.EventPlacementDeleted {
+		t.Errorf("expected event %s, got %s", v1.EventPlacementDeleted, e.Type)
+	}
+}
+
+func TestBroadcastSlowSubscriber(t *testing.T) {
+	log.InitLoggers(false)
+	ch := Subscribe()
+	defer Unsubscribe(ch)
+
+	// Broadcast must never block, even if the subscriber doesn't consume
+	for i := 0; i < subscriberBuffer*2; i++ {
+		Broadcast(New(v1.EventTaintAdded))
+	}
+	if len(ch) != subscriberBuffer {
+		t.Errorf("expected %d events buffered, got %d", subscriberBuffer, len(ch))
+	}
+}
+
+func TestCompareIDs(t *testing.T) {
+	testCases := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"1602688473021-0", "1602688473021-0", 0},
+		{"1602688473021-0", "1602688473021-1", -1},
+		{"1602688473021-10", "1602688473021-9", 1},
+		{"1602688473020-5", "1602688473021-0", -1},
+		{"1602688473021", "1602688473021-0", 0},
+		{"invalid", "1602688473021-0", -1},
+		{"1602688473021-0", "", 1},
+	}
+
+	for _, tc := range testCases {
+		if r := CompareIDs(tc.a, tc.b); r != tc.expected {
+			t.Errorf("CompareIDs(%q, %q): expected %d, got %d", tc.a, tc.b, tc.expected, r)
+		}
+	}
+}
+
+func TestParseXRead(t *testing.T) {
+	log.InitLoggers(false)
+	reply := []interface{}{
+		[]interface{}{
+			[]byte(Stream),
+			[]interface{}{
+				[]interface{}{
+					[]byte("1602688473021-0"),
+					[]interface{}{[]byte("event"), []byte(`{"type":"taint.added","taint":{"key":"foo","effect":"NoSchedule"}}`)},
+				},
+				[]interface{}{
+					[]byte("1602688473021-1"),
+					[]interface{}{[]byte("event"), []byte(`{"type":"placement.deleted"}`)},
+				},
+			},
+		},
+	}
+
+	result, err := parseXRead(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(result))
+	}
+	if result[0].ID != "1602688473021-0" || result[0].Type != v1.EventTaintAdded || result[0].Taint.Key != "foo" {
+		t.Errorf("unexpected first event %+v", result[0])
+	}
+	if result[1].ID != "1602688473021-1" || result[1].Type != v1.EventPlacementDeleted {
+		t.Errorf("unexpected second event %+v", result[1])
+	}
+
+	if result, err := parseXRead(nil); err != nil || len(result) != 0 {
+		t.Errorf("expected no event and no error for a nil reply, got %v %v", result, err)
+	}
+}
+
+func TestParseXClaim(t *testing.T) {
+	log.InitLoggers(false)
+	entries := []interface{}{
+		[]interface{}{
+			[]byte("1602688473021-0"),
+			[]interface{}{[]byte("event"), []byte(`{"type":"placement.created"}`)},
+		},
+		// Trimmed from the stream
+		[]interface{}{[]byte("1602688473021-1"), nil},
+		nil,
+		[]interface{}{
+			[]byte("1602688473021-2"),
+			[]interface{}{[]byte("event"), []byte(`not json`)},
+		},
+	}
+
+	result, invalid, err := parseXClaim(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].ID != "1602688473021-0" || result[0].Type != v1.EventPlacementCreated {
+		t.Errorf("expected the placement event, got %+v", result)
+	}
+	if strings.Join(invalid, ",") != "1602688473021-1,1602688473021-2" {
+		t.Errorf("expected the trimmed and the invalid events, got %v", invalid)
+	}
+}
+
+func TestClose(t *testing.T) {
+	defer func() {
+		mutex.Lock()
+		closed = false
+		mutex.Unlock()
+	}()
+
+	ch := Subscribe()
+	Close()
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed")
+	}
+	// Already closed
+	Unsubscribe(ch)
+
+	if _, ok := <-Subscribe(); ok {
+		t.Error("expected the channel to be closed after Close")
+	}
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/server"
+	"io"
+	"net/http"
+	"time"
+)
+
+// keepAliveInterval is the delay between two SSE comments sent to keep the connection open.
+const keepAliveInterval = 30 * time.Second
+
+// replayBatch is the number of events read at once from the stream when resuming.
+const replayBatch = 100
+
+// WriteSSE writes the event in the Server-Sent Events format.
+func WriteSSE(w io.Writer, e v1.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.ID != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", e.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
+
+// matchType returns true if the event type is in types. Empty types matches all events.
+func matchType(e v1.Event, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeSSE streams the events to the client using Server-Sent Events until the client disconnects.
+// If since is not empty, the events published after the event 'since' are sent first.
+// Only the events whose type is in types are sent, all events if types is empty.
+func ServeSSE(w http.ResponseWriter, req *http.Request, since string, types []string) {
+	flusher, ok := w.(http.Flusher)
+	if ! ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Streaming not supported")
+		return
+	}
+
+	// The stream lasts until the client disconnects
+	server.DisableWriteTimeout(req)
+
+	// Subscribe before replaying, so no event is missed in between.
+	ch := Subscribe()
+	defer Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	io.WriteString(w, ": connected\n\n")
+	flusher.Flush()
+
+	lastID := since
+	if since != "" {
+		conn, err := db.Dial()
+		if err != nil {
+			log.Err.Println("ServeSSE: cannot replay events", err)
+			return
+		}
+		for {
+			events, err := Read(conn, lastID, replayBatch, 0)
+			if err != nil {
+				log.Err.Println("ServeSSE: cannot replay events", err)
+				conn.Close()
+				return
+			}
+			for _, e := range events {
+				lastID = e.ID
+				if matchType(e, types) {
+					WriteSSE(w, e)
+				}
+			}
+			if len(events) < replayBatch {
+				break
+			}
+		}
+		conn.Close()
+		flusher.Flush()
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-ch:
+			if ! ok {
+				return
+			}
+			// Already sent during the replay
+			if lastID != "" && CompareIDs(e.ID, lastID) <= 0 {
+				continue
+			}
+			if ! matchType(e, types) {
+				continue
+			}
+			if err := WriteSSE(w, e); err != nil {
+				log.Debug.Println("ServeSSE", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CloneOptions are the options of CloneRepository.
+type CloneOptions struct {
+	// URL of the repository. SSH is assumed, unless it starts with 'http'.
+	URL string
+	// Branch or Tag to track. The default branch of the remote is used if both are empty.
+	Branch string
+	Tag string
+	// CacheDir is the directory of the clone, kept between the restarts.
+	// A temporary directory is used if it's empty.
+	CacheDir string
+
+	// SSHPrivateKey is the path of the private key, ~/.ssh/id_rsa if empty.
+	SSHPrivateKey string
+	// SSHKeyPassphrase decrypts the private key, if it's encrypted.
+	SSHKeyPassphrase string
+	// SSHKnownHosts is the path of the known_hosts file verifying the host key of the server.
+	// If empty, the files of the SSH_KNOWN_HOSTS environment variable are used,
+	// or ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts.
+	SSHKnownHosts string
+
+	// Username and Password authenticate to an HTTPS URL, if Password is set.
+	// Password can be a token.
+	Username string
+	Password string
+}
+
+// newAuth returns the authentication to the repository: the private key and the known hosts
+// for SSH, the basic authentication for HTTPS if a password is set, nil otherwise.
+func newAuth(o CloneOptions) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(o.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		if o.Password == "" {
+			log.Out.Println("Cloning (HTTP) anonymously")
+			return nil, nil
+		}
+		if endpoint.Protocol == "http" {
+			log.Err.Println("WARNING: the git password is sent in clear text, use an HTTPS URL")
+		}
+		log.Out.Println("Cloning (HTTP) as", o.Username)
+		return &githttp.BasicAuth{Username: o.Username, Password: o.Password}, nil
+
+	case "ssh":
+		keyFile := o.SSHPrivateKey
+		if keyFile == "" {
+			keyFile = filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa")
+		}
+		pem, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if o.SSHKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(o.SSHKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return nil, fmt.Errorf("%s is encrypted, see the 'git-ssh-key-passphrase' setting", keyFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", keyFile, err)
+		}
+
+		knownHosts := []string{}
+		if o.SSHKnownHosts != "" {
+			knownHosts = append(knownHosts, o.SSHKnownHosts)
+		}
+		// The connection fails if the host is unknown or its key doesn't match
+		hostKeyCallback, err := gitssh.NewKnownHostsCallback(knownHosts...)
+		if err != nil {
+			return nil, fmt.Errorf("known hosts: %v", err)
+		}
+
+		user := endpoint.User
+		if user == "" {
+			user = gitssh.DefaultUsername
+		}
+		log.Out.Println("Cloning (SSH) as", user, "using private key", keyFile)
+		return &gitssh.PublicKeys{
+			User: user,
+			Signer: signer,
+			HostKeyCallbackHelper: gitssh.HostKeyCallbackHelper{HostKeyCallback: hostKeyCallback},
+		}, nil
+	}
+	return nil, nil
+}
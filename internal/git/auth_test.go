@@ -0,0 +1,106 @@
+package git
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKey writes an RSA private key, encrypted if passphrase is set, and returns its path and its public key.
+func writeKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if passphrase != "" {
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "id_rsa")
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	public, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, public
+}
+
+func TestNewAuthHTTPS(t *testing.T) {
+	log.InitLoggers(false)
+	auth, err := newAuth(CloneOptions{URL: "https://gitlab.com/org/scheduler-config.git", Username: "oauth2"})
+	if err != nil || auth != nil {
+		t.Errorf("expected no authentication without password, got %v %v", auth, err)
+	}
+	auth, err = newAuth(CloneOptions{URL: "https://gitlab.com/org/scheduler-config.git", Username: "oauth2", Password: "token"})
+	if basic, ok := auth.(*githttp.BasicAuth); err != nil || ! ok || basic.Username != "oauth2" || basic.Password != "token" {
+		t.Errorf("expected basic authentication, got %v %v", auth, err)
+	}
+}
+
+func TestNewAuthSSH(t *testing.T) {
+	log.InitLoggers(false)
+	keyFile, _ := writeKey(t, "secret")
+	_, hostKey := writeKey(t, "")
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := ioutil.WriteFile(knownHosts, []byte(knownhosts.Line([]string{"github.com"}, hostKey)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := CloneOptions{
+		URL: "git@github.com:redhat-gpe/scheduler-config.git",
+		SSHPrivateKey: keyFile,
+		SSHKnownHosts: knownHosts,
+	}
+
+	if _, err := newAuth(o); err == nil || ! strings.Contains(err.Error(), "git-ssh-key-passphrase") {
+		t.Errorf("expected an error about the passphrase, got %v", err)
+	}
+	o.SSHKeyPassphrase = "wrong"
+	if _, err := newAuth(o); err == nil {
+		t.Error("expected an error with the wrong passphrase")
+	}
+
+	o.SSHKeyPassphrase = "secret"
+	auth, err := newAuth(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, ok := auth.(*gitssh.PublicKeys)
+	if ! ok || keys.User != "git" {
+		t.Fatalf("expected the keys of the user git, got %v", auth)
+	}
+
+	// Only the known host key is accepted
+	addr := &net.TCPAddr{IP: net.IPv4(140, 82, 121, 3), Port: 22}
+	if err := keys.HostKeyCallback("github.com:22", addr, hostKey); err != nil {
+		t.Errorf("expected the known host key to be accepted, got %v", err)
+	}
+	_, otherKey := writeKey(t, "")
+	if err := keys.HostKeyCallback("github.com:22", addr, otherKey); err == nil {
+		t.Error("expected a different host key to be refused")
+	}
+	if err := keys.HostKeyCallback("gitlab.com:22", addr, hostKey); err == nil {
+		t.Error("expected an unknown host to be refused")
+	}
+
+	// The user of an ssh:// URL
+	o.URL = "ssh://deploy@git.example.com:2222/scheduler-config.git"
+	if auth, err := newAuth(o); err != nil || auth.(*gitssh.PublicKeys).User != "deploy" {
+		t.Errorf("expected the user deploy, got %v %v", auth, err)
+	}
+}
@@ -1,7 +1,8 @@
-package v1
+package git
 
 import (
 	"github.com/go-git/go-git/v5"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"time"
 	"errors"
 )
@@ -10,27 +11,34 @@ import (
 var ErrGitRepoNoURLs = errors.New("Git repository has no remote URLs")
 
 // NewGitCommit constructor for GitCommit
-func NewGitCommit(configRepo *git.Repository) (GitCommit, error) {
+func NewGitCommit(configRepo *git.Repository) (v1.GitCommit, error) {
+	if configRepo == nil {
+		return v1.GitCommit{}, ErrNotCloned
+	}
+	repoLock.Lock()
+	defer repoLock.Unlock()
 	remote, err := configRepo.Remote("origin")
 	if err != nil {
-		return GitCommit{}, err
+		return v1.GitCommit{}, err
 	}
 
 	if len(remote.Config().URLs) == 0 {
-		return GitCommit{}, ErrGitRepoNoURLs
+		return v1.GitCommit{}, ErrGitRepoNoURLs
 	}
 	origin := remote.Config().URLs[0]
 
 	if rev, err := configRepo.ResolveRevision("HEAD") ; err == nil {
 		if head, err := configRepo.CommitObject(*rev) ; err == nil {
-			return GitCommit{
+			return v1.GitCommit{
 				Hash: head.Hash.String(),
 				Author: head.Author.Name,
 				Date: head.Author.When.UTC().Format(time.RFC3339),
 				Origin: origin,
+				Ref: TrackedRef(),
+				Pinned: Pinned() != "",
 			}, nil
 		}
-		return GitCommit{}, err
+		return v1.GitCommit{}, err
 	}
-	return GitCommit{}, err
+	return v1.GitCommit{}, err
 }
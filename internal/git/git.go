@@ -1,27 +1,54 @@
 package git
 
 import (
-	//"fmt"
-	"path/filepath"
+	"fmt"
 	"github.com/go-git/go-git/v5"
-	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 
 	"github.com/redhat-gpe/agnostics/internal/log"
-	"strings"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 	"errors"
+	"sync"
 )
 
 var configRepo *git.Repository
 var configRepoDir string
 var configRepoCloneOptions *git.CloneOptions
-var configRepoPullOptions *git.PullOptions
+var configRepoAuth transport.AuthMethod
+
+// trackedRef is the branch or the tag followed by the pulls, see TrackedRef.
+var trackedRef plumbing.ReferenceName
 
 var ErrUpdatedTooRecently = errors.New("updated too recently")
 
+// ErrPinned is returned by RefreshRepository when the config is pinned to a commit.
+var ErrPinned = errors.New("pinned to a commit")
+
+// ErrNotCloned is returned when the repository is not cloned yet.
+var ErrNotCloned = errors.New("the repository is not cloned")
+
+// ErrCommitNotFound is returned by Pin when the commit is not in the repository.
+var ErrCommitNotFound = errors.New("commit not found")
+
+// ErrCommitNotTracked is returned by UpdateTo and CheckPushed when the commit is not in the history
+// of the tracked ref anymore, for example after a force-push.
+var ErrCommitNotTracked = errors.New("not in the tracked ref")
+
+// ErrStaleCommit is returned by UpdateTo and CheckPushed when checking out the commit would roll
+// the config back: it's not the tip of the tracked ref and doesn't descend from HEAD.
+var ErrStaleCommit = errors.New("neither the tip of the tracked ref nor a descendant of HEAD")
+
+// repoLock serializes the operations on the repository and lastUpdated: the pulls of the watcher,
+// the polling and the handlers of the API run in different goroutines.
+var repoLock sync.Mutex
+
 // This function returns the current repository path as a string.
 func GetRepoDir() string {
 	return configRepoDir
@@ -36,6 +63,8 @@ func GetRepo() *git.Repository {
 // see https://pkg.go.dev/github.com/go-git/go-git/v5@v5.1.0/plumbing/object?tab=doc#Commit
 // Error is nil if OK.
 func GetRepoHeadCommit() (*gitobject.Commit, error) {
+	repoLock.Lock()
+	defer repoLock.Unlock()
 	if rev, err := configRepo.ResolveRevision("HEAD") ; err == nil {
 		if head, err := configRepo.CommitObject(*rev) ; err == nil {
 			return head, nil
@@ -49,89 +78,285 @@ func GetRepoHeadCommit() (*gitobject.Commit, error) {
 	}
 }
 
-func CloneRepository(url string, sshPrivateKey string) {
-	// Tempdir to clone the repository
-	dir, err := ioutil.TempDir("", "scheduler-config-")
+// TrackedRef returns the branch or the tag followed by the pulls,
+// for example 'refs/heads/main' or 'refs/tags/v1.2'.
+// It's empty if the repository is not cloned.
+func TrackedRef() string {
+	if configRepo == nil {
+		return ""
+	}
+	return trackedRef.String()
+}
+
+// fetchRefSpec returns the refspec fetching the tracked ref, and the local reference it's fetched to.
+func fetchRefSpec() (gitconfig.RefSpec, plumbing.ReferenceName) {
+	local := trackedRef
+	if trackedRef.IsBranch() {
+		local = plumbing.NewRemoteReferenceName("origin", trackedRef.Short())
+	}
+	return gitconfig.RefSpec("+" + trackedRef.String() + ":" + local.String()), local
+}
+
+// RemoteHead returns the hash of the tracked ref in the remote repository.
+// It only lists the references of the remote, like 'git ls-remote', nothing is fetched.
+func RemoteHead() (string, error) {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	remote, err := configRepo.Remote("origin")
 	if err != nil {
-		log.Err.Fatal(err)
+		return "", err
 	}
+	refs, err := remote.List(&git.ListOptions{Auth: configRepoAuth})
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range refs {
+		if ref.Name() == trackedRef {
+			return ref.Hash().String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in the remote repository", trackedRef)
+}
 
-	log.Debug.Println("temporary directory for cloning", dir)
+// RemoteMoved returns the hash of the tracked ref in the remote repository, and true if it's
+// not the commit checked out. The fetched ref is not compared: the remote can be force-pushed
+// back to a commit already fetched.
+func RemoteMoved() (string, bool, error) {
+	remote, err := RemoteHead()
+	if err != nil {
+		return "", false, err
+	}
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	head, err := configRepo.Head()
+	if err != nil {
+		return "", false, err
+	}
+	return remote, head.Hash().String() != remote, nil
+}
 
-	if url[0:4] != "http" {
-		log.Debug.Println("Assume SSH is used in the git URL")
+// CloneRepository clones the repository and checks out the branch or the tag of the options.
+// If the cache directory of the options contains a clone of the repository, it's reused
+// without fetching, so the scheduler can start when the remote is unreachable.
+func CloneRepository(o CloneOptions) {
+	var err error
+	configRepoAuth, err = newAuth(o)
+	if err != nil {
+		log.Err.Fatal(err)
+	}
 
-		// Setup auth
+	// Clones the repository into the given dir, just as a normal git clone does
+	configRepoCloneOptions = &git.CloneOptions{
+		URL: o.URL,
+		SingleBranch: true,
+		Auth: configRepoAuth,
+	}
+	switch {
+	case o.Branch != "":
+		configRepoCloneOptions.ReferenceName = plumbing.NewBranchReferenceName(o.Branch)
+	case o.Tag != "":
+		configRepoCloneOptions.ReferenceName = plumbing.NewTagReferenceName(o.Tag)
+	}
 
-		if sshPrivateKey == "" {
-			sshPrivateKey = filepath.Join(os.Getenv("HOME") + "/.ssh/id_rsa")
-		}
-		log.Out.Println("Cloning (SSH) using private key", sshPrivateKey)
+	// Set to HEAD by the clone if empty
+	ref := configRepoCloneOptions.ReferenceName
 
-		ss := strings.FieldsFunc(url, func(r rune) bool {
-			if r == '@' {
-				return true
-			}
-			return false
-		})
-		auth, err := gitssh.NewPublicKeysFromFile(ss[0], sshPrivateKey, "")
+	dir := o.CacheDir
+	if dir != "" {
+		ok, err := openCache(dir, o.URL, ref)
 		if err != nil {
 			log.Err.Fatal(err)
 		}
-		// Clones the repository into the given dir, just as a normal git clone does
-		configRepoCloneOptions = &git.CloneOptions{
-			URL: url,
-			SingleBranch: true,
-			Auth: auth,
-		}
-		configRepoPullOptions = &git.PullOptions{
-			SingleBranch: true,
-			Auth: auth,
+		if ok {
+			log.Out.Println("Reusing the clone of", dir, "tracking", trackedRef)
+			return
 		}
-		configRepo, err = git.PlainClone(dir, false, configRepoCloneOptions)
+	} else {
+		// Tempdir to clone the repository
+		dir, err = ioutil.TempDir("", "scheduler-config-")
 		if err != nil {
 			log.Err.Fatal(err)
 		}
-	} else {
-		// Clones the repository into the given dir, just as a normal git clone does
-		configRepoCloneOptions = &git.CloneOptions{
-			URL: url,
-			SingleBranch: true,
-		}
-		configRepoPullOptions = &git.PullOptions{
-			SingleBranch: true,
-		}
-		configRepo, err = git.PlainClone(dir, false, configRepoCloneOptions)
+		log.Debug.Println("temporary directory for cloning", dir)
+	}
+
+	configRepo, err = git.PlainClone(dir, false, configRepoCloneOptions)
+	if err != nil {
+		// Don't leave a partial clone in the cache
+		removeContent(dir)
+		log.Err.Fatal(err)
+	}
+	configRepoDir = dir
+
+	trackedRef = ref
+	if trackedRef == "" {
+		// The default branch of the remote
+		head, err := configRepo.Head()
 		if err != nil {
 			log.Err.Fatal(err)
 		}
+		trackedRef = head.Name()
+	}
+	log.Out.Println("Tracking", trackedRef)
+}
+
+// cached is true if the repository was reused from the cache directory, see Cached.
+var cached bool
+
+// Cached returns true if the repository was reused from the cache directory at startup,
+// instead of being cloned.
+func Cached() bool {
+	return cached
+}
+
+// openCache opens the clone of the repository of url in the cache directory dir, without fetching.
+// It returns false if dir doesn't contain a clone of the repository tracking ref, the default branch
+// if it's empty, after removing the clone of another repository or ref, and an error if dir
+// contains other files.
+func openCache(dir string, url string, ref plumbing.ReferenceName) (bool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, err
+	}
+	repo, err := git.PlainOpen(dir)
+	if err == git.ErrRepositoryNotExists {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return false, err
+		}
+		if len(entries) > 0 {
+			return false, fmt.Errorf("the cache directory %s is not empty and doesn't contain a clone", dir)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 || remote.Config().URLs[0] != url {
+		log.Out.Println("The cache directory", dir, "contains the clone of another repository, removing it")
+		return false, removeContent(dir)
 	}
+	cachedRef, defaultBranch := clonedRef(remote.Config())
+	if cachedRef == "HEAD" {
+		// The default branch, the only branch fetched by the clone
+		cachedRef = clonedBranch(repo)
+	}
+	if cachedRef == "" {
+		log.Out.Println("Cannot find the tracked ref in the cache directory", dir, "removing it")
+		return false, removeContent(dir)
+	}
+	// Else the scheduler would start from the commit of another branch or tag
+	if (ref == "" && ! defaultBranch) || (ref != "" && ref != cachedRef) {
+		log.Out.Println("The cache directory", dir, "contains a clone tracking", cachedRef, "removing it")
+		return false, removeContent(dir)
+	}
+	ref = cachedRef
+
+	configRepo = repo
 	configRepoDir = dir
+	trackedRef = ref
+	cached = true
+	return true, nil
+}
+
+// clonedRef returns the ref fetched by the clone of the remote, HEAD for the clone of the default
+// branch, and true in this case. It's empty if it's not found.
+func clonedRef(remote *gitconfig.RemoteConfig) (plumbing.ReferenceName, bool) {
+	for _, spec := range remote.Fetch {
+		if spec.Src() == "HEAD" {
+			return plumbing.HEAD, true
+		}
+	}
+	if len(remote.Fetch) != 1 {
+		return "", false
+	}
+	return plumbing.ReferenceName(remote.Fetch[0].Src()), false
+}
+
+// clonedBranch returns the branch of a single branch clone, empty if it's not found.
+func clonedBranch(repo *git.Repository) plumbing.ReferenceName {
+	refs, err := repo.References()
+	if err != nil {
+		return ""
+	}
+	defer refs.Close()
+	branches := []plumbing.ReferenceName{}
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		name := strings.TrimPrefix(ref.Name().String(), "refs/remotes/origin/")
+		if ref.Name().IsRemote() && name != ref.Name().String() && name != "HEAD" {
+			branches = append(branches, plumbing.NewBranchReferenceName(name))
+		}
+		return nil
+	})
+	if len(branches) != 1 {
+		return ""
+	}
+	return branches[0]
+}
+
+// removeContent removes the files of the directory dir, but not dir itself, which can be a volume.
+func removeContent(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// lastUpdated is when the repository was last updated, guarded by repoLock.
 var lastUpdated time.Time
 
+// lastPull is the result of the last pull, see LastPull.
+var lastPull struct {
+	sync.Mutex
+	at time.Time
+	err error
+}
+
+// LastPull returns when the repository was last pulled and the error of the pull,
+// nil if it succeeded or the repository was already up-to-date.
+// The time is zero if the repository was never pulled.
+func LastPull() (time.Time, error) {
+	lastPull.Lock()
+	defer lastPull.Unlock()
+	return lastPull.at, lastPull.err
+}
+
+func setLastPull(err error) {
+	lastPull.Lock()
+	defer lastPull.Unlock()
+	lastPull.at = time.Now()
+	lastPull.err = err
+}
+
 // This function refreshes the git Worktree containing the configuration of the scheduler.
-// It's basically running the equivalent of 'git pull'.
+// It's the equivalent of 'git fetch' and 'git reset --hard' to the tracked ref, so the
+// force-pushes are supported. It returns git.NoErrAlreadyUpToDate if HEAD didn't change,
+// and ErrPinned if the config is pinned to a commit, see Pin.
 func RefreshRepository() error {
+	repoLock.Lock()
+	defer repoLock.Unlock()
 	// Do not spam git pull. Allow only one pull every 10 seconds for this process.
 	if time.Now().Sub(lastUpdated) < 10 * time.Second {
 		log.Debug.Println("Git repo updated recently. Ignoring.")
 		return ErrUpdatedTooRecently
 	}
-	wt, err:= configRepo.Worktree()
-
-	if err != nil {
-		log.Err.Println(err)
-		return err
+	if Pinned() != "" {
+		log.Debug.Println("Git repo pinned to", Pinned(), "Ignoring.")
+		return ErrPinned
 	}
 
 	log.Debug.Println("Git repo updating...")
-	err = wt.Pull(configRepoPullOptions)
-
+	err := updateRepository()
 	if err != nil {
-		switch err.Error() {
-		case "already up-to-date":
+		switch err {
+		case git.NoErrAlreadyUpToDate:
 			log.Debug.Println("Git repo already up-to-date.")
 		default:
 			log.Err.Println(err)
@@ -139,6 +364,251 @@ func RefreshRepository() error {
 	}
 
 	lastUpdated = time.Now()
+	if err == git.NoErrAlreadyUpToDate {
+		setLastPull(nil)
+	} else {
+		setLastPull(err)
+	}
 
 	return err
 }
+
+// UpdateTo checks out the commit of the tracked ref, after a fetch, so all the replicas
+// serve the same commit. Unlike RefreshRepository, it's not limited to a pull every 10 seconds.
+// It returns git.NoErrAlreadyUpToDate if HEAD is already the commit, ErrPinned if the
+// config is pinned to a commit, see Pin, ErrCommitNotTracked if the commit was removed from the
+// tracked ref and ErrStaleCommit if the commit would roll the config back.
+func UpdateTo(hash string) error {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	if Pinned() != "" {
+		log.Debug.Println("Git repo pinned to", Pinned(), "Ignoring.")
+		return ErrPinned
+	}
+
+	log.Debug.Println("Git repo updating to", hash)
+	err := updateTo(plumbing.NewHash(hash))
+	if err != nil {
+		switch err {
+		case git.NoErrAlreadyUpToDate:
+			log.Debug.Println("Git repo already at", hash)
+		default:
+			log.Err.Println(err)
+		}
+	}
+
+	lastUpdated = time.Now()
+	if err == git.NoErrAlreadyUpToDate {
+		setLastPull(nil)
+	} else {
+		setLastPull(err)
+	}
+	return err
+}
+
+func updateTo(hash plumbing.Hash) error {
+	// Always fetched: a known commit can be an old one, requested again or out of order
+	tip, err := fetch()
+	if err != nil {
+		return err
+	}
+	if ! hasCommit(hash.String()) {
+		return fmt.Errorf("%s: %w", hash, ErrCommitNotFound)
+	}
+	head, err := configRepo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Hash() == hash && (head.Name() == trackedRef || ! trackedRef.IsBranch()) {
+		return git.NoErrAlreadyUpToDate
+	}
+	if err := checkUpdate(hash, head.Hash(), tip); err != nil {
+		return err
+	}
+	return checkout(hash)
+}
+
+// checkUpdate returns an error if the commit hash can't replace head: it must be in the history
+// of tip, the fetched commit of the tracked ref, else ErrCommitNotTracked is returned, and
+// be tip or descend from head, else ErrStaleCommit is returned.
+func checkUpdate(hash plumbing.Hash, head plumbing.Hash, tip plumbing.Hash) error {
+	if hash == tip {
+		return nil
+	}
+	ok, err := isAncestor(hash, tip)
+	if err != nil {
+		return err
+	}
+	if ! ok {
+		return fmt.Errorf("%s: %w", hash, ErrCommitNotTracked)
+	}
+	ok, err = isAncestor(head, hash)
+	if err != nil {
+		return err
+	}
+	if ! ok {
+		return fmt.Errorf("%s: %w", hash, ErrStaleCommit)
+	}
+	return nil
+}
+
+// isAncestor returns true if the commit a is the commit b or one of its ancestors.
+func isAncestor(a plumbing.Hash, b plumbing.Hash) (bool, error) {
+	ca, err := configRepo.CommitObject(a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := configRepo.CommitObject(b)
+	if err != nil {
+		return false, err
+	}
+	return ca.IsAncestor(cb)
+}
+
+// CheckPushed fetches the tracked ref and returns nil if the commit hash, for example the one
+// of a push, can be checked out: it's the fetched commit of the tracked ref or descends from HEAD.
+// A push delivered again, or out of order, returns ErrStaleCommit, and a commit removed from
+// the tracked ref by a force-push ErrCommitNotTracked.
+func CheckPushed(hash string) error {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	if configRepo == nil {
+		return ErrNotCloned
+	}
+	tip, err := fetch()
+	if err != nil {
+		return err
+	}
+	if ! hasCommit(hash) {
+		return fmt.Errorf("%s: %w", hash, ErrCommitNotFound)
+	}
+	head, err := configRepo.Head()
+	if err != nil {
+		return err
+	}
+	return checkUpdate(plumbing.NewHash(hash), head.Hash(), tip)
+}
+
+// fetch fetches the tracked ref and returns the commit it points to.
+func fetch() (plumbing.Hash, error) {
+	refSpec, local := fetchRefSpec()
+	err := configRepo.Fetch(&git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{refSpec},
+		Auth: configRepoAuth,
+		Tags: git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := configRepo.ResolveRevision(plumbing.Revision(local.String()))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return *hash, nil
+}
+
+// updateRepository checks out the commit of the tracked ref in the remote repository.
+func updateRepository() error {
+	hash, err := fetch()
+	if err != nil {
+		return err
+	}
+	head, err := configRepo.Head()
+	if err != nil {
+		return err
+	}
+	// HEAD is detached on a tag, and must be back on the branch after a pin
+	if head.Hash() == hash && (head.Name() == trackedRef || ! trackedRef.IsBranch()) {
+		return git.NoErrAlreadyUpToDate
+	}
+	return checkout(hash)
+}
+
+// checkout checks out the commit. The tracked branch is moved to the commit and checked out,
+// so HEAD stays on the branch, otherwise HEAD is detached, like for a tag or a pinned commit.
+func checkout(hash plumbing.Hash) error {
+	wt, err := configRepo.Worktree()
+	if err != nil {
+		return err
+	}
+	if trackedRef.IsBranch() && Pinned() == "" {
+		if err := configRepo.Storer.SetReference(plumbing.NewHashReference(trackedRef, hash)); err != nil {
+			return err
+		}
+		return wt.Checkout(&git.CheckoutOptions{Branch: trackedRef, Force: true})
+	}
+	return wt.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+}
+
+// pinned is the commit the config is pinned to, see Pin.
+var pinned struct {
+	sync.Mutex
+	hash string
+}
+
+// Pinned returns the commit the config is pinned to, empty if it's not pinned.
+func Pinned() string {
+	pinned.Lock()
+	defer pinned.Unlock()
+	return pinned.hash
+}
+
+func setPinned(hash string) {
+	pinned.Lock()
+	defer pinned.Unlock()
+	pinned.hash = hash
+}
+
+// HasCommit returns true if the commit is in the repository, fetched from the tracked ref.
+func HasCommit(hash string) bool {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	return hasCommit(hash)
+}
+
+func hasCommit(hash string) bool {
+	if configRepo == nil {
+		return false
+	}
+	_, err := configRepo.CommitObject(plumbing.NewHash(hash))
+	return err == nil
+}
+
+// Pin checks out the commit and stops the pulls until Unpin, to roll back the config
+// without changing the remote repository. The tracked ref is fetched if the commit is unknown.
+// It returns git.NoErrAlreadyUpToDate if the config is already pinned to the commit.
+func Pin(hash string) error {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	if Pinned() == hash {
+		return git.NoErrAlreadyUpToDate
+	}
+	if ! hasCommit(hash) {
+		if _, err := fetch(); err != nil {
+			return err
+		}
+		if ! hasCommit(hash) {
+			return ErrCommitNotFound
+		}
+	}
+	setPinned(hash)
+	if err := checkout(plumbing.NewHash(hash)); err != nil {
+		setPinned("")
+		return err
+	}
+	log.Out.Println("Git repo pinned to", hash)
+	return nil
+}
+
+// Unpin resumes the pulls of the tracked ref. The next RefreshRepository checks it out.
+func Unpin() {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	if Pinned() == "" {
+		return
+	}
+	setPinned("")
+	// Don't wait for the next pull to leave the pinned commit
+	lastUpdated = time.Time{}
+	log.Out.Println("Git repo unpinned, tracking", trackedRef)
+}
@@ -0,0 +1,409 @@
+package git
+
+import (
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitobject "github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commit adds a commit changing the file policy.yaml in the repository dir and returns its hash.
+func commit(t *testing.T, repo *git.Repository, dir string, content string) string {
+	if err := ioutil.WriteFile(filepath.Join(dir, "policy.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("policy.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("Update the policy", &git.CommitOptions{
+		Author: &gitobject.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+// cloneOrigin creates an origin repository with a commit and clones it like CloneRepository.
+// It returns the origin, its directory and the hash of the commit.
+func cloneOrigin(t *testing.T, ref plumbing.ReferenceName) (*git.Repository, string, string) {
+	log.InitLoggers(false)
+	origin := t.TempDir()
+	originRepo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commit(t, originRepo, origin, "predicates: []\n")
+	if ref.IsTag() {
+		if _, err := originRepo.CreateTag(ref.Short(), plumbing.NewHash(first), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configRepo, err = git.PlainClone(t.TempDir(), false, &git.CloneOptions{URL: origin, SingleBranch: true, ReferenceName: ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trackedRef = ref
+	lastUpdated = time.Time{}
+	t.Cleanup(func() {
+		configRepo = nil
+		setPinned("")
+	})
+	return originRepo, origin, first
+}
+
+// head returns the hash of the commit checked out.
+func head(t *testing.T) string {
+	commit, err := GetRepoHeadCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit.Hash.String()
+}
+
+func TestRemoteHead(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/heads/master")
+
+	if ref := TrackedRef(); ref != "refs/heads/master" {
+		t.Errorf("expected refs/heads/master, got %s", ref)
+	}
+	if head, err := RemoteHead(); err != nil || head != first {
+		t.Errorf("expected %s, got %s %v", first, head, err)
+	}
+
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	if head, err := RemoteHead(); err != nil || head != second {
+		t.Errorf("expected the new commit %s, got %s %v", second, head, err)
+	}
+	// Nothing is fetched
+	if local := head(t); local != first {
+		t.Errorf("expected the local HEAD to stay at %s, got %s", first, local)
+	}
+	if remote, moved, err := RemoteMoved(); err != nil || ! moved || remote != second {
+		t.Errorf("expected the remote to move to %s, got %s %v %v", second, remote, moved, err)
+	}
+}
+
+func TestRemoteMovedToKnownCommit(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/heads/master")
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	if err := UpdateTo(second); err != nil {
+		t.Fatal(err)
+	}
+	if _, moved, err := RemoteMoved(); err != nil || moved {
+		t.Errorf("expected the remote not to move, got %v %v", moved, err)
+	}
+
+	// Force-pushed back to the first commit, that's already in the clone
+	if err := originRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/master", plumbing.NewHash(first))); err != nil {
+		t.Fatal(err)
+	}
+	remote, moved, err := RemoteMoved()
+	if err != nil || ! moved || remote != first {
+		t.Fatalf("expected the remote to move to %s, got %s %v %v", first, remote, moved, err)
+	}
+	if err := UpdateTo(remote); err != nil || head(t) != first {
+		t.Errorf("expected HEAD to move to %s, got %v %s", first, err, head(t))
+	}
+	// The next poll finds nothing to pull
+	if _, moved, err := RemoteMoved(); err != nil || moved {
+		t.Errorf("expected the remote not to move after the pull, got %v %v", moved, err)
+	}
+}
+
+func TestRefreshTag(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/tags/v1")
+
+	if err := RefreshRepository(); err != git.NoErrAlreadyUpToDate {
+		t.Errorf("expected already up-to-date, got %v", err)
+	}
+
+	// A new commit on the branch doesn't move the tag
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	lastUpdated = time.Time{}
+	if err := RefreshRepository(); err != git.NoErrAlreadyUpToDate {
+		t.Errorf("expected already up-to-date, got %v", err)
+	}
+	if local := head(t); local != first {
+		t.Errorf("expected HEAD to stay at the tag %s, got %s", first, local)
+	}
+
+	// Moving the tag is pulled
+	if err := originRepo.DeleteTag("v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := originRepo.CreateTag("v1", plumbing.NewHash(second), nil); err != nil {
+		t.Fatal(err)
+	}
+	lastUpdated = time.Time{}
+	if err := RefreshRepository(); err != nil {
+		t.Errorf("expected the moved tag to be pulled, got %v", err)
+	}
+	if local := head(t); local != second {
+		t.Errorf("expected HEAD at %s, got %s", second, local)
+	}
+}
+
+func TestPin(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/heads/master")
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	if err := RefreshRepository(); err != nil {
+		t.Fatal(err)
+	}
+	if local := head(t); local != second {
+		t.Fatalf("expected HEAD at %s, got %s", second, local)
+	}
+
+	// Roll back
+	if err := Pin(first); err != nil {
+		t.Fatal(err)
+	}
+	if local := head(t); local != first || Pinned() != first {
+		t.Errorf("expected HEAD pinned at %s, got %s pinned %s", first, local, Pinned())
+	}
+	if err := Pin(first); err != git.NoErrAlreadyUpToDate {
+		t.Errorf("expected already up-to-date, got %v", err)
+	}
+	if err := Pin(strings.Repeat("0", 40)); err != ErrCommitNotFound {
+		t.Errorf("expected %v, got %v", ErrCommitNotFound, err)
+	}
+
+	// The pulls are ignored while pinned
+	third := commit(t, originRepo, origin, "predicates: []\npriorities: []\n")
+	lastUpdated = time.Time{}
+	if err := RefreshRepository(); err != ErrPinned {
+		t.Errorf("expected %v, got %v", ErrPinned, err)
+	}
+	if local := head(t); local != first {
+		t.Errorf("expected HEAD to stay at %s, got %s", first, local)
+	}
+
+	// A commit pushed after the last pull is fetched
+	if err := Pin(third); err != nil {
+		t.Fatal(err)
+	}
+
+	Unpin()
+	if err := RefreshRepository(); err != nil {
+		t.Fatal(err)
+	}
+	if local := head(t); local != third || Pinned() != "" {
+		t.Errorf("expected HEAD at %s after unpin, got %s pinned %s", third, local, Pinned())
+	}
+	if ref, err := configRepo.Head(); err != nil || ref.Name() != "refs/heads/master" {
+		t.Errorf("expected HEAD back on the branch, got %v %v", ref, err)
+	}
+}
+
+func TestUpdateTo(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/heads/master")
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	third := commit(t, originRepo, origin, "predicates: []\npriorities: []\n")
+
+	// The commit requested, not the latest one, and without waiting between the updates
+	if err := UpdateTo(second); err != nil {
+		t.Fatal(err)
+	}
+	if local := head(t); local != second {
+		t.Errorf("expected HEAD at %s, got %s", second, local)
+	}
+	if err := UpdateTo(second); err != git.NoErrAlreadyUpToDate {
+		t.Errorf("expected already up-to-date, got %v", err)
+	}
+	if err := UpdateTo(third); err != nil {
+		t.Fatal(err)
+	}
+	// An old commit, for example a push delivered again, doesn't roll the config back
+	if err := UpdateTo(first); ! errors.Is(err, ErrStaleCommit) || head(t) != third {
+		t.Errorf("expected %v and HEAD to stay at %s, got %v %s", ErrStaleCommit, third, err, head(t))
+	}
+	if ref, err := configRepo.Head(); err != nil || ref.Name() != "refs/heads/master" {
+		t.Errorf("expected HEAD on the branch, got %v %v", ref, err)
+	}
+	if err := UpdateTo(strings.Repeat("0", 40)); ! errors.Is(err, ErrCommitNotFound) {
+		t.Errorf("expected %v, got %v", ErrCommitNotFound, err)
+	}
+
+	if err := Pin(second); err != nil {
+		t.Fatal(err)
+	}
+	defer Unpin()
+	if err := UpdateTo(third); err != ErrPinned || head(t) != second {
+		t.Errorf("expected %v and HEAD to stay at %s, got %v %s", ErrPinned, second, err, head(t))
+	}
+}
+
+func TestUpdateToForcePushed(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/heads/master")
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	if _, err := fetch(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second commit is removed from the branch, but it's still in the clone
+	if err := originRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/master", plumbing.NewHash(first))); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateTo(second); ! errors.Is(err, ErrCommitNotTracked) || head(t) != first {
+		t.Errorf("expected %v and HEAD to stay at %s, got %v %s", ErrCommitNotTracked, first, err, head(t))
+	}
+	if err := CheckPushed(second); ! errors.Is(err, ErrCommitNotTracked) {
+		t.Errorf("expected %v, got %v", ErrCommitNotTracked, err)
+	}
+}
+
+func TestCheckPushed(t *testing.T) {
+	originRepo, origin, first := cloneOrigin(t, "refs/heads/master")
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	third := commit(t, originRepo, origin, "predicates: []\npriorities: []\n")
+
+	// Not fetched yet: the commits pushed after HEAD
+	for _, hash := range []string{second, third} {
+		if err := CheckPushed(hash); err != nil {
+			t.Errorf("expected %s to be accepted, got %v", hash, err)
+		}
+	}
+	if err := UpdateTo(third); err != nil {
+		t.Fatal(err)
+	}
+	// The tip, delivered again
+	if err := CheckPushed(third); err != nil {
+		t.Errorf("expected the tip to be accepted, got %v", err)
+	}
+	// Older pushes, delivered late
+	for _, hash := range []string{first, second} {
+		if err := CheckPushed(hash); ! errors.Is(err, ErrStaleCommit) {
+			t.Errorf("expected %s to be refused with %v, got %v", hash, ErrStaleCommit, err)
+		}
+	}
+	if err := CheckPushed(strings.Repeat("1", 40)); ! errors.Is(err, ErrCommitNotFound) {
+		t.Errorf("expected %v, got %v", ErrCommitNotFound, err)
+	}
+}
+
+func TestCloneCache(t *testing.T) {
+	log.InitLoggers(false)
+	origin := t.TempDir()
+	originRepo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commit(t, originRepo, origin, "predicates: []\n")
+	cache := filepath.Join(t.TempDir(), "cache")
+	restart := func() {
+		configRepo = nil
+		cached = false
+		lastUpdated = time.Time{}
+		CloneRepository(CloneOptions{URL: origin, CacheDir: cache})
+	}
+	t.Cleanup(func() {
+		configRepo = nil
+		cached = false
+	})
+
+	restart()
+	if Cached() || GetRepoDir() != cache || head(t) != first {
+		t.Fatalf("expected a clone in the cache, got cached %v in %s", Cached(), GetRepoDir())
+	}
+
+	// The cached clone is reused, and fetched by the first refresh
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	restart()
+	if ! Cached() || head(t) != first || TrackedRef() != "refs/heads/master" {
+		t.Fatalf("expected the cached clone at %s on master, got cached %v at %s on %s", first, Cached(), head(t), TrackedRef())
+	}
+	if err := RefreshRepository(); err != nil || head(t) != second {
+		t.Fatalf("expected the cached clone to be pulled to %s, got %s %v", second, head(t), err)
+	}
+
+	// The remote is unreachable: the scheduler starts from the cached commit
+	if err := os.RemoveAll(origin); err != nil {
+		t.Fatal(err)
+	}
+	restart()
+	if err := RefreshRepository(); err == nil {
+		t.Error("expected the pull to fail")
+	}
+	if _, err := LastPull(); err == nil {
+		t.Error("expected the error of the pull")
+	}
+	if ! Cached() || head(t) != second {
+		t.Errorf("expected the cached commit %s, got %s", second, head(t))
+	}
+}
+
+func TestCloneCacheOtherRef(t *testing.T) {
+	log.InitLoggers(false)
+	origin := t.TempDir()
+	originRepo, err := git.PlainInit(origin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commit(t, originRepo, origin, "predicates: []\n")
+	second := commit(t, originRepo, origin, "predicates:\n  - name: LabelPredicates\n")
+	if err := originRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/stable", plumbing.NewHash(first))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := originRepo.CreateTag("v1", plumbing.NewHash(first), nil); err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(t.TempDir(), "cache")
+	t.Cleanup(func() {
+		configRepo = nil
+		cached = false
+	})
+
+	testCases := []struct {
+		name string
+		options CloneOptions
+		cached bool
+		ref string
+		head string
+	}{
+		{"default branch", CloneOptions{}, false, "refs/heads/master", second},
+		{"default branch again", CloneOptions{}, true, "refs/heads/master", second},
+		{"same branch", CloneOptions{Branch: "master"}, true, "refs/heads/master", second},
+		{"other branch", CloneOptions{Branch: "stable"}, false, "refs/heads/stable", first},
+		{"other branch again", CloneOptions{Branch: "stable"}, true, "refs/heads/stable", first},
+		{"tag", CloneOptions{Tag: "v1"}, false, "refs/tags/v1", first},
+		{"tag again", CloneOptions{Tag: "v1"}, true, "refs/tags/v1", first},
+		{"back to the default branch", CloneOptions{}, false, "refs/heads/master", second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configRepo = nil
+			cached = false
+			tc.options.URL = origin
+			tc.options.CacheDir = cache
+			CloneRepository(tc.options)
+			if Cached() != tc.cached || TrackedRef() != tc.ref || head(t) != tc.head {
+				t.Errorf("expected cached %v on %s at %s, got cached %v on %s at %s", tc.cached, tc.ref, tc.head, Cached(), TrackedRef(), head(t))
+			}
+		})
+	}
+}
+
+func TestOpenCacheOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := openCache(dir, "https://example.com/config.git", ""); ok || err == nil {
+		t.Errorf("expected a directory with other files to be refused, got %v %v", ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("expected the other files to be kept, got %v", err)
+	}
+}
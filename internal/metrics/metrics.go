@@ -0,0 +1,164 @@
+// Package metrics exposes the metrics of the scheduler in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Types of the metrics
+const (
+	typeCounter = "counter"
+	typeGauge = "gauge"
+)
+
+// metric is a counter or a gauge, with a value for each combination of label values.
+type metric struct {
+	name string
+	help string
+	kind string
+	labels []string
+	mutex sync.Mutex
+	values map[string]float64
+}
+
+// registry is the list of all the metrics, by name.
+var registry = struct {
+	sync.Mutex
+	metrics map[string]*metric
+}{metrics: map[string]*metric{}}
+
+// register adds a metric to the registry. It panics if the name is already used.
+func register(name string, help string, kind string, labels []string) *metric {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.metrics[name]; ok {
+		panic("metrics: " + name + " is already registered")
+	}
+	m := &metric{
+		name: name,
+		help: help,
+		kind: kind,
+		labels: labels,
+		values: map[string]float64{},
+	}
+	registry.metrics[name] = m
+	return m
+}
+
+// key returns the key of the label values in values.
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *metric) add(v float64, labelValues []string) {
+	k := m.key(labelValues)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.values[k] += v
+}
+
+func (m *metric) set(v float64, labelValues []string) {
+	k := m.key(labelValues)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.values[k] = v
+}
+
+// labelEscaper escapes the label values, see the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// write writes the metric in the Prometheus text format, the values sorted by labels.
+func (m *metric) write(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+		return err
+	}
+	keys := []string{}
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		labels := ""
+		if len(m.labels) > 0 {
+			pairs := []string{}
+			for i, v := range strings.Split(k, "\xff") {
+				pairs = append(pairs, m.labels[i]+"=\""+labelEscaper.Replace(v)+"\"")
+			}
+			labels = "{" + strings.Join(pairs, ",") + "}"
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", m.name, labels, strconv.FormatFloat(m.values[k], 'g', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counter is a metric that only increases, like the number of requests.
+type Counter struct {
+	m *metric
+}
+
+// NewCounter registers a counter with the labels, if any.
+func NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{register(name, help, typeCounter, labels)}
+}
+
+// Inc adds 1 to the counter of the label values, one per label.
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+// Gauge is a metric that can go up and down, like a number of items or a timestamp.
+type Gauge struct {
+	m *metric
+}
+
+// NewGauge registers a gauge with the labels, if any.
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{register(name, help, typeGauge, labels)}
+}
+
+// Set sets the gauge of the label values, one per label.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.set(v, labelValues)
+}
+
+// Write writes all the metrics in the Prometheus text format, sorted by name.
+func Write(w io.Writer) error {
+	registry.Lock()
+	names := []string{}
+	for name := range registry.metrics {
+		names = append(names, name)
+	}
+	registry.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		registry.Lock()
+		m := registry.metrics[name]
+		registry.Unlock()
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+}
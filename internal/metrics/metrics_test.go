@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	requests := NewCounter("test_requests_total", "The requests.", "method", "path")
+	requests.Inc("GET", "/")
+	requests.Inc("GET", "/")
+	requests.Inc("POST", `/a"b`)
+	up := NewGauge("test_up", "1 if up.")
+	up.Set(1)
+	up.Set(0.5)
+
+	var b bytes.Buffer
+	if err := Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_requests_total The requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/"} 2
+test_requests_total{method="POST",path="/a\"b"} 1
+# HELP test_up 1 if up.
+# TYPE test_up gauge
+test_up 0.5
+`
+	if ! strings.Contains(b.String(), expected) {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Body.String() != b.String() || ! strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected response %s %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	NewGauge("test_twice", "Registered twice.")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	NewGauge("test_twice", "Registered twice.")
+}
@@ -0,0 +1,7 @@
+package modules
+
+// Predicates are the names of the predicates that can be used in the policy.
+var Predicates = []string{"LabelPredicates", "TaintPredicates"}
+
+// Priorities are the names of the priorities that can be used in the policy.
+var Priorities = []string{"LabelPriorities", "TaintPriorities"}
@@ -2,7 +2,7 @@ package modules
 
 import (
 	"testing"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"sort"
 )
 
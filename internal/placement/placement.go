@@ -2,11 +2,13 @@ package placement
 
 import(
 	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/events"
 	"github.com/redhat-gpe/agnostics/internal/log"
-	"github.com/redhat-gpe/agnostics/internal/api/v1"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"github.com/gomodule/redigo/redis"
 	"errors"
 	"encoding/json"
+	"sort"
 )
 
 // Error when the placement is not found using Uuid
@@ -93,6 +95,30 @@ func GetAll(count int) ([]v1.Placement, error) {
 	return result, err
 }
 
+// GetByCloud retrieves the placements made on the cloud 'name', most recent first.
+// The 'count' parameter is the maximum number of placements to be returned.
+// Set 'count' to  0 if you want the function to return all placements without limit.
+func GetByCloud(name string, count int) ([]v1.Placement, error) {
+	placements, err := GetAll(0)
+	if err != nil {
+		return []v1.Placement{}, err
+	}
+
+	result := []v1.Placement{}
+	for _, p := range placements {
+		if p.Cloud.Name == name {
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreationTimestamp.After(result[j].CreationTimestamp)
+	})
+	if count != 0 && len(result) > count {
+		result = result[:count]
+	}
+	return result, nil
+}
+
 // Save saves a placement in the database.
 func Save(p v1.Placement) error {
 	conn, err := db.Dial()
@@ -124,6 +150,10 @@ func Save(p v1.Placement) error {
 		if newEntry == false {
 			countPlacementsByCloud(conn, "DECR", pOld.Cloud.Name)
 			countPlacementsByCloud(conn, "DECR", "all")
+		} else {
+			e := events.New(v1.EventPlacementCreated)
+			e.Placement = &p
+			events.Publish(e)
 		}
 
 		return nil
@@ -220,6 +250,9 @@ func Delete(uuid string) error {
 		log.Debug.Println("reply Delete(", uuid ,")=", reply)
 		countPlacementsByCloud(conn, "DECR", p.Cloud.Name)
 		countPlacementsByCloud(conn, "DECR", "all")
+		e := events.New(v1.EventPlacementDeleted)
+		e.Placement = &p
+		events.Publish(e)
 		return nil
 	}
 }
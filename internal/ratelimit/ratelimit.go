@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules are the limits applied to each client of the API.
+// A client is the authenticated user, or the IP address when authentication is disabled.
+type Rules struct {
+	// Default is the number of requests a client can send to a route during Window.
+	// 0 means no limit.
+	Default int
+	// Routes overrides Default for some routes. The key is the method and the path
+	// of the route table, for example 'POST /api/v1/schedule'.
+	Routes map[string]int
+	// Window is the period the requests are counted over.
+	Window time.Duration
+	// MaxConcurrent is the number of requests a client can have in progress
+	// at the same time. 0 means no limit.
+	MaxConcurrent int
+	// ConcurrentTimeout is the time after which a request in progress is not counted anymore,
+	// so the requests of a replica that stopped, or the event streams, don't hold their slot forever.
+	ConcurrentTimeout time.Duration
+}
+
+// Store keeps the counters shared by all the replicas of the scheduler.
+type Store interface {
+	// Incr increments the counter of key and returns its new value and the time
+	// before it's reset. The counter is reset window after its first increment.
+	Incr(key string, window time.Duration) (int64, time.Duration, error)
+	// Acquire adds id to the requests in progress of key if there are less than max,
+	// not counting the ones older than timeout.
+	Acquire(key string, id string, max int, timeout time.Duration) (bool, error)
+	// Release removes id from the requests in progress of key.
+	Release(key string, id string) error
+}
+
+// Limiter applies the rules to the requests.
+type Limiter struct {
+	Rules Rules
+	Store Store
+}
+
+// NewLimiter is the constructor for a Limiter keeping its counters in redis.
+func NewLimiter(rules Rules) *Limiter {
+	if rules.Window == 0 {
+		rules.Window = time.Minute
+	}
+	if rules.ConcurrentTimeout == 0 {
+		rules.ConcurrentTimeout = 5 * time.Minute
+	}
+	return &Limiter{
+		Rules: rules,
+		Store: redisStore{},
+	}
+}
+
+// Enabled returns true if at least one limit is set.
+func (r Rules) Enabled() bool {
+	if r.Default > 0 || r.MaxConcurrent > 0 {
+		return true
+	}
+	for _, limit := range r.Routes {
+		if limit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the number of requests allowed for the route during the window, 0 if unlimited.
+func (r Rules) Limit(route string) int {
+	if limit, ok := r.Routes[route]; ok {
+		return limit
+	}
+	return r.Default
+}
+
+// ParseRoutes parses the per-route limits, format 'METHOD /path=limit,...',
+// for example 'POST /api/v1/schedule=30,POST /api/v2/placements=30'.
+func ParseRoutes(s string) (map[string]int, error) {
+	routes := map[string]int{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("invalid rate limit '%s', format is 'METHOD /path=limit'", item)
+		}
+		route := strings.Join(strings.Fields(item[:i]), " ")
+		if fields := strings.Fields(route); len(fields) != 2 || ! strings.HasPrefix(fields[1], "/") {
+			return nil, fmt.Errorf("invalid route '%s' in rate limit, format is 'METHOD /path'", route)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit '%s' for route '%s', must be a positive integer", item[i+1:], route)
+		}
+		routes[route] = limit
+	}
+	return routes, nil
+}
+
+// Allow counts the request of the client on the route. If the client sent too many
+// requests, it returns false and the time to wait before retrying.
+// If the store is not available, the request is allowed.
+func (l *Limiter) Allow(client string, route string) (bool, time.Duration) {
+	limit := l.Rules.Limit(route)
+	if limit <= 0 {
+		return true, 0
+	}
+	count, ttl, err := l.Store.Incr("ratelimit:"+client+":"+route, l.Rules.Window)
+	if err != nil {
+		log.Err.Println("ratelimit: cannot count the request, allowing it:", err)
+		return true, 0
+	}
+	if count > int64(limit) {
+		return false, ttl
+	}
+	return true, 0
+}
+
+// Acquire reserves a slot for a request of the client. If the client has too many
+// requests in progress, it returns false. Otherwise release must be called once the
+// request is done.
+// If the store is not available, the request is allowed.
+func (l *Limiter) Acquire(client string) (release func(), ok bool) {
+	if l.Rules.MaxConcurrent <= 0 {
+		return func() {}, true
+	}
+	key := "concurrency:" + client
+	id := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.FormatUint(nextID(), 10)
+	ok, err := l.Store.Acquire(key, id, l.Rules.MaxConcurrent, l.Rules.ConcurrentTimeout)
+	if err != nil {
+		log.Err.Println("ratelimit: cannot count the requests in progress, allowing it:", err)
+		return func() {}, true
+	}
+	if ! ok {
+		return nil, false
+	}
+	return func() {
+		if err := l.Store.Release(key, id); err != nil {
+			log.Err.Println("ratelimit: cannot release the request", err)
+		}
+	}, true
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"errors"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store for the tests, the counters are never reset.
+type memoryStore struct {
+	mutex sync.Mutex
+	counters map[string]int64
+	inProgress map[string]map[string]bool
+	err error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		counters: map[string]int64{},
+		inProgress: map[string]map[string]bool{},
+	}
+}
+
+func (s *memoryStore) Incr(key string, window time.Duration) (int64, time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return 0, 0, s.err
+	}
+	s.counters[key]++
+	return s.counters[key], window, nil
+}
+
+func (s *memoryStore) Acquire(key string, id string, max int, timeout time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if s.inProgress[key] == nil {
+		s.inProgress[key] = map[string]bool{}
+	}
+	if len(s.inProgress[key]) >= max {
+		return false, nil
+	}
+	s.inProgress[key][id] = true
+	return true, nil
+}
+
+func (s *memoryStore) Release(key string, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.inProgress[key], id)
+	return nil
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" POST /api/v1/schedule=30, GET  /api/v2/clouds = 5 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes["POST /api/v1/schedule"] != 30 || routes["GET /api/v2/clouds"] != 5 {
+		t.Errorf("unexpected routes %v", routes)
+	}
+
+	for _, s := range []string{"POST /api/v1/schedule", "/api/v1/schedule=3", "POST /api/v1/schedule=-1", "POST /api/v1/schedule=abc"} {
+		if _, err := ParseRoutes(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	log.InitLoggers(false)
+	store := newMemoryStore()
+	l := &Limiter{
+		Rules: Rules{Default: 2, Routes: map[string]int{"POST /api/v1/schedule": 1, "GET /api/v1/clouds": 0}, Window: time.Minute},
+		Store: store,
+	}
+
+	testCases := []struct {
+		client string
+		route string
+		expected bool
+	}{
+		{"user:alice", "GET /api/v1/placements", true},
+		{"user:alice", "GET /api/v1/placements", true},
+		{"user:alice", "GET /api/v1/placements", false},
+		// Limits are per client and per route
+		{"user:bob", "GET /api/v1/placements", true},
+		{"user:alice", "POST /api/v1/schedule", true},
+		{"user:alice", "POST /api/v1/schedule", false},
+		// 0 means no limit
+		{"user:alice", "GET /api/v1/clouds", true},
+		{"user:alice", "GET /api/v1/clouds", true},
+		{"user:alice", "GET /api/v1/clouds", true},
+	}
+	for i, tc := range testCases {
+		ok, retryAfter := l.Allow(tc.client, tc.route)
+		if ok != tc.expected {
+			t.Errorf("%d: expected %v for %s %s, got %v", i, tc.expected, tc.client, tc.route, ok)
+		}
+		if ! ok && retryAfter != time.Minute {
+			t.Errorf("%d: expected to retry after a minute, got %s", i, retryAfter)
+		}
+	}
+
+	// The requests are allowed when the store is not available
+	store.err = errors.New("connection refused")
+	if ok, _ := l.Allow("user:alice", "GET /api/v1/placements"); ! ok {
+		t.Error("expected the request to be allowed when the store fails")
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	log.InitLoggers(false)
+	store := newMemoryStore()
+	l := &Limiter{
+		Rules: Rules{MaxConcurrent: 2},
+		Store: store,
+	}
+
+	release1, ok := l.Acquire("user:alice")
+	if ! ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	if _, ok := l.Acquire("user:alice"); ! ok {
+		t.Fatal("expected second request to be allowed")
+	}
+	if _, ok := l.Acquire("user:alice"); ok {
+		t.Error("expected third request to be refused")
+	}
+	if _, ok := l.Acquire("user:bob"); ! ok {
+		t.Error("expected the requests of another client to be allowed")
+	}
+
+	release1()
+	if _, ok := l.Acquire("user:alice"); ! ok {
+		t.Error("expected request to be allowed after a release")
+	}
+}
+
+func TestRulesEnabled(t *testing.T) {
+	if (Rules{}).Enabled() || (Rules{Routes: map[string]int{"GET /api/v1/clouds": 0}}).Enabled() {
+		t.Error("expected rules without limits to be disabled")
+	}
+	if ! (Rules{Routes: map[string]int{"GET /api/v1/clouds": 1}}).Enabled() || ! (Rules{MaxConcurrent: 1}).Enabled() {
+		t.Error("expected rules to be enabled")
+	}
+}
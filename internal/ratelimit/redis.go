@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"sync/atomic"
+	"time"
+)
+
+// incrScript increments the counter and sets its expiration on the first increment.
+// It returns the counter and its TTL in milliseconds.
+var incrScript = redis.NewScript(1, `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {count, redis.call('PTTL', KEYS[1])}
+`)
+
+// acquireScript removes the requests older than the timeout from the sorted set,
+// then adds the request if there is a free slot. The score is the start time in milliseconds.
+var acquireScript = redis.NewScript(1, `
+local now = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - tonumber(ARGV[4]))
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// counter makes the IDs of the requests in progress unique within the replica.
+var counter uint64
+
+func nextID() uint64 {
+	return atomic.AddUint64(&counter, 1)
+}
+
+// redisStore is the Store shared by all the replicas.
+type redisStore struct{}
+
+func (redisStore) Incr(key string, window time.Duration) (int64, time.Duration, error) {
+	conn, err := db.Dial()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	values, err := redis.Int64s(incrScript.Do(conn, key, window.Milliseconds()))
+	if err != nil {
+		return 0, 0, err
+	}
+	return values[0], time.Duration(values[1]) * time.Millisecond, nil
+}
+
+func (redisStore) Acquire(key string, id string, max int, timeout time.Duration) (bool, error) {
+	conn, err := db.Dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	return redis.Bool(acquireScript.Do(conn, key, id, now, max, timeout.Milliseconds()))
+}
+
+func (redisStore) Release(key string, id string) error {
+	conn, err := db.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("ZREM", key, id)
+	return err
+}
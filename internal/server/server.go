@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Timeouts are the timeouts of the HTTP servers, see http.Server.
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read time.Duration
+	// Write is the maximum duration of a response. The event streams are not limited, see DisableWriteTimeout.
+	Write time.Duration
+	Idle time.Duration
+}
+
+// DefaultTimeouts are the timeouts used when none is configured.
+var DefaultTimeouts = Timeouts{
+	ReadHeader: 10 * time.Second,
+	Read: 30 * time.Second,
+	Write: 60 * time.Second,
+	Idle: 120 * time.Second,
+}
+
+type contextKey string
+
+// connContextKey is the key of the connection in the context of the requests.
+const connContextKey contextKey = "conn"
+
+// New returns the server of handler on addr, with HTTPS if tlsConfig is not nil.
+func New(addr string, handler http.Handler, tlsConfig *tls.Config, timeouts Timeouts) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		Handler: handler,
+		TLSConfig: tlsConfig,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout: timeouts.Read,
+		WriteTimeout: timeouts.Write,
+		IdleTimeout: timeouts.Idle,
+		// Keep the connection in the context, for DisableWriteTimeout
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey, c)
+		},
+		// HTTP/1.1 only: the write timeout of HTTP/2 streams can't be disabled.
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
+	}
+}
+
+// DisableWriteTimeout removes the write timeout of the connection of the request,
+// for the responses that can last forever, like the event streams.
+func DisableWriteTimeout(req *http.Request) {
+	if c, ok := req.Context().Value(connContextKey).(net.Conn); ok {
+		c.SetWriteDeadline(time.Time{})
+	}
+}
+
+// Run serves until the server is shut down. It returns nil after Shutdown.
+func Run(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		// The certificates are provided by the config
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+var draining int32
+
+// Drain marks the scheduler as stopping, so the health checks fail and
+// the load balancers stop sending new requests.
+func Drain() {
+	atomic.StoreInt32(&draining, 1)
+}
+
+// Draining returns true after Drain.
+func Draining() bool {
+	return atomic.LoadInt32(&draining) == 1
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// start serves handler on a random port and returns its URL.
+func start(t *testing.T, handler http.HandlerFunc, timeouts Timeouts) (*http.Server, string, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(listener.Addr().String(), handler, nil, timeouts)
+	errs := make(chan error, 1)
+	go func() {
+		err := srv.Serve(listener)
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		errs <- err
+	}()
+	t.Cleanup(func() { srv.Close() })
+	return srv, "http://" + listener.Addr().String(), errs
+}
+
+func TestWriteTimeout(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/stream" {
+			DisableWriteTimeout(req)
+		}
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "OK")
+	}
+	_, url, _ := start(t, handler, Timeouts{Write: 50 * time.Millisecond})
+
+	if resp, err := http.Get(url + "/slow"); err == nil {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil && string(body) == "OK" {
+			t.Error("expected the slow response to time out")
+		}
+	}
+
+	resp, err := http.Get(url + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "OK" {
+		t.Errorf("expected the stream not to time out, got %q", body)
+	}
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "OK")
+	}
+	srv, url, errs := start(t, handler, DefaultTimeouts)
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+
+	<-started
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-result; r != "OK" {
+		t.Errorf("expected the request in progress to complete, got %q", r)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("expected no error after shutdown, got %v", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	defer func() { draining = 0 }()
+	if Draining() {
+		t.Fatal("expected not draining")
+	}
+	Drain()
+	if ! Draining() {
+		t.Error("expected draining")
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions are the files used to serve HTTPS.
+type TLSOptions struct {
+	// CertFile and KeyFile are the PEM certificate and private key of the server.
+	// TLS is disabled if they are empty.
+	CertFile string
+	KeyFile string
+	// ClientCAFile is the PEM bundle of the CAs used to verify the client certificates.
+	// Client certificates are not requested if it's empty.
+	ClientCAFile string
+	// ClientCertRequired rejects the connections without a valid client certificate.
+	// Otherwise the clients without certificate use the other authentication methods.
+	ClientCertRequired bool
+}
+
+// Enabled returns true if TLS is configured.
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// reloadInterval is the minimum time between two checks of the files.
+var reloadInterval = 10 * time.Second
+
+// certReloader provides the TLS config for each connection, and reloads
+// the certificates when the files change, so they can be renewed without restart.
+type certReloader struct {
+	options TLSOptions
+	mutex sync.Mutex
+	config *tls.Config
+	modTimes map[string]time.Time
+	checked time.Time
+}
+
+// files returns the files to watch.
+func (r *certReloader) files() []string {
+	files := []string{r.options.CertFile, r.options.KeyFile}
+	if r.options.ClientCAFile != "" {
+		files = append(files, r.options.ClientCAFile)
+	}
+	return files
+}
+
+// changed returns true if one of the files was modified since the last load.
+func (r *certReloader) changed() bool {
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			log.Err.Println("TLS:", err)
+			return false
+		}
+		if ! info.ModTime().Equal(r.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads the files and builds the config. The current config is kept if it fails.
+func (r *certReloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.options.CertFile, r.options.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load the certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if r.options.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.options.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if ! pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", r.options.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.options.ClientCertRequired {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	r.config = config
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Since(r.checked) >= reloadInterval {
+		r.checked = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				log.Err.Println("TLS: cannot reload the certificates, keeping the previous ones:", err)
+			} else {
+				log.Out.Println("TLS: certificates reloaded")
+			}
+		}
+	}
+	return r.config, nil
+}
+
+// NewTLSConfig returns the TLS config of the listeners, nil if TLS is disabled.
+// The certificates are reloaded when the files change.
+func NewTLSConfig(options TLSOptions) (*tls.Config, error) {
+	if ! options.Enabled() {
+		if options.ClientCAFile != "" {
+			return nil, errors.New("the client CA requires the certificate and the key of the server")
+		}
+		return nil, nil
+	}
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, errors.New("both the certificate and the key are required for TLS")
+	}
+	if options.ClientCertRequired && options.ClientCAFile == "" {
+		return nil, errors.New("the client CA is required to verify the client certificates")
+	}
+
+	r := &certReloader{options: options, checked: time.Now()}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+		// Only used by http.Server to know the certificates are provided by the config
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			config, err := r.getConfigForClient(hello)
+			if err != nil {
+				return nil, err
+			}
+			return &config.Certificates[0], nil
+		},
+	}, nil
+}
+
+// CertificateUser returns the user of the verified client certificate
+// of the request, that is the common name of its subject.
+func CertificateUser(req *http.Request) (string, bool) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+	return cn, cn != ""
+}
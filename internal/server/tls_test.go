@@ -0,0 +1,264 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key *ecdsa.PrivateKey
+	pem []byte
+}
+
+var serial int64
+
+func newCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{CommonName: "test CA"},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		KeyUsage: x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key for cn.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{CommonName: cn},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The modification time must change even if the test is fast
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startServer serves the CN of the client certificate.
+func startServer(t *testing.T, config *tls.Config) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _ := CertificateUser(req)
+		io.WriteString(w, user)
+	}))
+	srv.TLS = config
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(url string, roots *x509.CertPool, clientCert []tls.Certificate) (string, *x509.Certificate, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: roots,
+		Certificates: clientCert,
+	}}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body), resp.TLS.PeerCertificates[0], nil
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	testCases := []TLSOptions{
+		{CertFile: "cert.pem"},
+		{ClientCAFile: "ca.pem"},
+		{CertFile: "cert.pem", KeyFile: "key.pem", ClientCertRequired: true},
+		{CertFile: "missing.pem", KeyFile: "missing.pem"},
+	}
+	for _, tc := range testCases {
+		if _, err := NewTLSConfig(tc); err == nil {
+			t.Errorf("expected error for %+v", tc)
+		}
+	}
+	if config, err := NewTLSConfig(TLSOptions{}); config != nil || err != nil {
+		t.Errorf("expected TLS to be disabled, got %v %v", config, err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	log.InitLoggers(false)
+	dir := t.TempDir()
+	ca := newCA(t)
+	certPEM, keyPEM := ca.issue(t, "scheduler", x509.ExtKeyUsageServerAuth)
+	options := TLSOptions{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile: filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	modTime := time.Now().Add(-time.Minute)
+	writeFile(t, options.CertFile, certPEM, modTime)
+	writeFile(t, options.KeyFile, keyPEM, modTime)
+	writeFile(t, options.ClientCAFile, ca.pem, modTime)
+
+	config, err := NewTLSConfig(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := startServer(t, config)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	clientPEM, clientKeyPEM := ca.issue(t, "alice", x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _, err := get(srv.URL, roots, []tls.Certificate{clientCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "alice" {
+		t.Errorf("expected user alice, got %q", user)
+	}
+
+	// The client certificate is optional
+	if user, _, err := get(srv.URL, roots, nil); err != nil || user != "" {
+		t.Errorf("expected anonymous request, got %q %v", user, err)
+	}
+
+	// A certificate signed by another CA is rejected
+	otherPEM, otherKeyPEM := newCA(t).issue(t, "mallory", x509.ExtKeyUsageClientAuth)
+	otherCert, _ := tls.X509KeyPair(otherPEM, otherKeyPEM)
+	if _, _, err := get(srv.URL, roots, []tls.Certificate{otherCert}); err == nil {
+		t.Error("expected the certificate of another CA to be rejected")
+	}
+}
+
+func TestClientCertRequired(t *testing.T) {
+	log.InitLoggers(false)
+	dir := t.TempDir()
+	ca := newCA(t)
+	certPEM, keyPEM := ca.issue(t, "scheduler", x509.ExtKeyUsageServerAuth)
+	options := TLSOptions{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile: filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientCertRequired: true,
+	}
+	writeFile(t, options.CertFile, certPEM, time.Now())
+	writeFile(t, options.KeyFile, keyPEM, time.Now())
+	writeFile(t, options.ClientCAFile, ca.pem, time.Now())
+
+	config, err := NewTLSConfig(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := startServer(t, config)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	if _, _, err := get(srv.URL, roots, nil); err == nil {
+		t.Error("expected the request without client certificate to be rejected")
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	log.InitLoggers(false)
+	defer func(d time.Duration) { reloadInterval = d }(reloadInterval)
+	reloadInterval = 0
+
+	dir := t.TempDir()
+	ca := newCA(t)
+	options := TLSOptions{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile: filepath.Join(dir, "tls.key"),
+	}
+	certPEM, keyPEM := ca.issue(t, "scheduler", x509.ExtKeyUsageServerAuth)
+	modTime := time.Now().Add(-time.Minute)
+	writeFile(t, options.CertFile, certPEM, modTime)
+	writeFile(t, options.KeyFile, keyPEM, modTime)
+
+	config, err := NewTLSConfig(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := startServer(t, config)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	_, first, err := get(srv.URL, roots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renew the certificate
+	certPEM, keyPEM = ca.issue(t, "scheduler", x509.ExtKeyUsageServerAuth)
+	writeFile(t, options.CertFile, certPEM, time.Now())
+	writeFile(t, options.KeyFile, keyPEM, time.Now())
+	_, second, err := get(srv.URL, roots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SerialNumber.Cmp(second.SerialNumber) == 0 {
+		t.Error("expected the new certificate to be served")
+	}
+
+	// An invalid certificate is not loaded, the previous one is kept
+	writeFile(t, options.CertFile, []byte("invalid"), time.Now().Add(time.Minute))
+	_, third, err := get(srv.URL, roots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.SerialNumber.Cmp(second.SerialNumber) != 0 {
+		t.Error("expected the previous certificate to be kept")
+	}
+}
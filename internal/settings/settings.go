@@ -0,0 +1,294 @@
+package settings
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources of the values, from the lowest to the highest precedence.
+const (
+	SourceDefault = "default"
+	SourceFile = "file"
+	SourceEnv = "env"
+	SourceFlag = "flag"
+)
+
+// Option is a setting of the program. It can be set by a flag, an environment
+// variable or a key of the config file, all with the same name.
+type Option struct {
+	// Name is the name of the flag and the key in the config file.
+	Name string
+	// Env is the environment variable, empty if the option has none.
+	Env string
+	Usage string
+	// Source is where the current value comes from.
+	Source string
+	value flag.Value
+	mask func(string) string
+}
+
+// Secret masks the value of the option when it's printed.
+func (o *Option) Secret() *Option {
+	o.mask = func(v string) string {
+		if v == "" {
+			return ""
+		}
+		return "********"
+	}
+	return o
+}
+
+// SecretURL masks the password of the URL of the option when it's printed.
+func (o *Option) SecretURL() *Option {
+	o.mask = MaskURL
+	return o
+}
+
+// Value returns the current value of the option, as a string.
+func (o *Option) Value() string {
+	return o.value.String()
+}
+
+// set sets the value of the option, with an error message describing the expected format.
+func (o *Option) set(v string) error {
+	if err := o.value.Set(v); err != nil {
+		switch o.value.(flag.Getter).Get().(type) {
+		case bool:
+			return fmt.Errorf("'%s' is not a boolean, must be 'true' or 'false'", v)
+		case int:
+			return fmt.Errorf("'%s' is not an integer", v)
+		case time.Duration:
+			return fmt.Errorf("'%s' is not a duration, for example '30s' or '5m'", v)
+		}
+		return err
+	}
+	return nil
+}
+
+// MaskedValue returns the value of the option, masked if it's a secret.
+func (o *Option) MaskedValue() string {
+	if o.mask != nil {
+		return o.mask(o.Value())
+	}
+	return o.Value()
+}
+
+// MaskURL masks the password of u, for example 'redis://:********@localhost:6379'.
+func MaskURL(u string) string {
+	scheme := strings.Index(u, "://")
+	at := strings.LastIndex(u, "@")
+	if scheme == -1 || at < scheme {
+		return u
+	}
+	userinfo := u[scheme+3:at]
+	if i := strings.Index(userinfo, ":"); i != -1 {
+		userinfo = userinfo[:i+1] + "********"
+	} else {
+		// The user is the password for some URLs, like redis://password@host
+		userinfo = "********"
+	}
+	return u[:scheme+3] + userinfo + u[at:]
+}
+
+// Set is the set of the options of a program.
+type Set struct {
+	flags *flag.FlagSet
+	options []*Option
+	byName map[string]*Option
+	// ConfigPath is the path of the config file, set by the '-config' flag or the CONFIG environment variable.
+	ConfigPath string
+	// PrintConfig is true if the effective config must be printed, see Print.
+	PrintConfig bool
+}
+
+// New is the constructor for a Set, with the '-config' and '-print-config' flags.
+func New(name string) *Set {
+	s := &Set{
+		flags: flag.NewFlagSet(name, flag.ContinueOnError),
+		byName: map[string]*Option{},
+	}
+	s.flags.StringVar(&s.ConfigPath, "config", "", "The path of the YAML config file. Its keys are the names of the flags. Precedence: flag > environment variable > config file > default.\nEnvironment variable: CONFIG\n")
+	s.flags.BoolVar(&s.PrintConfig, "print-config", false, "Print the effective config, with the secrets masked, and exit.")
+	return s
+}
+
+// FlagSet returns the flags of the set, for example to customize the usage.
+func (s *Set) FlagSet() *flag.FlagSet {
+	return s.flags
+}
+
+// Options returns all the options, in the order they were added.
+func (s *Set) Options() []*Option {
+	return s.options
+}
+
+// Lookup returns the option name, or nil.
+func (s *Set) Lookup(name string) *Option {
+	return s.byName[name]
+}
+
+// add registers the option of the flag name, already added to the flags.
+func (s *Set) add(name string, env string, usage string) *Option {
+	f := s.flags.Lookup(name)
+	o := &Option{
+		Name: name,
+		Env: env,
+		Usage: usage,
+		Source: SourceDefault,
+		value: f.Value,
+	}
+	s.options = append(s.options, o)
+	s.byName[name] = o
+	return o
+}
+
+// usage returns the usage of the flag, with the environment variable.
+func usage(env string, usage string) string {
+	if env == "" {
+		return usage
+	}
+	return usage + "\nEnvironment variable: " + env + "\n"
+}
+
+// String adds a string option. p is set to value.
+func (s *Set) String(p *string, name string, env string, value string, u string) *Option {
+	s.flags.StringVar(p, name, value, usage(env, u))
+	return s.add(name, env, u)
+}
+
+// Bool adds a boolean option. p is set to value.
+func (s *Set) Bool(p *bool, name string, env string, value bool, u string) *Option {
+	s.flags.BoolVar(p, name, value, usage(env, u))
+	return s.add(name, env, u)
+}
+
+// Int adds an integer option. p is set to value.
+func (s *Set) Int(p *int, name string, env string, value int, u string) *Option {
+	s.flags.IntVar(p, name, value, usage(env, u))
+	return s.add(name, env, u)
+}
+
+// Duration adds a duration option, for example '30s'. p is set to value.
+func (s *Set) Duration(p *time.Duration, name string, env string, value time.Duration, u string) *Option {
+	s.flags.DurationVar(p, name, value, usage(env, u))
+	return s.add(name, env, u)
+}
+
+// Parse sets the options from the arguments, the environment variables and the config file.
+// All the invalid values are reported in the error.
+func (s *Set) Parse(args []string) error {
+	if err := s.flags.Parse(args); err != nil {
+		return err
+	}
+	fromFlags := map[string]bool{}
+	s.flags.Visit(func(f *flag.Flag) {
+		fromFlags[f.Name] = true
+		if o, ok := s.byName[f.Name]; ok {
+			o.Source = SourceFlag
+		}
+	})
+	if ! fromFlags["config"] {
+		s.ConfigPath = os.Getenv("CONFIG")
+	}
+
+	errs := []string{}
+	if s.ConfigPath != "" {
+		values, err := readFile(s.ConfigPath)
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(values) {
+			o, ok := s.byName[key]
+			if ! ok {
+				errs = append(errs, fmt.Sprintf("%s: unknown key '%s'", s.ConfigPath, key))
+				continue
+			}
+			if fromFlags[key] {
+				continue
+			}
+			if err := o.set(values[key]); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid value for '%s': %v", s.ConfigPath, key, err))
+				continue
+			}
+			o.Source = SourceFile
+		}
+	}
+
+	for _, o := range s.options {
+		if o.Env == "" || fromFlags[o.Name] {
+			continue
+		}
+		e := os.Getenv(o.Env)
+		if e == "" {
+			continue
+		}
+		if err := o.set(e); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value for %s: %v", o.Env, err))
+			continue
+		}
+		o.Source = SourceEnv
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// readFile reads the config file. The values must be scalars.
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := map[string]string{}
+	for k, v := range raw {
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return nil, fmt.Errorf("%s: the value of '%s' must be a string, a number or a boolean", path, k)
+		case nil:
+			values[k] = ""
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// Print writes the effective config as YAML, with the source of each value and the secrets masked.
+// The output can be used as a config file.
+func (s *Set) Print(w io.Writer) error {
+	for _, o := range s.options {
+		value := o.MaskedValue()
+		// Quote the strings, so the output is valid YAML whatever the value
+		if _, ok := o.value.(flag.Getter).Get().(string); ok {
+			value = strconv.Quote(value)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s # %s\n", o.Name, value, o.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,110 @@
+package source
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// dirDebounce is the time without changes before the config is reloaded,
+// so an editor saving several files or a ConfigMap update triggers a single reload.
+var dirDebounce = time.Second
+
+// localDir is a local directory, like a working copy of the config repository
+// or a ConfigMap volume. The changes are detected with inotify.
+type localDir struct {
+	dir string
+	mutex sync.Mutex
+	revision string
+}
+
+// NewDir returns the source reading the config from the local directory dir.
+func NewDir(dir string) Source {
+	return &localDir{dir: dir}
+}
+
+func (d *localDir) Kind() string {
+	return KindDir
+}
+
+func (d *localDir) Dir() string {
+	return d.dir
+}
+
+func (d *localDir) Revision() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.revision
+}
+
+// Update only checks that the files changed, they are read in place.
+func (d *localDir) Update(string) error {
+	revision, err := hashDir(d.dir)
+	if err != nil {
+		log.Err.Println("Cannot read the config directory", d.dir, err)
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if revision == d.revision {
+		return ErrUnchanged
+	}
+	d.revision = revision
+	return nil
+}
+
+// Watch watches the directory and its subdirectories, like 'clouds'.
+func (d *localDir) Watch(ctx context.Context, changed func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Err.Println("Cannot watch the config directory", d.dir, err)
+		return
+	}
+	defer watcher.Close()
+	d.addWatches(watcher)
+	log.Out.Println("Watching the config directory", d.dir)
+
+	// Stopped until the first event
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+			return
+		case event, ok := <-watcher.Events:
+			if ! ok {
+				return
+			}
+			log.Debug.Println("Config directory:", event)
+			debounce.Reset(dirDebounce)
+		case err, ok := <-watcher.Errors:
+			if ! ok {
+				return
+			}
+			log.Err.Println("Config directory watch:", err)
+		case <-debounce.C:
+			// The new subdirectories, and the new targets of the links of a ConfigMap volume
+			d.addWatches(watcher)
+			changed()
+		}
+	}
+}
+
+// addWatches watches the directory and all its subdirectories.
+// The watches of the removed directories are removed by inotify.
+func (d *localDir) addWatches(watcher *fsnotify.Watcher) {
+	dirs := []string{""}
+	files := []string{}
+	if err := listFiles(d.dir, "", &dirs, &files); err != nil {
+		log.Err.Println("Cannot list the config directory", d.dir, err)
+	}
+	for _, rel := range dirs {
+		if err := watcher.Add(filepath.Join(d.dir, rel)); err != nil {
+			log.Err.Println("Cannot watch", filepath.Join(d.dir, rel), err)
+		}
+	}
+}
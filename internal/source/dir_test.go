@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes the file rel of dir, creating its directory.
+func writeFile(t *testing.T, dir string, rel string, content string) {
+	p := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirUpdate(t *testing.T) {
+	log.InitLoggers(false)
+	dir := t.TempDir()
+	writeFile(t, dir, "policy.yaml", "predicates: []\n")
+	writeFile(t, dir, "clouds/a.yml", "name: a\n")
+
+	src := NewDir(dir)
+	if src.Dir() != dir || src.Revision() != "" {
+		t.Errorf("unexpected dir %s or revision %s", src.Dir(), src.Revision())
+	}
+	if err := src.Update(""); err != nil {
+		t.Fatal(err)
+	}
+	first := src.Revision()
+	if err := src.Update(""); err != ErrUnchanged {
+		t.Errorf("expected %v, got %v", ErrUnchanged, err)
+	}
+
+	// The hidden files are ignored
+	writeFile(t, dir, "clouds/.a.yml.swp", "editor")
+	if err := src.Update(""); err != ErrUnchanged {
+		t.Errorf("expected %v for a hidden file, got %v", ErrUnchanged, err)
+	}
+
+	writeFile(t, dir, "clouds/a.yml", "name: a\nenabled: false\n")
+	if err := src.Update(""); err != nil || src.Revision() == first {
+		t.Errorf("expected a new revision, got %s %v", src.Revision(), err)
+	}
+}
+
+// TestDirConfigMap checks the layout of a ConfigMap volume: the files are links
+// to the directory '..data', itself a link to the current version.
+func TestDirConfigMap(t *testing.T) {
+	log.InitLoggers(false)
+	dir := t.TempDir()
+	writeFile(t, dir, "..v1/policy.yaml", "predicates: []\n")
+	writeFile(t, dir, "..v1/clouds/a.yml", "name: a\n")
+	for old, new := range map[string]string{
+		"..v1": "..data",
+		"..data/policy.yaml": "policy.yaml",
+		"..data/clouds": "clouds",
+	} {
+		if err := os.Symlink(old, filepath.Join(dir, new)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := NewDir(dir)
+	if err := src.Update(""); err != nil {
+		t.Fatal(err)
+	}
+	first := src.Revision()
+
+	dirDebounce = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	go src.Watch(ctx, func() { changes <- struct{}{} })
+	// Let the watches be added
+	time.Sleep(50 * time.Millisecond)
+
+	// Kubernetes writes the new version and swaps the link '..data'
+	writeFile(t, dir, "..v2/policy.yaml", "predicates: []\n")
+	writeFile(t, dir, "..v2/clouds/a.yml", "name: a\nenabled: false\n")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a change")
+	}
+	if err := src.Update(""); err != nil || src.Revision() == first {
+		t.Errorf("expected a new revision, got %s %v", src.Revision(), err)
+	}
+}
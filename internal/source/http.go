@@ -0,0 +1,236 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBundleSize is the maximum size of a bundle, and of the files extracted from it.
+const maxBundleSize = 50 << 20
+
+// httpBundle is a tarball of the config files fetched over HTTP, for example from an
+// artifact server in an air-gapped install. It's fetched again every interval, and
+// extracted only if it changed.
+type httpBundle struct {
+	url string
+	interval time.Duration
+	client *http.Client
+	mutex sync.Mutex
+	// tmpDir is where the current bundle is extracted, dir is the directory containing
+	// the config in it, tmpDir or its only subdirectory.
+	tmpDir string
+	dir string
+	etag string
+	revision string
+}
+
+// NewHTTP returns the source reading the config from the tarball at url, optionally gzipped.
+// The files can be at the root of the tarball or in a single directory, like the archives of
+// GitHub or GitLab. The tarball is checked every interval, 0 means only on the pull requests.
+func NewHTTP(url string, interval time.Duration) Source {
+	return &httpBundle{
+		url: url,
+		interval: interval,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (b *httpBundle) Kind() string {
+	return KindHTTP
+}
+
+func (b *httpBundle) Dir() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.dir
+}
+
+// Revision is the SHA-256 of the tarball.
+func (b *httpBundle) Revision() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.revision
+}
+
+func (b *httpBundle) Update(string) error {
+	b.mutex.Lock()
+	etag := b.etag
+	b.mutex.Unlock()
+
+	bundle, etag, err := b.fetch(etag)
+	if err == ErrUnchanged {
+		return err
+	}
+	if err != nil {
+		log.Err.Println("Cannot fetch the config bundle", b.url, err)
+		return err
+	}
+	sum := sha256.Sum256(bundle)
+	revision := hex.EncodeToString(sum[:])
+
+	b.mutex.Lock()
+	b.etag = etag
+	unchanged := revision == b.revision
+	b.mutex.Unlock()
+	if unchanged {
+		return ErrUnchanged
+	}
+
+	tmpDir, err := ioutil.TempDir("", "scheduler-config-")
+	if err != nil {
+		return err
+	}
+	if err := extract(bundle, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		log.Err.Println("Cannot extract the config bundle", b.url, err)
+		return err
+	}
+
+	b.mutex.Lock()
+	previous := b.tmpDir
+	b.tmpDir = tmpDir
+	b.dir = configRoot(tmpDir)
+	b.revision = revision
+	b.mutex.Unlock()
+	if previous != "" {
+		os.RemoveAll(previous)
+	}
+	log.Out.Println("Config bundle", b.url, "updated to", revision)
+	return nil
+}
+
+// fetch downloads the bundle, unless it has the ETag etag.
+// It returns the bundle and its ETag, or ErrUnchanged.
+func (b *httpBundle) fetch(etag string) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", b.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, "", ErrUnchanged
+	default:
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	bundle, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBundleSize + 1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(bundle) > maxBundleSize {
+		return nil, "", fmt.Errorf("the bundle is larger than %d bytes", maxBundleSize)
+	}
+	return bundle, resp.Header.Get("ETag"), nil
+}
+
+// extract extracts the regular files and the directories of the tarball to dir.
+// The other entries, like the links, are ignored.
+func extract(bundle []byte, dir string) error {
+	var r io.Reader = bytes.NewReader(bundle)
+	if len(bundle) > 2 && bundle[0] == 0x1f && bundle[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	var size int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %s", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			size += header.Size
+			if size > maxBundleSize {
+				return errors.New("the extracted files are too large")
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, io.LimitReader(tr, header.Size))
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			log.Debug.Println("Config bundle: ignoring", header.Name)
+		}
+	}
+}
+
+// configRoot returns the directory containing the config in the extracted bundle dir:
+// dir, or its only subdirectory if the files are in a directory, like 'scheduler-config-main/'.
+func configRoot(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "policy.yaml")); err == nil {
+		return dir
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) != 1 || ! entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+// Watch requests an update every interval.
+func (b *httpBundle) Watch(ctx context.Context, changed func()) {
+	if b.interval <= 0 {
+		return
+	}
+	log.Out.Println("Checking the config bundle", b.url, "every", b.interval)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed()
+		}
+	}
+}
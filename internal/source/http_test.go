@@ -0,0 +1,109 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// tarball returns a gzipped tarball of the files, by path.
+func tarball(t *testing.T, files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHTTPUpdate(t *testing.T) {
+	log.InitLoggers(false)
+	bundle := tarball(t, map[string]string{
+		"scheduler-config-main/policy.yaml": "predicates: []\n",
+		"scheduler-config-main/clouds/a.yml": "name: a\n",
+	})
+	etag := `"v1"`
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(bundle)
+	}))
+	defer server.Close()
+
+	src := NewHTTP(server.URL, 0)
+	if err := src.Update(""); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(src.Dir()) != "scheduler-config-main" {
+		t.Errorf("expected the config in the directory of the tarball, got %s", src.Dir())
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(src.Dir(), "clouds", "a.yml")); err != nil || string(content) != "name: a\n" {
+		t.Errorf("unexpected clouds/a.yml %q %v", content, err)
+	}
+	first := src.Revision()
+
+	if err := src.Update(""); err != ErrUnchanged {
+		t.Errorf("expected %v with the same ETag, got %v", ErrUnchanged, err)
+	}
+
+	// A new tarball, the previous one is removed
+	previous := src.Dir()
+	bundle = tarball(t, map[string]string{"policy.yaml": "predicates: []\n", "clouds/b.yml": "name: b\n"})
+	etag = `"v2"`
+	if err := src.Update(""); err != nil || src.Revision() == first {
+		t.Fatalf("expected a new revision, got %s %v", src.Revision(), err)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(src.Dir(), "clouds", "b.yml")); err != nil {
+		t.Error(err)
+	}
+	if _, err := ioutil.ReadDir(previous); err == nil {
+		t.Errorf("expected %s to be removed", previous)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestHTTPInvalidBundle(t *testing.T) {
+	log.InitLoggers(false)
+	bundle := tarball(t, map[string]string{"../policy.yaml": "predicates: []\n"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(bundle)
+	}))
+	defer server.Close()
+
+	src := NewHTTP(server.URL, 0)
+	if err := src.Update(""); err == nil || src.Dir() != "" {
+		t.Errorf("expected a path outside of the directory to be refused, got %v %s", err, src.Dir())
+	}
+	if err := NewHTTP(server.URL+"/missing", 0).Update(""); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
@@ -0,0 +1,102 @@
+// Package source provides the places the config of the scheduler is read from:
+// the git repository, a local directory or a tarball fetched over HTTP.
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of the sources
+const (
+	KindGit = "git"
+	KindDir = "dir"
+	KindHTTP = "http"
+)
+
+// ErrUnchanged is returned by Update when the config didn't change.
+var ErrUnchanged = errors.New("the config didn't change")
+
+// Source is where the config files come from. The config is read from the local
+// directory Dir, which is updated from the source by Update.
+type Source interface {
+	// Kind returns the kind of the source: KindGit, KindDir or KindHTTP.
+	Kind() string
+	// Dir returns the local directory containing the config files.
+	Dir() string
+	// Revision returns the version of the files of Dir, for example the commit hash.
+	// It's empty before the first Update.
+	Revision() string
+	// Update updates Dir from the source to the revision, the latest one if it's empty.
+	// Only git updates to a given revision, the other sources update to the latest one.
+	// It returns nil only if the config changed, ErrUnchanged or the error of the update otherwise.
+	Update(revision string) error
+	// Watch calls changed each time the source may have changed, until ctx is done.
+	// It returns immediately if the source is not watched, like git, updated on the
+	// pull requests of the API, the webhooks and the polling.
+	Watch(ctx context.Context, changed func())
+}
+
+// hashDir returns the SHA-256 of the paths and the contents of the files of dir, sorted by path.
+func hashDir(dir string) (string, error) {
+	files := []string{}
+	if err := listFiles(dir, "", nil, &files); err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, rel := range files {
+		io.WriteString(h, rel+"\x00")
+		f, err := os.Open(filepath.Join(dir, rel))
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// listFiles appends the paths of the files of the directory rel of dir to files,
+// and the paths of the subdirectories to dirs if it's not nil. The hidden files are ignored, and the symbolic links are followed, like in a Kubernetes
+// ConfigMap volume where the files are links to the hidden directory '..data'.
+func listFiles(dir string, rel string, dirs *[]string, files *[]string) error {
+	entries, err := ioutil.ReadDir(filepath.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		p := filepath.Join(rel, entry.Name())
+		info, err := os.Stat(filepath.Join(dir, p))
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if dirs != nil {
+				*dirs = append(*dirs, p)
+			}
+			if err := listFiles(dir, p, dirs, files); err != nil {
+				return err
+			}
+			continue
+		}
+		*files = append(*files, p)
+	}
+	return nil
+}
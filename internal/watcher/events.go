@@ -0,0 +1,54 @@
+package watcher
+
+import(
+	"context"
+	"github.com/redhat-gpe/agnostics/internal/events"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"time"
+)
+
+// eventReadTimeout is how long ConsumeEventQueue blocks waiting for new events.
+const eventReadTimeout = 5 * time.Second
+
+// ConsumeEventQueue function reads the new events from the redis stream 'events'
+// and broadcasts them to the local subscribers, for example the console
+// or the API event stream, until ctx is done.
+// It starts after the last event of the stream, and resumes after the last event read
+// when redis is reconnected, so no event is skipped.
+func ConsumeEventQueue(ctx context.Context) {
+	setStatus(events.Stream, kindStream, false, nil)
+	conn, err := db.ReconnectContext(ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+	setStatus(events.Stream, kindStream, true, nil)
+
+	// Empty until the start is resolved: "$" would skip the events published during a reconnection
+	lastID := ""
+	for ctx.Err() == nil {
+		var newEvents []v1.Event
+		if lastID == "" {
+			lastID, err = events.LastID(conn)
+		} else {
+			newEvents, err = events.Read(conn, lastID, 100, eventReadTimeout)
+		}
+		if err != nil {
+			log.Debug.Println(err)
+			setStatus(events.Stream, kindStream, false, err)
+			conn.Close()
+			if conn, err = db.ReconnectContext(ctx); err != nil {
+				return
+			}
+			setStatus(events.Stream, kindStream, true, nil)
+			continue
+		}
+		for _, e := range newEvents {
+			log.Debug.Printf("stream %s: event %s %s\n", events.Stream, e.ID, e.Type)
+			lastID = e.ID
+			events.Broadcast(e)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import(
+	"context"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/git"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/metrics"
+	"time"
+)
+
+// pollLockKey is the redis key held by the replica polling the repository.
+const pollLockKey = "repo:poll"
+
+var pollsTotal = metrics.NewCounter("agnostics_git_polls_total", "The number of checks of the remote repository, by result: unchanged, changed or error.", "result")
+
+// PollRepository checks the remote repository every interval, until ctx is done.
+// When the tracked ref moved, a pull of its commit is requested, so all the replicas reload the config.
+// The replicas share the polling: at each interval, only the first one polls.
+// A zero interval disables the polling.
+func PollRepository(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	log.Out.Println("Polling the config repository every", interval)
+	owner := hostname()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Held a bit less than the interval, so the next tick of the same replica gets it again.
+		if acquirePollLock(owner, interval - interval / 10) {
+			pollRepository()
+		}
+	}
+}
+
+// acquirePollLock returns true if this replica polls for the duration ttl.
+func acquirePollLock(owner string, ttl time.Duration) bool {
+	conn, err := db.Dial()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	reply, err := conn.Do("SET", pollLockKey, owner, "NX", "PX", ttl.Milliseconds())
+	if err != nil {
+		log.Err.Println("acquirePollLock", err)
+		return false
+	}
+	return reply != nil
+}
+
+// pollRepository requests a pull if the tracked ref moved in the remote repository.
+// Nothing is done while the config is pinned to a commit.
+func pollRepository() {
+	if git.Pinned() != "" {
+		log.Debug.Println("pollRepository: pinned to", git.Pinned())
+		return
+	}
+	remote, moved, err := git.RemoteMoved()
+	if err != nil {
+		log.Err.Println("pollRepository", err)
+		pollsTotal.Inc("error")
+		return
+	}
+	if ! moved {
+		log.Debug.Println("pollRepository: up-to-date at", remote)
+		pollsTotal.Inc("unchanged")
+		return
+	}
+	log.Out.Println("pollRepository: the tracked ref moved to", remote, "requesting a pull")
+	pollsTotal.Inc("changed")
+	RequestPullCommit(remote)
+}
@@ -0,0 +1,147 @@
+package watcher
+
+import(
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/gomodule/redigo/redis"
+	"os"
+	"sort"
+	"time"
+)
+
+// replicasKey is the redis set of the names of the replicas. The status of each one is
+// at replicasKey:<name>, and expires if the replica stops reporting it.
+const replicasKey = "repo:replicas"
+
+// Every reportInterval, the replicas report the config they loaded, see reportReplica.
+// The report expires after reportTTL.
+const (
+	reportInterval = time.Minute
+	reportTTL = 5 * time.Minute
+)
+
+// hostname returns the name of the replica, its hostname.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "scheduler"
+	}
+	return name
+}
+
+// reportReplica saves in redis the revision of the config loaded by the replica.
+// updateErr is the error of the last update of the config source, if it failed.
+func reportReplica(updateErr error) {
+	status := v1.ReplicaStatus{
+		Name: hostname(),
+		Revision: config.Current().Revision,
+		ReportTimestamp: time.Now().UTC(),
+	}
+	if t := config.LoadTimestamp(); ! t.IsZero() {
+		t = t.UTC()
+		status.LoadTimestamp = &t
+	}
+	if updateErr != nil {
+		status.Error = updateErr.Error()
+	} else if _, err := config.LastLoadError(); err != nil {
+		status.Error = err.Error()
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		log.Err.Println("reportReplica", err)
+		return
+	}
+
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis. Replica status not reported.")
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Do("SET", replicasKey+":"+status.Name, b, "EX", int(reportTTL.Seconds())); err != nil {
+		log.Err.Println("reportReplica", err)
+		return
+	}
+	if _, err := conn.Do("SADD", replicasKey, status.Name); err != nil {
+		log.Err.Println("reportReplica", err)
+	}
+}
+
+// Replicas returns the config loaded by the replicas that reported it recently, sorted by name.
+// A replica diverges if it didn't load the expected revision, see markDiverged.
+func Replicas() ([]v1.ReplicaStatus, error) {
+	conn, err := db.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	names, err := redis.Strings(conn.Do("SMEMBERS", replicasKey))
+	if err != nil {
+		return nil, err
+	}
+	replicas := []v1.ReplicaStatus{}
+	for _, name := range names {
+		b, err := redis.Bytes(conn.Do("GET", replicasKey+":"+name))
+		if err == redis.ErrNil {
+			// The replica is gone
+			conn.Do("SREM", replicasKey, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		status := v1.ReplicaStatus{}
+		if err := json.Unmarshal(b, &status); err != nil {
+			log.Err.Println("Replicas: invalid status of", name, err)
+			continue
+		}
+		replicas = append(replicas, status)
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].Name < replicas[j].Name })
+
+	// The pinned commit, else the commit pulled last
+	expected := ""
+	for _, key := range []string{pinnedKey, targetKey} {
+		expected, err = redis.String(conn.Do("GET", key))
+		if err == nil {
+			break
+		}
+		if err != redis.ErrNil {
+			return nil, err
+		}
+	}
+	markDiverged(replicas, expected)
+	return replicas, nil
+}
+
+// markDiverged sets Diverged on the replicas that didn't load the revision expected.
+// If expected is empty, for example when the config isn't read from git, the revision
+// loaded by most replicas is expected. Nothing diverges if there is no majority.
+func markDiverged(replicas []v1.ReplicaStatus, expected string) {
+	if expected == "" {
+		count := map[string]int{}
+		for _, r := range replicas {
+			count[r.Revision]++
+		}
+		max := 0
+		for revision, n := range count {
+			switch {
+			case n > max:
+				expected = revision
+				max = n
+			case n == max:
+				expected = ""
+			}
+		}
+		if expected == "" {
+			return
+		}
+	}
+	for i := range replicas {
+		replicas[i].Diverged = replicas[i].Revision != expected
+	}
+}
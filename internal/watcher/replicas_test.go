@@ -0,0 +1,51 @@
+package watcher
+
+import(
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"testing"
+)
+
+func TestMarkDiverged(t *testing.T) {
+	replicas := func(revisions ...string) []v1.ReplicaStatus {
+		result := []v1.ReplicaStatus{}
+		for _, r := range revisions {
+			result = append(result, v1.ReplicaStatus{Revision: r})
+		}
+		return result
+	}
+	testCases := []struct {
+		name string
+		replicas []v1.ReplicaStatus
+		expected string
+		diverged []bool
+	}{
+		{"all at the commit pulled", replicas("a", "a"), "a", []bool{false, false}},
+		{"one behind the commit pulled", replicas("a", "b", "b"), "a", []bool{false, true, true}},
+		{"not loaded", replicas("a", ""), "a", []bool{false, true}},
+		{"majority", replicas("a", "b", "a"), "", []bool{false, true, false}},
+		{"no majority", replicas("a", "b"), "", []bool{false, false}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			markDiverged(tc.replicas, tc.expected)
+			for i, r := range tc.replicas {
+				if r.Diverged != tc.diverged[i] {
+					t.Errorf("expected replica %d at %q diverged=%v", i, r.Revision, tc.diverged[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsCommitHash(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"76ae82f3a0cb5c3a2c6ef1e27c43c6b8a0a1b1c4": true,
+		"0000000000000000000000000000000000000000": false,
+		"76ae82f": false,
+		"pull": false,
+	} {
+		if isCommitHash(s) != expected {
+			t.Errorf("expected isCommitHash(%q) to be %v", s, expected)
+		}
+	}
+}
@@ -1,13 +1,175 @@
 package watcher
 
 import(
+	"context"
+	"crypto/rand"
 	"github.com/redhat-gpe/agnostics/internal/git"
 	"github.com/redhat-gpe/agnostics/internal/log"
 	"github.com/redhat-gpe/agnostics/internal/config"
 	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/events"
+	"github.com/redhat-gpe/agnostics/internal/source"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
 	"github.com/gomodule/redigo/redis"
+	gogit "github.com/go-git/go-git/v5"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Duration of the keys deduplicating the config events. An event of a pull request is
+// identified by its message, the other ones, like the changes of a directory detected by
+// each replica, only by the revisions: they're deduplicated for a short time.
+const (
+	requestDedupTTL = time.Hour
+	transitionDedupTTL = time.Minute
+)
+
+// configEventKey returns the redis key deduplicating the event of type eventType about the reload
+// from the revision from to the revision to, requested by requestID if it's set, and its duration.
+func configEventKey(eventType string, from string, to string, requestID string) (string, time.Duration) {
+	key := "events:"+eventType+":"+from+":"+to
+	if requestID == "" {
+		return key, transitionDedupTTL
+	}
+	return key+":"+requestID, requestDedupTTL
+}
+
+// publishConfigEvent publishes the event e about the reload from the revision from to the
+// revision of the source. requestID is the pull request that triggered the reload, if any.
+// All the replicas load the config, but the event is published only once per reload.
+func publishConfigEvent(e v1.Event, from string, requestID string) {
+	src := config.GetSource()
+	revision := src.Revision()
+	if src.Kind() == source.KindGit {
+		gitCommit, err := git.NewGitCommit(git.GetRepo())
+		if err != nil {
+			log.Err.Println("publishConfigEvent", err)
+			return
+		}
+		e.GitCommit = &gitCommit
+	}
+
+	conn, err :=  db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis. Event", e.Type, "not published.")
+		return
+	}
+	defer conn.Close()
+
+	key, ttl := configEventKey(e.Type, from, revision, requestID)
+	reply, err := conn.Do("SET", key, 1, "NX", "EX", int(ttl.Seconds()))
+	if err != nil {
+		log.Err.Println("publishConfigEvent", err)
+		return
+	}
+	if reply == nil {
+		log.Debug.Println("publishConfigEvent: already published by another replica")
+		return
+	}
+	events.Publish(e)
+}
+
+// pinnedKey is the redis key of the commit the config is pinned to, shared by the replicas.
+const pinnedKey = "repo:pinned"
+
+// targetKey is the redis key of the commit of the last pull requested, the one all the replicas
+// must check out. It's removed when the commit is unknown, see RequestPull.
+const targetKey = "repo:target"
+
+// pullMessage is the revision in the messages on 'repoMQ' requesting to update the config
+// source to the latest revision, see newPullMessage.
+const pullMessage = "pull"
+
+// newPullMessage returns the message on 'repoMQ' requesting to update the config source to the
+// revision: '<id> <revision>'. The id identifies the request, the same on all the replicas.
+func newPullMessage(revision string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Err.Println("newPullMessage", err)
+		return revision
+	}
+	return hex.EncodeToString(b)+" "+revision
+}
+
+// parsePullMessage returns the id of the request and the revision, empty for the latest one.
+func parsePullMessage(data string) (string, string) {
+	id := ""
+	if i := strings.IndexByte(data, ' '); i >= 0 {
+		id, data = data[:i], data[i+1:]
+	}
+	if data == pullMessage {
+		data = ""
+	}
+	return id, data
+}
+
+// SetPinned pins the config of all the replicas to the commit, or unpins it if hash is empty.
+// The replicas apply it with SyncRepository when they receive the pull request.
+// When unpinning, the pull is requested in the background, like RequestPull in the handlers:
+// resolving the tracked ref lists the references of the remote repository.
+func SetPinned(hash string) error {
+	conn, err := db.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if hash == "" {
+		if _, err := conn.Do("DEL", pinnedKey); err != nil {
+			return err
+		}
+		go RequestPull()
+		return nil
+	}
+	if _, err := conn.Do("SET", pinnedKey, hash); err != nil {
+		return err
+	}
+	_, err = conn.Do("PUBLISH", "repoMQ", newPullMessage(hash))
+	return err
+}
+
+// getPinned returns the commit the config is pinned to in redis, empty if it's not pinned.
+func getPinned() (string, error) {
+	conn, err := db.Dial()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	hash, err := redis.String(conn.Do("GET", pinnedKey))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+	return hash, err
+}
+
+// SyncRepository checks out the commit the config is pinned to, or the commit hash of the
+// tracked ref if it's not pinned, the latest one if hash is empty.
+// It returns nil if HEAD changed and the config must be reloaded.
+func SyncRepository(hash string) error {
+	pinned, err := getPinned()
+	if err != nil {
+		log.Err.Println("Cannot get the pinned commit from redis.", err)
+		return err
+	}
+	if pinned != "" {
+		err := git.Pin(pinned)
+		if err != nil && err != gogit.NoErrAlreadyUpToDate {
+			log.Err.Println("Cannot pin the config to", pinned, err)
+		}
+		return err
+	}
+	git.Unpin()
+	if hash != "" {
+		return git.UpdateTo(hash)
+	}
+	return git.RefreshRepository()
+}
+
+// RequestPull requests all the replicas to update the config source. For git, the commit
+// of the tracked ref is resolved once, so all the replicas check out the same commit.
 func RequestPull() {
 	conn, err :=  db.Dial()
 	if err != nil {
@@ -16,33 +178,183 @@ func RequestPull() {
 	}
 	defer conn.Close()
 
-	conn.Do("PUBLISH", "repoMQ", "pull")
+	requestPull(conn)
 }
 
-// ConsumePullQueue function watches the message Queue 'repoMQ' in redis
-// and executes RefreshRepository when there is a request with
-// a delay of 10 seconds between each call.
-// The goal is to avoid spamming github (or whatever the provider).
-func ConsumePullQueue() {
-	conn :=  db.ReconnectPubSub()
+// RequestPullCommit requests all the replicas to check out the commit hash of the tracked ref,
+// for example the commit pushed. The latest commit is pulled if hash isn't a commit hash,
+// or if it would roll the config back, see git.CheckPushed.
+func RequestPullCommit(hash string) {
+	conn, err :=  db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis. Repo not updated.")
+		return
+	}
 	defer conn.Close()
 
-	conn.Subscribe("repoMQ")
-	defer conn.Unsubscribe("repoMQ")
+	if ! isCommitHash(hash) {
+		requestPull(conn)
+		return
+	}
+	// An old push, delivered again or out of order, must not roll the config back
+	if err := git.CheckPushed(hash); err != nil {
+		log.Out.Println("Commit", hash, "not pulled:", err, "- pulling the latest commit")
+		requestPull(conn)
+		return
+	}
+	publishPull(conn, hash)
+}
+
+func requestPull(conn redis.Conn) {
+	hash := ""
+	if src := config.GetSource(); src != nil && src.Kind() == source.KindGit {
+		remote, err := git.RemoteHead()
+		if err != nil {
+			log.Err.Println("Cannot resolve the tracked ref, pulling the latest commit.", err)
+		}
+		hash = remote
+	}
+	publishPull(conn, hash)
+}
+
+// publishPull saves the commit hash as the one expected on all the replicas
+// and publishes it on 'repoMQ', or pullMessage if it's empty.
+func publishPull(conn redis.Conn, hash string) {
+	if hash == "" {
+		conn.Do("DEL", targetKey)
+		conn.Do("PUBLISH", "repoMQ", newPullMessage(pullMessage))
+		return
+	}
+	if _, err := conn.Do("SET", targetKey, hash); err != nil {
+		log.Err.Println("publishPull", err)
+	}
+	conn.Do("PUBLISH", "repoMQ", newPullMessage(hash))
+}
+
+// isCommitHash returns true if s is the full hash of a commit. The webhooks send
+// zeros when a ref is deleted.
+func isCommitHash(s string) bool {
+	if len(s) != 40 || s == strings.Repeat("0", 40) {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// gitSource is the config repository. It's updated by SyncRepository
+// when a pull is requested on 'repoMQ', see RequestPull and RequestPullCommit.
+type gitSource struct{}
+
+// NewGitSource returns the source reading the config from the cloned repository.
+func NewGitSource() source.Source {
+	return gitSource{}
+}
+
+func (gitSource) Kind() string {
+	return source.KindGit
+}
+
+func (gitSource) Dir() string {
+	return git.GetRepoDir()
+}
+
+func (gitSource) Revision() string {
+	if git.GetRepo() == nil {
+		return ""
+	}
+	head, err := git.GetRepoHeadCommit()
+	if err != nil {
+		return ""
+	}
+	return head.Hash.String()
+}
+
+func (gitSource) Update(revision string) error {
+	return SyncRepository(revision)
+}
+
+// Watch returns immediately: the pulls are requested by the API, the webhooks and the polling.
+func (gitSource) Watch(ctx context.Context, changed func()) {}
+
+// ConsumeSource updates the config source and reloads the config when it changed,
+// until ctx is done. An update is done when src detects a change, and when
+// a pull is requested on the message Queue 'repoMQ' in redis, see RequestPull.
+// The message is the revision to update to, so all the replicas load the same one.
+// A reload in progress is completed first. The replica reports the revision it loaded
+// after each update and every reportInterval, see Replicas.
+func ConsumeSource(ctx context.Context, src source.Source) {
+	changes := make(chan struct{}, 1)
+	// pending is the revision of the next update, empty for the latest one, and the id
+	// of the pull request, if any. A request replaces the pending one, if any.
+	var pending struct {
+		sync.Mutex
+		id string
+		revision string
+	}
+	request := func(id string, revision string) {
+		pending.Lock()
+		pending.id = id
+		pending.revision = revision
+		pending.Unlock()
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		src.Watch(ctx, func() { request("", "") })
+	}()
+	go func() {
+		defer wg.Done()
+		subscribe(ctx, "repoMQ", func(m redis.Message) {
+			request(parsePullMessage(string(m.Data)))
+		})
+	}()
+	defer wg.Wait()
+
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+	var updateErr error
+	reportReplica(nil)
 	for {
-		switch v := conn.Receive().(type) {
-		case redis.Message:
-			log.Debug.Printf("channel %s: message: %s\n", v.Channel, v.Data)
-			if err := git.RefreshRepository(); err == nil {
-				config.Load()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reportReplica(updateErr)
+		case <-changes:
+			pending.Lock()
+			id, revision := pending.id, pending.revision
+			pending.Unlock()
+
+			err := src.Update(revision)
+			switch err {
+			case nil:
+				updateErr = nil
+			case source.ErrUnchanged, gogit.NoErrAlreadyUpToDate:
+				updateErr = nil
+				reportReplica(nil)
+				continue
+			default:
+				updateErr = err
+				reportReplica(err)
+				continue
+			}
+			from := config.Current().Revision
+			if err := config.Load(); err != nil {
+				e := events.New(v1.EventConfigLoadFailed)
+				e.Error = err.Error()
+				publishConfigEvent(e, from, id)
+			} else {
+				e := events.New(v1.EventConfigReloaded)
+				e.Changes = config.LastChanges()
+				publishConfigEvent(e, from, id)
 			}
-		case redis.Subscription:
-			log.Debug.Printf("channel %s: %s %d\n", v.Channel, v.Kind, v.Count)
-			continue
-		case error:
-			log.Debug.Println(v)
-			conn = db.ReconnectPubSub()
-			conn.Subscribe("repoMQ")
+			reportReplica(nil)
 		}
 	}
 }
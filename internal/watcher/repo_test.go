@@ -0,0 +1,38 @@
+package watcher
+
+import(
+	"strings"
+	"testing"
+)
+
+func TestPullMessage(t *testing.T) {
+	hash := strings.Repeat("a", 40)
+	first, second := newPullMessage(hash), newPullMessage(hash)
+	if first == second {
+		t.Errorf("expected the requests to have different ids, got %s", first)
+	}
+	if id, revision := parsePullMessage(first); id == "" || revision != hash {
+		t.Errorf("expected an id and %s, got %q %q", hash, id, revision)
+	}
+	if id, revision := parsePullMessage(newPullMessage(pullMessage)); id == "" || revision != "" {
+		t.Errorf("expected an id and the latest revision, got %q %q", id, revision)
+	}
+	// The messages of the previous versions
+	if id, revision := parsePullMessage(pullMessage); id != "" || revision != "" {
+		t.Errorf("expected the latest revision without id, got %q %q", id, revision)
+	}
+}
+
+// TestConfigEventKey checks that a reload back to a revision is published again.
+func TestConfigEventKey(t *testing.T) {
+	pinned, _ := configEventKey("config.reloaded", "b", "a", "1")
+	again, ttl := configEventKey("config.reloaded", "b", "a", "2")
+	if pinned == again || ttl != requestDedupTTL {
+		t.Errorf("expected a key per request, got %s %s %v", pinned, again, ttl)
+	}
+	back, _ := configEventKey("config.reloaded", "a", "b", "")
+	forth, ttl := configEventKey("config.reloaded", "b", "a", "")
+	if back == forth || ttl != transitionDedupTTL {
+		t.Errorf("expected a key per transition, got %s %s %v", back, forth, ttl)
+	}
+}
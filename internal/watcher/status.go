@@ -0,0 +1,52 @@
+package watcher
+
+import(
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of subscriptions
+const (
+	kindChannel = "channel"
+	kindStream = "stream"
+)
+
+// subscriptions is the status of the channels and streams the consumers listen to, see Subscriptions.
+var subscriptions = struct {
+	sync.Mutex
+	byName map[string]v1.SubscriptionStatus
+}{byName: map[string]v1.SubscriptionStatus{}}
+
+// setStatus records whether the subscription name is connected.
+// err is why it's disconnected, if known.
+func setStatus(name string, kind string, connected bool, err error) {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	s, ok := subscriptions.byName[name]
+	if ! ok || s.Connected != connected {
+		s.Since = time.Now().UTC()
+	}
+	s.Name = name
+	s.Kind = kind
+	s.Connected = connected
+	s.Error = ""
+	if err != nil {
+		s.Error = err.Error()
+	}
+	subscriptions.byName[name] = s
+}
+
+// Subscriptions returns the status of the channels and streams the consumers listen to, sorted by name.
+// A consumer appears once it's started.
+func Subscriptions() []v1.SubscriptionStatus {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	result := []v1.SubscriptionStatus{}
+	for _, s := range subscriptions.byName {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,62 @@
+package watcher
+
+import(
+	"context"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/gomodule/redigo/redis"
+)
+
+// subscribe calls handle for each message published on the redis channel,
+// reconnecting when the connection is lost, until ctx is done.
+// A message being handled when ctx is done is handled completely before returning.
+func subscribe(ctx context.Context, channel string, handle func(redis.Message)) {
+	setStatus(channel, kindChannel, false, nil)
+	for {
+		conn, err := db.ReconnectPubSubContext(ctx)
+		if err != nil {
+			return
+		}
+		conn.Subscribe(channel)
+
+		// Unsubscribing makes Receive return, once the current message is handled.
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.Unsubscribe(channel)
+			case <-done:
+			}
+		}()
+
+		err = receive(conn, handle)
+		setStatus(channel, kindChannel, false, err)
+		close(done)
+		conn.Close()
+		if ctx.Err() != nil {
+			log.Debug.Printf("channel %s: stopped\n", channel)
+			return
+		}
+	}
+}
+
+// receive handles the messages until the channel is unsubscribed or the connection fails.
+// It returns the error of the connection, nil if the channel is unsubscribed.
+func receive(conn redis.PubSubConn, handle func(redis.Message)) error {
+	for {
+		switch v := conn.Receive().(type) {
+		case redis.Message:
+			log.Debug.Printf("channel %s: message: %s\n", v.Channel, v.Data)
+			handle(v)
+		case redis.Subscription:
+			log.Debug.Printf("channel %s: %s %d\n", v.Channel, v.Kind, v.Count)
+			if v.Count == 0 {
+				return nil
+			}
+			setStatus(v.Channel, kindChannel, true, nil)
+		case error:
+			log.Debug.Println(v)
+			return v
+		}
+	}
+}
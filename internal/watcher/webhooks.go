@@ -0,0 +1,104 @@
+package watcher
+
+import(
+	"context"
+	"github.com/redhat-gpe/agnostics/internal/events"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/webhook"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"time"
+)
+
+// webhookGroup is the redis consumer group of the replicas delivering webhooks.
+const webhookGroup = "webhooks"
+
+// claimInterval is how often the events pending in the group are claimed, see events.Claim.
+const claimInterval = 5 * time.Minute
+
+// ConsumeWebhookQueue function reads the events from the redis stream 'events'
+// and delivers them to the webhooks.
+// The replicas share a consumer group, so each event is delivered by only one replica.
+// An event is acknowledged once all its deliveries are finished, delivered or saved as dead
+// letters. The events not acknowledged by a replica that stopped or crashed are claimed
+// and delivered again, at startup and every claimInterval.
+// It stops when ctx is done.
+func ConsumeWebhookQueue(ctx context.Context) {
+	consumer := hostname()
+	deliverer := webhook.NewDeliverer()
+	defer deliverer.Stop()
+	// Longer than a delivery in progress
+	claimMinIdle := deliverer.MaxDuration() + time.Minute
+
+	setStatus(events.Stream+"/"+webhookGroup, kindStream, false, nil)
+	conn, err := db.ReconnectContext(ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+	setStatus(events.Stream+"/"+webhookGroup, kindStream, true, nil)
+	if err := events.CreateGroup(conn, webhookGroup); err != nil {
+		log.Err.Println("ConsumeWebhookQueue", err)
+	}
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		var newEvents []v1.Event
+		if time.Since(lastClaim) >= claimInterval {
+			newEvents, err = events.Claim(conn, webhookGroup, consumer, claimMinIdle, 100)
+			if err == nil {
+				lastClaim = time.Now()
+				if len(newEvents) > 0 {
+					log.Out.Println("ConsumeWebhookQueue: delivering", len(newEvents), "events not acknowledged")
+				}
+			}
+		}
+		if err == nil && len(newEvents) == 0 {
+			newEvents, err = events.ReadGroup(conn, webhookGroup, consumer, 100, eventReadTimeout)
+		}
+		if err != nil {
+			log.Debug.Println(err)
+			setStatus(events.Stream+"/"+webhookGroup, kindStream, false, err)
+			conn.Close()
+			if conn, err = db.ReconnectContext(ctx); err != nil {
+				return
+			}
+			setStatus(events.Stream+"/"+webhookGroup, kindStream, true, nil)
+			if err := events.CreateGroup(conn, webhookGroup); err != nil {
+				log.Err.Println("ConsumeWebhookQueue", err)
+			}
+			continue
+		}
+		if len(newEvents) == 0 {
+			continue
+		}
+		webhooks, err := webhook.GetAll()
+		if err != nil {
+			log.Err.Println("ConsumeWebhookQueue", err)
+		}
+		for _, e := range newEvents {
+			id := e.ID
+			deliverer.Dispatch(webhooks, e, func(abandoned bool) {
+				if abandoned {
+					log.Out.Println("ConsumeWebhookQueue: event", id, "not acknowledged, it will be delivered again")
+					return
+				}
+				ackEvent(id)
+			})
+		}
+	}
+}
+
+// ackEvent acknowledges the event delivered to the webhooks, on its own connection:
+// the deliveries finish while the consumer waits for the next events.
+func ackEvent(id string) {
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis. Event", id, "not acknowledged.")
+		return
+	}
+	defer conn.Close()
+	if err := events.Ack(conn, webhookGroup, id); err != nil {
+		log.Err.Println("ackEvent", err)
+	}
+}
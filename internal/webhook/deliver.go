@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the header containing the HMAC-SHA256 signature of the payload,
+// in the format 'sha256=<hex>'.
+const SignatureHeader = "X-Agnostics-Signature"
+
+// EventHeader is the header containing the type of the event.
+const EventHeader = "X-Agnostics-Event"
+
+// DeliveryHeader is the header containing the ID of the event.
+const DeliveryHeader = "X-Agnostics-Delivery"
+
+// ErrStopped is returned by Deliver when the retries are abandoned because the Deliverer is stopping.
+var ErrStopped = errors.New("retries abandoned, the scheduler is stopping")
+
+// Sign returns the value of the signature header for the payload.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliverer POSTs events to webhooks, with retries.
+type Deliverer struct {
+	Client *http.Client
+	// MaxAttempts is the number of attempts before giving up.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles for each retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between two attempts.
+	MaxBackoff time.Duration
+	// DeadLetter is called when the delivery failed after all the attempts.
+	DeadLetter func(v1.WebhookDeadLetter) error
+	// MaxConcurrent is the maximum number of deliveries in progress, defaultMaxConcurrent if zero.
+	MaxConcurrent int
+
+	mutex sync.Mutex
+	stop chan struct{}
+	slots chan struct{}
+	stopOnce sync.Once
+	deliveries sync.WaitGroup
+}
+
+// defaultMaxConcurrent is the maximum number of deliveries in progress if MaxConcurrent is zero.
+const defaultMaxConcurrent = 20
+
+// NewDeliverer is the constructor for a Deliverer with the default retry policy.
+// Failed deliveries are saved in the redis dead-letter list.
+func NewDeliverer() *Deliverer {
+	return &Deliverer{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 6,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff: 5 * time.Minute,
+		DeadLetter: SaveDeadLetter,
+		MaxConcurrent: defaultMaxConcurrent,
+	}
+}
+
+// post sends the payload once.
+func (d *Deliverer) post(w v1.Webhook, e v1.Event, payload []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(DeliveryHeader, e.ID)
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, payload))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Deliver POSTs the event to the webhook. It retries with exponential backoff
+// and saves a dead letter if all the attempts failed.
+// If the Deliverer is stopped while waiting for a retry, the delivery is abandoned without
+// dead letter and ErrStopped is returned: the event must be delivered again.
+// This function is blocking until the delivery succeeds or is abandoned.
+func (d *Deliverer) Deliver(w v1.Webhook, e v1.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := d.InitialBackoff
+	attempt := 1
+	for ; ; attempt++ {
+		err = d.post(w, e, payload)
+		if err == nil {
+			log.Debug.Println("webhook", w.ID, "event", e.ID, e.Type, "delivered")
+			return nil
+		}
+		log.Err.Printf("webhook %s: event %s delivery attempt %d/%d failed: %v\n", w.ID, e.ID, attempt, d.MaxAttempts, err)
+		if attempt >= d.MaxAttempts {
+			break
+		}
+		select {
+		case <-d.stopped():
+			log.Err.Printf("webhook %s: event %s: %v\n", w.ID, e.ID, ErrStopped)
+			return ErrStopped
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+
+	if d.DeadLetter != nil {
+		d.DeadLetter(v1.WebhookDeadLetter{
+			Webhook: w,
+			Event: e,
+			Attempts: attempt,
+			LastError: err.Error(),
+			CreationTimestamp: time.Now().UTC().Round(time.Second),
+		})
+	}
+	return err
+}
+
+// MaxDuration returns the longest duration of a delivery, with all the attempts timing out.
+func (d *Deliverer) MaxDuration() time.Duration {
+	duration := time.Duration(d.MaxAttempts) * d.Client.Timeout
+	backoff := d.InitialBackoff
+	for attempt := 1; attempt < d.MaxAttempts; attempt++ {
+		duration = duration + backoff
+		backoff = backoff * 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+	return duration
+}
+
+// Dispatch delivers the event to all the matching webhooks, concurrently.
+// done, if not nil, is called once all the deliveries are finished, delivered or saved as dead
+// letters. abandoned is true if a delivery was abandoned by Stop: the event must be delivered again.
+// It blocks while MaxConcurrent deliveries are in progress, so the events wait in the stream
+// instead of in goroutines.
+func (d *Deliverer) Dispatch(webhooks []v1.Webhook, e v1.Event, done func(abandoned bool)) {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	abandoned := false
+	slots := d.concurrencySlots()
+	for _, w := range webhooks {
+		if w.MatchEvent(e) {
+			select {
+			case slots <- struct{}{}:
+			case <-d.stopped():
+				log.Err.Printf("webhook %s: event %s: %v\n", w.ID, e.ID, ErrStopped)
+				mutex.Lock()
+				abandoned = true
+				mutex.Unlock()
+				continue
+			}
+			wg.Add(1)
+			d.deliveries.Add(1)
+			go func(w v1.Webhook) {
+				defer d.deliveries.Done()
+				defer wg.Done()
+				defer func() { <-slots }()
+				if d.Deliver(w, e) == ErrStopped {
+					mutex.Lock()
+					abandoned = true
+					mutex.Unlock()
+				}
+			}(w)
+		}
+	}
+	if done == nil {
+		return
+	}
+	d.deliveries.Add(1)
+	go func() {
+		defer d.deliveries.Done()
+		wg.Wait()
+		mutex.Lock()
+		defer mutex.Unlock()
+		done(abandoned)
+	}()
+}
+
+// concurrencySlots returns the channel holding a value per delivery in progress.
+func (d *Deliverer) concurrencySlots() chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.slots == nil {
+		max := d.MaxConcurrent
+		if max <= 0 {
+			max = defaultMaxConcurrent
+		}
+		d.slots = make(chan struct{}, max)
+	}
+	return d.slots
+}
+
+// stopped returns the channel closed by Stop.
+func (d *Deliverer) stopped() chan struct{} {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.stop == nil {
+		d.stop = make(chan struct{})
+	}
+	return d.stop
+}
+
+// Stop abandons the retries of the deliveries in progress, and waits for the deliveries
+// started by Dispatch and their done functions.
+func (d *Deliverer) Stop() {
+	d.stopOnce.Do(func() { close(d.stopped()) })
+	d.deliveries.Wait()
+}
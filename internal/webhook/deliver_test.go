@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDeliverer returns a Deliverer with short delays that records the dead letters.
+func testDeliverer(deadLetters *[]v1.WebhookDeadLetter) *Deliverer {
+	return &Deliverer{
+		Client: &http.Client{Timeout: time.Second},
+		MaxAttempts: 3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond,
+		DeadLetter: func(d v1.WebhookDeadLetter) error {
+			*deadLetters = append(*deadLetters, d)
+			return nil
+		},
+	}
+}
+
+func TestDeliverSignature(t *testing.T) {
+	log.InitLoggers(false)
+	secret := "s3cr3t"
+	var mutex sync.Mutex
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received++
+		body, _ := ioutil.ReadAll(r.Body)
+		if sig := r.Header.Get(SignatureHeader); sig != Sign(secret, body) {
+			t.Errorf("invalid signature %q", sig)
+		}
+		if e := r.Header.Get(EventHeader); e != v1.EventTaintAdded {
+			t.Errorf("expected event header %s, got %s", v1.EventTaintAdded, e)
+		}
+		if id := r.Header.Get(DeliveryHeader); id != "1602688473021-0" {
+			t.Errorf("expected delivery header 1602688473021-0, got %s", id)
+		}
+	}))
+	defer receiver.Close()
+
+	deadLetters := []v1.WebhookDeadLetter{}
+	d := testDeliverer(&deadLetters)
+	w := v1.Webhook{ID: "test", URL: receiver.URL, Secret: secret}
+	e := v1.Event{ID: "1602688473021-0", Type: v1.EventTaintAdded}
+
+	if err := d.Deliver(w, e); err != nil {
+		t.Fatal(err)
+	}
+	if received != 1 {
+		t.Errorf("expected 1 delivery, got %d", received)
+	}
+	if len(deadLetters) != 0 {
+		t.Errorf("expected no dead letter, got %d", len(deadLetters))
+	}
+}
+
+func TestDeliverRetry(t *testing.T) {
+	log.InitLoggers(false)
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	deadLetters := []v1.WebhookDeadLetter{}
+	d := testDeliverer(&deadLetters)
+	w := v1.Webhook{ID: "test", URL: receiver.URL}
+
+	if err := d.Deliver(w, v1.Event{Type: v1.EventPlacementCreated}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+	if len(deadLetters) != 0 {
+		t.Errorf("expected no dead letter, got %d", len(deadLetters))
+	}
+}
+
+func TestDeliverDeadLetter(t *testing.T) {
+	log.InitLoggers(false)
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	deadLetters := []v1.WebhookDeadLetter{}
+	d := testDeliverer(&deadLetters)
+	w := v1.Webhook{ID: "test", URL: receiver.URL}
+
+	if err := d.Deliver(w, v1.Event{Type: v1.EventPlacementDeleted}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != d.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", d.MaxAttempts, attempts)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", len(deadLetters))
+	}
+	if deadLetters[0].Attempts != d.MaxAttempts || deadLetters[0].Event.Type != v1.EventPlacementDeleted {
+		t.Errorf("unexpected dead letter %+v", deadLetters[0])
+	}
+}
+
+func TestDeliverStop(t *testing.T) {
+	log.InitLoggers(false)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	var mutex sync.Mutex
+	deadLetters := []v1.WebhookDeadLetter{}
+	d := testDeliverer(&deadLetters)
+	d.InitialBackoff = time.Hour
+	d.MaxBackoff = time.Hour
+	d.DeadLetter = func(dl v1.WebhookDeadLetter) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		deadLetters = append(deadLetters, dl)
+		return nil
+	}
+
+	abandoned := make(chan bool, 1)
+	d.Dispatch([]v1.Webhook{{ID: "test", URL: receiver.URL}}, v1.Event{Type: v1.EventTaintAdded}, func(a bool) { abandoned <- a })
+
+	// Stop doesn't wait for the retries, the event must be delivered again
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop is waiting for the retries")
+	}
+	if a := <-abandoned; ! a {
+		t.Error("expected the delivery to be abandoned")
+	}
+	if len(deadLetters) != 0 {
+		t.Errorf("expected no dead letter, got %+v", deadLetters)
+	}
+}
+
+func TestDispatchDone(t *testing.T) {
+	log.InitLoggers(false)
+	var mutex sync.Mutex
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received++
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	deadLetters := []v1.WebhookDeadLetter{}
+	d := testDeliverer(&deadLetters)
+	webhooks := []v1.Webhook{
+		{ID: "ok", URL: receiver.URL},
+		{ID: "failing", URL: receiver.URL+"/failing"},
+		{ID: "other", URL: receiver.URL, Events: []string{v1.EventPlacementDeleted}},
+	}
+	done := make(chan bool, 1)
+	d.Dispatch(webhooks, v1.Event{Type: v1.EventTaintAdded}, func(abandoned bool) {
+		// All the deliveries are finished
+		mutex.Lock()
+		defer mutex.Unlock()
+		if received != 1 + d.MaxAttempts || len(deadLetters) != 1 {
+			t.Errorf("expected %d requests and a dead letter, got %d %d", 1 + d.MaxAttempts, received, len(deadLetters))
+		}
+		done <- abandoned
+	})
+	select {
+	case abandoned := <-done:
+		if abandoned {
+			t.Error("expected the event not to be abandoned")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected done to be called")
+	}
+
+	// No matching webhook
+	d.Dispatch(webhooks[2:], v1.Event{Type: v1.EventTaintAdded}, func(abandoned bool) { done <- abandoned })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected done to be called without webhook")
+	}
+	d.Stop()
+}
+
+func TestDispatchMaxConcurrent(t *testing.T) {
+	log.InitLoggers(false)
+	var mutex sync.Mutex
+	inProgress, maxInProgress, received := 0, 0, 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inProgress++
+		if inProgress > maxInProgress {
+			maxInProgress = inProgress
+		}
+		mutex.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mutex.Lock()
+		inProgress--
+		received++
+		mutex.Unlock()
+	}))
+	defer receiver.Close()
+
+	deadLetters := []v1.WebhookDeadLetter{}
+	d := testDeliverer(&deadLetters)
+	d.MaxConcurrent = 2
+	webhooks := []v1.Webhook{}
+	for _, id := range []string{"a", "b", "c"} {
+		webhooks = append(webhooks, v1.Webhook{ID: id, URL: receiver.URL})
+	}
+	done := make(chan bool, 3)
+	for i := 0; i < 3; i++ {
+		d.Dispatch(webhooks, v1.Event{Type: v1.EventTaintAdded}, func(abandoned bool) { done <- abandoned })
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected done to be called")
+		}
+	}
+	d.Stop()
+	if received != 9 || maxInProgress > 2 {
+		t.Errorf("expected 9 deliveries, 2 at most at the same time, got %d, %d at most", received, maxInProgress)
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	// 6 attempts of 10s, and 2+4+8+16+32s between them
+	if d := NewDeliverer().MaxDuration(); d != 60 * time.Second + 62 * time.Second {
+		t.Errorf("unexpected max duration %v", d)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"type":"taint.added"}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=206dd317478917b5caa209fe80d88b46b45f39a40ca03598770fec5a27f31ec4"
+	if r := Sign("secret", []byte(`{"type":"taint.added"}`)); r != expected {
+		t.Errorf("expected signature %s, got %s", expected, r)
+	}
+}
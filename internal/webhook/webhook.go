@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gomodule/redigo/redis"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/db"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"time"
+)
+
+// ErrWebhookNotFound is returned when the webhook doesn't exist.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// webhooksKey is the redis hash containing the webhooks registered through the API.
+const webhooksKey = "webhooks"
+
+// deadLetterKey is the redis list containing the failed deliveries, most recent first.
+const deadLetterKey = "webhooks:deadletter"
+
+// deadLetterMaxLen is the number of failed deliveries kept in the dead-letter list.
+const deadLetterMaxLen = 1000
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Create registers a new webhook. The ID, Source and CreationTimestamp are set.
+func Create(w v1.Webhook) (v1.Webhook, error) {
+	id, err := newID()
+	if err != nil {
+		return v1.Webhook{}, err
+	}
+	w.ID = id
+	w.Source = "api"
+	w.CreationTimestamp = time.Now().UTC().Round(time.Second)
+
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis:", err)
+		return v1.Webhook{}, err
+	}
+	defer conn.Close()
+
+	jsonText, err := json.Marshal(w)
+	if err != nil {
+		return v1.Webhook{}, err
+	}
+	if _, err := conn.Do("HSET", webhooksKey, w.ID, jsonText); err != nil {
+		log.Err.Println("webhook.Create(", w.ID, ")", err)
+		return v1.Webhook{}, err
+	}
+	return w, nil
+}
+
+// Delete unregisters the webhook id. Only the webhooks created through the API can be deleted.
+func Delete(id string) error {
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis:", err)
+		return err
+	}
+	defer conn.Close()
+
+	n, err := redis.Int(conn.Do("HDEL", webhooksKey, id))
+	if err != nil {
+		log.Err.Println("webhook.Delete(", id, ")", err)
+		return err
+	}
+	if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// GetAll returns all the webhooks, the ones from the config and the ones registered through the API.
+func GetAll() ([]v1.Webhook, error) {
+	result := append([]v1.Webhook{}, config.GetWebhooks()...)
+
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis:", err)
+		return result, err
+	}
+	defer conn.Close()
+
+	values, err := redis.StringMap(conn.Do("HGETALL", webhooksKey))
+	if err != nil {
+		log.Err.Println("webhook.GetAll", err)
+		return result, err
+	}
+	for id, v := range values {
+		var w v1.Webhook
+		if err := json.Unmarshal([]byte(v), &w); err != nil {
+			log.Err.Println("webhook.GetAll: cannot unmarshal", id, err)
+			continue
+		}
+		result = append(result, w)
+	}
+	return result, nil
+}
+
+// SaveDeadLetter records a failed delivery in the dead-letter list.
+// The secret of the webhook is not saved.
+func SaveDeadLetter(d v1.WebhookDeadLetter) error {
+	d.Webhook.Secret = ""
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis:", err)
+		return err
+	}
+	defer conn.Close()
+
+	jsonText, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Do("LPUSH", deadLetterKey, jsonText); err != nil {
+		log.Err.Println("webhook.SaveDeadLetter", err)
+		return err
+	}
+	if _, err := conn.Do("LTRIM", deadLetterKey, 0, deadLetterMaxLen-1); err != nil {
+		log.Err.Println("webhook.SaveDeadLetter", err)
+		return err
+	}
+	return nil
+}
+
+// GetDeadLetters returns the failed deliveries, most recent first.
+func GetDeadLetters() ([]v1.WebhookDeadLetter, error) {
+	result := []v1.WebhookDeadLetter{}
+	conn, err := db.Dial()
+	if err != nil {
+		log.Err.Println("Cannot connect to redis:", err)
+		return result, err
+	}
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("LRANGE", deadLetterKey, 0, -1))
+	if err != nil {
+		log.Err.Println("webhook.GetDeadLetters", err)
+		return result, err
+	}
+	for _, v := range values {
+		var d v1.WebhookDeadLetter
+		if err := json.Unmarshal(v, &d); err != nil {
+			log.Err.Println("webhook.GetDeadLetters", err)
+			continue
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
@@ -1,7 +1,9 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -38,6 +40,41 @@ func (c *Cloud) Taint (t Taint) {
 	c.Taints = append(c.Taints, t)
 }
 
+// ErrTaintKeyEffectRequired is returned by Validate when the key or the effect is missing.
+var ErrTaintKeyEffectRequired = errors.New("Taint must have 'key' and 'effect'.")
+
+// ErrTaintInvalidEffect is returned by Validate when the effect is not supported.
+var ErrTaintInvalidEffect = errors.New("Taint.effect must be 'NoSchedule' or 'PreferNoSchedule'.")
+
+// Validate checks that the taint can be applied to a cloud.
+func (t Taint) Validate() error {
+	if t.Key == "" || t.Effect == "" {
+		return ErrTaintKeyEffectRequired
+	}
+
+	if t.Effect != TaintEffectNoSchedule && t.Effect != TaintEffectPreferNoSchedule {
+		return ErrTaintInvalidEffect
+	}
+	return nil
+}
+
+// ParseTaint parses a taint from a string in the format returned by String():
+// '<key>=<value>:<effect>' or '<key>:<effect>'. The taint is not validated.
+func ParseTaint(s string) (Taint, error) {
+	t := NewTaint()
+	i := strings.LastIndex(s, ":")
+	if i == -1 {
+		return t, fmt.Errorf("invalid taint '%s', format is <key>=<value>:<effect> or <key>:<effect>", s)
+	}
+	t.Effect = s[i+1:]
+	keyValue := strings.SplitN(s[:i], "=", 2)
+	t.Key = keyValue[0]
+	if len(keyValue) == 2 {
+		t.Value = keyValue[1]
+	}
+	return t, nil
+}
+
 func NewTaint() Taint {
 	return Taint{
 		CreationTimestamp: time.Now().UTC().Round(time.Second),
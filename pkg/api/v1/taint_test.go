@@ -117,3 +117,88 @@ func TestMatchTaint(t *testing.T) {
 		}
 	}
 }
+
+func TestTaintValidate(t *testing.T) {
+	testCases := []struct {
+		description string
+		taint       Taint
+		expected    error
+	}{
+		{
+			description: "taint with key and NoSchedule effect is valid",
+			taint:       Taint{Key: "foo", Value: "bar", Effect: TaintEffectNoSchedule},
+			expected:    nil,
+		},
+		{
+			description: "taint with key and PreferNoSchedule effect is valid",
+			taint:       Taint{Key: "foo", Effect: TaintEffectPreferNoSchedule},
+			expected:    nil,
+		},
+		{
+			description: "taint without key is invalid",
+			taint:       Taint{Value: "bar", Effect: TaintEffectNoSchedule},
+			expected:    ErrTaintKeyEffectRequired,
+		},
+		{
+			description: "taint without effect is invalid",
+			taint:       Taint{Key: "foo"},
+			expected:    ErrTaintKeyEffectRequired,
+		},
+		{
+			description: "taint with unknown effect is invalid",
+			taint:       Taint{Key: "foo", Effect: "NoExecute"},
+			expected:    ErrTaintInvalidEffect,
+		},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.taint.Validate(); err != tc.expected {
+			t.Errorf("[%s] expected Validate() to return %v, got %v", tc.description, tc.expected, err)
+		}
+	}
+}
+
+func TestParseTaint(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    Taint
+		expectError bool
+	}{
+		{
+			input:    "foo=bar:NoSchedule",
+			expected: Taint{Key: "foo", Value: "bar", Effect: TaintEffectNoSchedule},
+		},
+		{
+			input:    "foo:PreferNoSchedule",
+			expected: Taint{Key: "foo", Effect: TaintEffectPreferNoSchedule},
+		},
+		{
+			input:    "foo=bar=baz:NoSchedule",
+			expected: Taint{Key: "foo", Value: "bar=baz", Effect: TaintEffectNoSchedule},
+		},
+		{
+			input:       "foo=bar",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		r, err := ParseTaint(tc.input)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("[%s] expected an error", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] unexpected error %v", tc.input, err)
+			continue
+		}
+		if !r.MatchTaint(tc.expected) || r.Value != tc.expected.Value {
+			t.Errorf("[%s] expected %s, got %s", tc.input, tc.expected, r)
+		}
+		if r.String() != tc.input {
+			t.Errorf("[%s] expected String() to return the input, got %s", tc.input, r.String())
+		}
+	}
+}
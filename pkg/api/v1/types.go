@@ -0,0 +1,366 @@
+package v1
+
+import("time")
+
+// The Cloud is the main object that the scheduler work with.
+type Cloud struct {
+	Name string `json:"name"`
+	Labels map[string]string `json:"labels"`
+	// Weight is usually not provided by config, but automatically filled
+	// by the scheduler later, depending on priorities configured.
+	// It's possible to add it to the config though, if needed.
+	Weight int `json:"weight"`
+	// Enabled defines if the cloud can be selected when loading the config. It's a top-level control. If it's set to false, then the cloud will not be used. It takes precedence over scheduling, thus over taints and tolerations.
+	// True by default.
+	Enabled bool `json:"enabled"`
+	// Taints are part of the mechanism to reduce the priority (effect=PreferNoSchedule)
+	// or set a cloud as unschedulable (effect=NoSchedule).
+	// The taints prevail over the labels or any other control.
+	// They can be bypass when requesting schedule to the scheduler by
+	// specifying tolerations. In other words, a taint allows a cloud to refuse
+	// deployment to be scheduled unless that deployment has a matching toleration.
+	// Taints are usually dynamic resources managed by the scheduler, but they can also
+	// be statically provided in the configuration.
+	Taints []Taint `json:"taints,omitempty"`
+}
+
+type Error struct {
+	Code int32 `json:"code"`
+	Message string `json:"message"`
+	// Errors lists the invalid fields of the request, if any.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type ScheduleQuery struct {
+	CloudSelector map[string]string `json:"cloud_selector"`
+	CloudPreference map[string]string `json:"cloud_preference"`
+	Tolerations []Toleration `json:"tolerations"`
+	UUID string `json:"uuid,omitempty"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type GitCommit struct {
+	Hash string `json:"hash"`
+	Author string `json:"author"`
+	Date string `json:"date"`
+	Origin string `json:"origin"`
+	// Ref is the branch or the tag tracked, for example 'refs/heads/main'.
+	// +optional
+	Ref string `json:"ref,omitempty"`
+	// Pinned is true if the config is pinned to the commit: the pulls are ignored until it's unpinned.
+	// +optional
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// RepoPin is the request to pin the config to a commit of the config repository.
+type RepoPin struct {
+	// Commit is the full hash of the commit.
+	Commit string `json:"commit"`
+}
+
+// ConfigChanges is what a reload changed in the config, compared to the previous one.
+// The taints are the static ones of the config files, not the ones of the API.
+type ConfigChanges struct {
+	// From and To are the revisions of the config source before and after the reload,
+	// the commit hashes for git.
+	// +optional
+	From string `json:"from,omitempty"`
+	// +optional
+	To string `json:"to,omitempty"`
+	// CreationTimestamp is when the config was reloaded.
+	CreationTimestamp time.Time `json:"creation_timestamp"`
+	// +optional
+	CloudsAdded []string `json:"clouds_added,omitempty"`
+	// +optional
+	CloudsRemoved []string `json:"clouds_removed,omitempty"`
+	// CloudsChanged are the clouds kept and changed, sorted by name.
+	// +optional
+	CloudsChanged []CloudChanges `json:"clouds_changed,omitempty"`
+	// +optional
+	Policy *PolicyChanges `json:"policy,omitempty"`
+}
+
+// CloudChanges is what a reload changed in a cloud.
+type CloudChanges struct {
+	Name string `json:"name"`
+	// Labels are the labels added, removed or changed, by key.
+	// +optional
+	Labels map[string]ValueChange `json:"labels,omitempty"`
+	// Enabled is the new value, if it was toggled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// +optional
+	TaintsAdded []Taint `json:"taints_added,omitempty"`
+	// +optional
+	TaintsRemoved []Taint `json:"taints_removed,omitempty"`
+}
+
+// PolicyChanges is what a reload changed in the policy.
+type PolicyChanges struct {
+	// +optional
+	PredicatesAdded []string `json:"predicates_added,omitempty"`
+	// +optional
+	PredicatesRemoved []string `json:"predicates_removed,omitempty"`
+	// Priorities are the weights of the priorities added, removed or changed, by name.
+	// +optional
+	Priorities map[string]ValueChange `json:"priorities,omitempty"`
+}
+
+// ValueChange is the previous and the new value of a label or a weight.
+type ValueChange struct {
+	// Old is missing if the value was added.
+	// +optional
+	Old *string `json:"old,omitempty"`
+	// New is missing if the value was removed.
+	// +optional
+	New *string `json:"new,omitempty"`
+}
+
+const(
+	// TaintEffectNoSchedule does not allow new deployments to be scheduled
+	// onto the cloud unless they tolerate the taint
+	TaintEffectNoSchedule string = "NoSchedule"
+	// TaintEffectPreferNoSchedule is like TaintEffectNoSchedule,
+	// but the scheduler tries not to schedule new deployments onto the cloud,
+	// rather than prohibiting new deployment from scheduling onto the cloud entirely.
+	TaintEffectPreferNoSchedule string = "PreferNoSchedule"
+
+	TolerationOpExists string = "Exists"
+	TolerationOpEqual  string = "Equal"
+)
+
+// Taint : The cloud this taint is attached to has the "effect" on any deployment that
+// does not tolerate the Taint.
+type Taint struct {
+	// Required. The taint key to be applied to a cloud.
+	Key string `json:"key"`
+	// The taint value corresponding to the taint key.
+	// +optional
+	Value string `json:"value,omitempty"`
+	// Required. The effect of the taint on deployments that do not tolerate the taint.
+	// Valid effects are NoSchedule, PreferNoSchedule.
+	Effect string `json:"effect"`
+	// CreationTimestamp the Taint was added.
+	// +optional
+	CreationTimestamp time.Time `json:"creation_timestamp,omitempty"`
+}
+
+// Toleration : The deployment this Toleration is attached to tolerates any taint that matches
+// the triple <key,value,effect> using the matching operator <operator>.
+type Toleration struct {
+	// Key is the taint key that the toleration applies to. Empty means match all taint keys.
+	// If the key is empty, operator must be Exists; this combination means to match all values and all keys.
+	// +optional
+	Key string `json:"key,omitempty"`
+	// Operator represents a key's relationship to the value.
+	// Valid operators are Exists and Equal. Defaults to Equal.
+	// Exists is equivalent to wildcard for value, so that a cloud can
+	// tolerate all taints of a particular category.
+	// +optional
+	Operator string `json:"operator,omitempty"`
+	// Value is the taint value the toleration matches to.
+	// If the operator is Exists, the value should be empty, otherwise just a regular string.
+	// +optional
+	Value string `json:"value,omitempty"`
+	// Effect indicates the taint effect to match. Empty means match all taint effects.
+	// When specified, allowed values are NoSchedule, PreferNoSchedule.
+	// +optional
+	Effect string `json:"effect,omitempty"`
+}
+
+// Placement object to track where a deployment goes. The link is done thanks to uuid.
+type Placement struct {
+	// The uuid of the CloudForms service
+	UUID string `json:"uuid,omitempty"`
+	// CreationTimestamp the placement was made. UTC and RFC3339
+	CreationTimestamp time.Time `json:"creation_timestamp"`
+	// Date the placement was made. UTC and RFC3339  (deprecated)
+	Date time.Time `json:"date"`
+	// The cloud where it was scheduled to.
+	Cloud Cloud `json:"cloud"`
+	// Annotations
+	// +optional
+	Annotations map[string]string `json:"annotations"`
+}
+
+const(
+	// EventConfigReloaded is emitted when the config is reloaded from a new commit.
+	EventConfigReloaded string = "config.reloaded"
+	// EventConfigLoadFailed is emitted when the config of a new commit is invalid. The previous config is kept.
+	EventConfigLoadFailed string = "config.load_failed"
+	// EventTaintAdded is emitted when a taint is added to a cloud.
+	EventTaintAdded string = "taint.added"
+	// EventTaintRemoved is emitted when a taint is removed from a cloud.
+	EventTaintRemoved string = "taint.removed"
+	// EventPlacementCreated is emitted when a new placement is saved.
+	EventPlacementCreated string = "placement.created"
+	// EventPlacementDeleted is emitted when a placement is deleted.
+	EventPlacementDeleted string = "placement.deleted"
+)
+
+// Event is published when something changes in the scheduler.
+// Only the fields relevant to the event type are set.
+type Event struct {
+	// ID of the event in the stream. It can be used to resume the stream
+	// after that event.
+	ID string `json:"id,omitempty"`
+	// Type of the event, for example 'placement.created'
+	Type string `json:"type"`
+	// CreationTimestamp the event was emitted. UTC and RFC3339
+	CreationTimestamp time.Time `json:"creation_timestamp"`
+	// The cloud concerned by a taint event, with the taints after the change.
+	// +optional
+	Cloud *Cloud `json:"cloud,omitempty"`
+	// The taint added or removed.
+	// +optional
+	Taint *Taint `json:"taint,omitempty"`
+	// The placement created or deleted.
+	// +optional
+	Placement *Placement `json:"placement,omitempty"`
+	// The commit the config was reloaded to, or failed to load from.
+	// +optional
+	GitCommit *GitCommit `json:"git_commit,omitempty"`
+	// What the reload changed in the config, for config.reloaded.
+	// +optional
+	Changes *ConfigChanges `json:"changes,omitempty"`
+	// The error, for the failures like config.load_failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// Webhook is a subscription to the events. The matching events are POSTed to the URL.
+type Webhook struct {
+	// ID of the subscription. Generated when created through the API.
+	ID string `json:"id"`
+	// URL the events are POSTed to.
+	URL string `json:"url"`
+	// Events is the list of the event types to send. Empty means all events.
+	// +optional
+	Events []string `json:"events,omitempty"`
+	// Secret used to sign the payload with HMAC-SHA256. The signature is sent
+	// in the 'X-Agnostics-Signature' header. It's never returned by the API.
+	// +optional
+	Secret string `json:"secret,omitempty"`
+	// Source is where the subscription is defined: 'api' or 'config'.
+	Source string `json:"source"`
+	// CreationTimestamp the subscription was created.
+	// +optional
+	CreationTimestamp time.Time `json:"creation_timestamp,omitempty"`
+}
+
+// WebhookDeadLetter is a delivery that failed after all the retries.
+type WebhookDeadLetter struct {
+	Webhook Webhook `json:"webhook"`
+	Event Event `json:"event"`
+	Attempts int `json:"attempts"`
+	LastError string `json:"last_error"`
+	// CreationTimestamp the delivery was abandoned.
+	CreationTimestamp time.Time `json:"creation_timestamp"`
+}
+
+// Status is the detailed status of the components of a replica of the scheduler.
+type Status struct {
+	// Ready is true if the replica can serve the requests, see the /readyz endpoint.
+	Ready bool `json:"ready"`
+	// Stopping is true when the replica received SIGTERM.
+	Stopping bool `json:"stopping"`
+	// Degraded is true if the replica is ready but a component is failing, for example
+	// the config repository can't be pulled: the config may be outdated.
+	Degraded bool `json:"degraded"`
+	// Warnings are the reasons why the replica is degraded.
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+	Redis RedisStatus `json:"redis"`
+	Git GitStatus `json:"git"`
+	Config ConfigStatus `json:"config"`
+	// Subscriptions are the redis channels and streams the replica listens to.
+	Subscriptions []SubscriptionStatus `json:"subscriptions"`
+	// Replicas are the revisions of the config loaded by all the replicas, sorted by name.
+	// +optional
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+}
+
+// RedisStatus is the status of the connection to redis.
+type RedisStatus struct {
+	Connected bool `json:"connected"`
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// GitStatus is the status of the config repository.
+type GitStatus struct {
+	// Cloned is true once the repository is cloned.
+	Cloned bool `json:"cloned"`
+	// Cached is true if the clone of the cache directory was reused at startup.
+	// +optional
+	Cached bool `json:"cached,omitempty"`
+	// Head is the commit checked out.
+	// +optional
+	Head *GitCommit `json:"head,omitempty"`
+	// LastPull is when the repository was last pulled. Empty if it never was.
+	// +optional
+	LastPull *time.Time `json:"last_pull,omitempty"`
+	// LastPullError is the error of the last pull, empty if it succeeded.
+	// +optional
+	LastPullError string `json:"last_pull_error,omitempty"`
+}
+
+// ConfigStatus is the status of the config loaded from the repository.
+type ConfigStatus struct {
+	// Loaded is true once the config is loaded.
+	Loaded bool `json:"loaded"`
+	// Source is where the config is read from: 'git', 'dir' or 'http'.
+	Source string `json:"source"`
+	// Revision is the version of the config source the loaded config was read from: the commit
+	// hash for git, the SHA-256 of the files for a directory or of the tarball for http.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// LoadTimestamp is when the config was last loaded successfully.
+	// +optional
+	LoadTimestamp *time.Time `json:"load_timestamp,omitempty"`
+	// Clouds is the number of clouds in the config.
+	Clouds int `json:"clouds"`
+	// Error is the error of the last load, if it failed. The previous config is kept.
+	// +optional
+	Error string `json:"error,omitempty"`
+	// ErrorTimestamp is when the last load failed.
+	// +optional
+	ErrorTimestamp *time.Time `json:"error_timestamp,omitempty"`
+}
+
+// SubscriptionStatus is the status of a redis channel or stream the replica listens to.
+// ReplicaStatus is the config loaded by a replica, as it last reported it.
+type ReplicaStatus struct {
+	// Name is the hostname of the replica.
+	Name string `json:"name"`
+	// Revision is the version of the config source the replica loaded, see ConfigStatus.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// LoadTimestamp is when the replica last loaded the config successfully.
+	// +optional
+	LoadTimestamp *time.Time `json:"load_timestamp,omitempty"`
+	// Error is the error of the last load or update of the replica, if it failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+	// ReportTimestamp is when the replica last reported its status.
+	ReportTimestamp time.Time `json:"report_timestamp"`
+	// Diverged is true if the replica doesn't serve the expected revision: the commit
+	// pulled or pinned last, or the revision of most replicas if it's unknown.
+	Diverged bool `json:"diverged"`
+}
+
+type SubscriptionStatus struct {
+	Name string `json:"name"`
+	// Kind is 'channel' or 'stream'.
+	Kind string `json:"kind"`
+	Connected bool `json:"connected"`
+	// Since is when the subscription was last connected or disconnected.
+	Since time.Time `json:"since"`
+	// Error is why the subscription is disconnected, if known.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
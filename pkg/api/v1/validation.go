@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxKeyLength is the maximum length of the keys of selectors, preferences and annotations.
+	MaxKeyLength = 253
+	// MaxLabelValueLength is the maximum length of the values of selectors and preferences.
+	MaxLabelValueLength = 256
+	// MaxAnnotationValueLength is the maximum length of the value of an annotation.
+	MaxAnnotationValueLength = 4096
+	// MaxAnnotationsSize is the maximum total size of the keys and values of the annotations.
+	MaxAnnotationsSize = 64 * 1024
+)
+
+// keyRegexp is the format of the keys of selectors, preferences, annotations and tolerations:
+// alphanumeric characters, '-', '_', '.' and '/', starting and ending with an alphanumeric character.
+var keyRegexp = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_./]*[A-Za-z0-9])?$`)
+
+// FieldError describes why a field of a request is invalid.
+type FieldError struct {
+	// Field is the path of the field, for example 'tolerations[0].operator'.
+	Field string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors is the list of all the invalid fields of a request.
+type FieldErrors []FieldError
+
+func (errs FieldErrors) Error() string {
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validateKey returns the error for the key, or an empty string if the key is valid.
+func validateKey(key string) string {
+	if key == "" {
+		return "key must not be empty"
+	}
+	if len(key) > MaxKeyLength {
+		return fmt.Sprintf("key must be no more than %d characters", MaxKeyLength)
+	}
+	if ! keyRegexp.MatchString(key) {
+		return "key must consist of alphanumeric characters, '-', '_', '.' or '/', and must start and end with an alphanumeric character"
+	}
+	return ""
+}
+
+// sortedKeys returns the keys of m, sorted so the errors are always in the same order.
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateLabels validates the keys and values of a selector or a preference.
+func validateLabels(field string, labels map[string]string) FieldErrors {
+	errs := FieldErrors{}
+	for _, k := range sortedKeys(labels) {
+		path := fmt.Sprintf("%s[%s]", field, k)
+		if msg := validateKey(k); msg != "" {
+			errs = append(errs, FieldError{path, msg})
+		}
+		if len(labels[k]) > MaxLabelValueLength {
+			errs = append(errs, FieldError{path, fmt.Sprintf("value must be no more than %d characters", MaxLabelValueLength)})
+		}
+	}
+	return errs
+}
+
+// validateAnnotations validates the keys, the values and the total size of the annotations.
+func validateAnnotations(annotations map[string]string) FieldErrors {
+	errs := FieldErrors{}
+	size := 0
+	for _, k := range sortedKeys(annotations) {
+		path := fmt.Sprintf("annotations[%s]", k)
+		v := annotations[k]
+		size = size + len(k) + len(v)
+		if msg := validateKey(k); msg != "" {
+			errs = append(errs, FieldError{path, msg})
+		}
+		if v == "" {
+			errs = append(errs, FieldError{path, "value must not be empty"})
+		}
+		if len(v) > MaxAnnotationValueLength {
+			errs = append(errs, FieldError{path, fmt.Sprintf("value must be no more than %d characters", MaxAnnotationValueLength)})
+		}
+	}
+	if size > MaxAnnotationsSize {
+		errs = append(errs, FieldError{"annotations", fmt.Sprintf("total size must be no more than %d bytes", MaxAnnotationsSize)})
+	}
+	return errs
+}
+
+// Validate checks the toleration. field is the path of the toleration in the request.
+func (tol Toleration) Validate(field string) FieldErrors {
+	errs := FieldErrors{}
+	// The operator is case-insensitive, like in ToleratesTaint
+	switch strings.ToLower(tol.Operator) {
+	case "", strings.ToLower(TolerationOpEqual):
+		if tol.Key == "" {
+			errs = append(errs, FieldError{field + ".operator", "must be 'Exists' when the key is empty"})
+		}
+	case strings.ToLower(TolerationOpExists):
+		if tol.Value != "" {
+			errs = append(errs, FieldError{field + ".value", "must be empty when the operator is 'Exists'"})
+		}
+	default:
+		errs = append(errs, FieldError{field + ".operator", "must be 'Equal' or 'Exists'"})
+	}
+
+	if tol.Key != "" {
+		if msg := validateKey(tol.Key); msg != "" {
+			errs = append(errs, FieldError{field + ".key", msg})
+		}
+	}
+
+	switch tol.Effect {
+	case "", TaintEffectNoSchedule, TaintEffectPreferNoSchedule:
+	default:
+		errs = append(errs, FieldError{field + ".effect", "must be empty, 'NoSchedule' or 'PreferNoSchedule'"})
+	}
+	return errs
+}
+
+// Validate checks all the fields of the query and returns all the errors found.
+// The uuid is not checked, it's optional for dry runs.
+func (q ScheduleQuery) Validate() FieldErrors {
+	errs := FieldErrors{}
+	errs = append(errs, validateLabels("cloud_selector", q.CloudSelector)...)
+	errs = append(errs, validateLabels("cloud_preference", q.CloudPreference)...)
+	for i, tol := range q.Tolerations {
+		errs = append(errs, tol.Validate(fmt.Sprintf("tolerations[%d]", i))...)
+	}
+	errs = append(errs, validateAnnotations(q.Annotations)...)
+	return errs
+}
@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTolerationValidate(t *testing.T) {
+	testCases := []struct {
+		description string
+		toleration Toleration
+		expected []string
+	}{
+		{
+			description: "Equal with key",
+			toleration: Toleration{Key: "maintenance", Operator: TolerationOpEqual, Value: "yes", Effect: TaintEffectNoSchedule},
+			expected: []string{},
+		},
+		{
+			description: "Default operator with key",
+			toleration: Toleration{Key: "maintenance", Value: "yes"},
+			expected: []string{},
+		},
+		{
+			description: "Exists without key tolerates everything",
+			toleration: Toleration{Operator: TolerationOpExists},
+			expected: []string{},
+		},
+		{
+			description: "Equal without key",
+			toleration: Toleration{Operator: TolerationOpEqual, Value: "yes"},
+			expected: []string{"tol.operator"},
+		},
+		{
+			description: "Exists with value",
+			toleration: Toleration{Key: "maintenance", Operator: TolerationOpExists, Value: "yes"},
+			expected: []string{"tol.value"},
+		},
+		{
+			description: "Lower case operator",
+			toleration: Toleration{Key: "maintenance", Operator: "exists"},
+			expected: []string{},
+		},
+		{
+			description: "Lower case operator without key",
+			toleration: Toleration{Operator: "equal", Value: "yes"},
+			expected: []string{"tol.operator"},
+		},
+		{
+			description: "Unknown operator",
+			toleration: Toleration{Key: "maintenance", Operator: "In"},
+			expected: []string{"tol.operator"},
+		},
+		{
+			description: "Invalid key and effect",
+			toleration: Toleration{Key: "-bad key", Operator: TolerationOpExists, Effect: "NoExecute"},
+			expected: []string{"tol.key", "tol.effect"},
+		},
+	}
+
+	for _, tc := range testCases {
+		errs := tc.toleration.Validate("tol")
+		fields := []string{}
+		for _, e := range errs {
+			fields = append(fields, e.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%s: expected errors on %v, got %v", tc.description, tc.expected, errs)
+		}
+	}
+}
+
+func TestScheduleQueryValidate(t *testing.T) {
+	q := ScheduleQuery{
+		CloudSelector: map[string]string{"region": "na", "bad key": "x"},
+		CloudPreference: map[string]string{"purpose": strings.Repeat("x", MaxLabelValueLength+1)},
+		Tolerations: []Toleration{
+			{Key: "maintenance", Operator: TolerationOpExists},
+			{Operator: "Unknown"},
+		},
+		Annotations: map[string]string{
+			"owner": "",
+			"comment": strings.Repeat("x", MaxAnnotationValueLength+1),
+		},
+	}
+
+	expected := []string{
+		"cloud_selector[bad key]",
+		"cloud_preference[purpose]",
+		"tolerations[1].operator",
+		"annotations[comment]",
+		"annotations[owner]",
+	}
+
+	errs := q.Validate()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, e := range errs {
+		if e.Field != expected[i] {
+			t.Errorf("expected error %d on %s, got %s", i, expected[i], e.Field)
+		}
+	}
+
+	if errs := (ScheduleQuery{CloudSelector: map[string]string{"region": "na"}}).Validate(); len(errs) != 0 {
+		t.Errorf("expected no error, got %v", errs)
+	}
+}
+
+func TestAnnotationsSize(t *testing.T) {
+	annotations := map[string]string{}
+	for i := 0; i < 20; i++ {
+		annotations[strings.Repeat("k", i+1)] = strings.Repeat("x", MaxAnnotationValueLength)
+	}
+	errs := ScheduleQuery{Annotations: annotations}.Validate()
+	if len(errs) != 1 || errs[0].Field != "annotations" {
+		t.Errorf("expected the total size error, got %v", errs)
+	}
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// EventTypes is the list of the event types, that the event streams and the webhooks can filter on.
+var EventTypes = []string{
+	EventConfigReloaded,
+	EventConfigLoadFailed,
+	EventTaintAdded,
+	EventTaintRemoved,
+	EventPlacementCreated,
+	EventPlacementDeleted,
+}
+
+// IsEventType returns true if t is one of EventTypes.
+func IsEventType(t string) bool {
+	for _, v := range EventTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the URL and the event types of the webhook, created through the API
+// or defined in the config repository.
+func (w Webhook) Validate() error {
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Webhook must have a valid 'url' (http or https).")
+	}
+
+	for _, t := range w.Events {
+		if !IsEventType(t) {
+			return errors.New("Unknown event type '" + t + "'. Must be one of: " + strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// MatchEvent returns true if the event must be sent to the webhook.
+func (w Webhook) MatchEvent(e Event) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"testing"
+)
+
+func TestWebhookMatchEvent(t *testing.T) {
+	testCases := []struct {
+		description string
+		webhook     Webhook
+		event       Event
+		expected    bool
+	}{
+		{
+			description: "webhook without events matches all events",
+			webhook:     Webhook{URL: "http://localhost"},
+			event:       Event{Type: EventPlacementCreated},
+			expected:    true,
+		},
+		{
+			description: "webhook matches event in its list",
+			webhook:     Webhook{URL: "http://localhost", Events: []string{EventTaintAdded, EventTaintRemoved}},
+			event:       Event{Type: EventTaintRemoved},
+			expected:    true,
+		},
+		{
+			description: "webhook doesn't match event not in its list",
+			webhook:     Webhook{URL: "http://localhost", Events: []string{EventTaintAdded}},
+			event:       Event{Type: EventConfigReloaded},
+			expected:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		if r := tc.webhook.MatchEvent(tc.event); r != tc.expected {
+			t.Errorf("[%s] expected MatchEvent() to be %v, got %v", tc.description, tc.expected, r)
+		}
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	testCases := []struct {
+		description string
+		webhook     Webhook
+		valid       bool
+	}{
+		{"https URL", Webhook{URL: "https://chat.example.com/hook"}, true},
+		{"http URL with events", Webhook{URL: "http://localhost:8080", Events: []string{EventTaintAdded}}, true},
+		{"empty URL", Webhook{}, false},
+		{"ftp URL", Webhook{URL: "ftp://files.example.com"}, false},
+		{"URL without host", Webhook{URL: "https:///hook"}, false},
+		{"unknown event type", Webhook{URL: "https://chat.example.com", Events: []string{"cloud.deleted"}}, false},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.webhook.Validate(); (err == nil) != tc.valid {
+			t.Errorf("[%s] expected valid=%v, got %v", tc.description, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package v2
+
+import "github.com/redhat-gpe/agnostics/pkg/api/v1"
+
+// ProblemContentType is the media type of the errors, see RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypePrefix is the prefix of the 'type' member of a Problem,
+// followed by the reason.
+const ProblemTypePrefix = "urn:agnostics:problem:"
+
+// Reasons are the machine-readable codes of the errors.
+const (
+	ReasonInvalidBody string = "InvalidBody"
+	ReasonInvalidParameter string = "InvalidParameter"
+	ReasonInvalidTaint string = "InvalidTaint"
+	ReasonInvalidWebhook string = "InvalidWebhook"
+	ReasonUnauthorized string = "Unauthorized"
+	ReasonNotFound string = "NotFound"
+	ReasonMethodNotAllowed string = "MethodNotAllowed"
+	ReasonCloudNotFound string = "CloudNotFound"
+	ReasonTaintNotFound string = "TaintNotFound"
+	ReasonPlacementNotFound string = "PlacementNotFound"
+	ReasonWebhookNotFound string = "WebhookNotFound"
+	ReasonWebhookReadOnly string = "WebhookReadOnly"
+	ReasonAlreadyPlaced string = "AlreadyPlaced"
+	ReasonNoCloudAvailable string = "NoCloudAvailable"
+	ReasonTooManyRequests string = "TooManyRequests"
+	ReasonInternalError string = "InternalError"
+)
+
+// Problem is the body of all the error responses of the v2 API, as described in RFC 7807.
+type Problem struct {
+	// Type is ProblemTypePrefix followed by the reason.
+	Type string `json:"type"`
+	// Title is the short summary of the problem, it's the same for all occurrences of a reason.
+	Title string `json:"title"`
+	Status int `json:"status"`
+	// Detail is the explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request.
+	Instance string `json:"instance,omitempty"`
+	// Reason is the machine-readable code of the problem.
+	Reason string `json:"reason"`
+	// Errors lists the invalid fields of the request, if any.
+	Errors []v1.FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem is the constructor for a Problem.
+func NewProblem(status int, reason string, title string, detail string) Problem {
+	return Problem{
+		Type: ProblemTypePrefix + reason,
+		Title: title,
+		Status: status,
+		Detail: detail,
+		Reason: reason,
+	}
+}
+
+func (p Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + " " + p.Detail
+	}
+	return p.Title
+}
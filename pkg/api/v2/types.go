@@ -0,0 +1,16 @@
+// Package v2 contains the types of the v2 API of the scheduler.
+// The resources are the same as v1, only the routes and the errors change.
+package v2
+
+import "github.com/redhat-gpe/agnostics/pkg/api/v1"
+
+type Cloud = v1.Cloud
+type Taint = v1.Taint
+type Toleration = v1.Toleration
+type ScheduleQuery = v1.ScheduleQuery
+type Placement = v1.Placement
+type GitCommit = v1.GitCommit
+type Message = v1.Message
+type Event = v1.Event
+type Webhook = v1.Webhook
+type WebhookDeadLetter = v1.WebhookDeadLetter
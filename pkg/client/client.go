@@ -0,0 +1,209 @@
+// Package client is a Go client for the v1 API of the agnostics scheduler.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is matched by errors.Is when the API returns 404.
+var ErrNotFound = errors.New("not found")
+
+// ErrUnauthorized is matched by errors.Is when the API returns 401.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrAlreadyPlaced is matched by errors.Is when scheduling a uuid that already has a placement.
+var ErrAlreadyPlaced = errors.New("uuid already has a placement")
+
+// APIError is returned when the API responds with an error status.
+type APIError struct {
+	StatusCode int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is makes the typed errors ErrNotFound, ErrUnauthorized and ErrAlreadyPlaced
+// usable with errors.Is.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrAlreadyPlaced:
+		return e.StatusCode == http.StatusBadRequest && strings.Contains(e.Message, "already has a placement")
+	}
+	return false
+}
+
+// Client calls the v1 API of the scheduler.
+type Client struct {
+	baseURL string
+	username string
+	password string
+	httpClient *http.Client
+	maxRetries int
+	retryWait time.Duration
+}
+
+// Option configures the Client, see New.
+type Option func(*Client)
+
+// WithBasicAuth sets the credentials used for all the requests.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithHTTPClient sets the http.Client used, for example to configure TLS.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = h
+	}
+}
+
+// WithRetries sets the number of retries for the idempotent requests (GET, PUT, DELETE)
+// when the request fails or the server is unavailable (429, 502, 503, 504).
+// The delay between retries starts at wait and doubles each time, unless the server sends Retry-After.
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// New is the constructor for a Client. baseURL is the URL of the scheduler,
+// for example https://scheduler.example.com
+// By default, idempotent requests are retried 3 times.
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		retryWait: time.Second,
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns the delay before the next attempt.
+func (c *Client) retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return c.retryWait * time.Duration(1<<uint(attempt))
+}
+
+// send sends the request, with retries for idempotent methods, and returns the response.
+func (c *Client) send(ctx context.Context, method string, path string, body []byte, accept string) (*http.Response, error) {
+	retries := c.maxRetries
+	if method == "POST" {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", accept)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= retries || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		delay := c.retryDelay(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// do sends the request and decodes the JSON response in result.
+// If the API returns an error, it's returned as an *APIError.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, b, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, content)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
+
+func newAPIError(statusCode int, content []byte) *APIError {
+	apiError := v1.Error{}
+	if err := json.Unmarshal(content, &apiError); err == nil && apiError.Message != "" {
+		return &APIError{StatusCode: statusCode, Message: apiError.Message}
+	}
+	return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(content))}
+}
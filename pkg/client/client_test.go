@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/redhat-gpe/agnostics/internal/api"
+	"github.com/redhat-gpe/agnostics/internal/config"
+	"github.com/redhat-gpe/agnostics/internal/log"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPolicy = `---
+predicates:
+  - name: LabelPredicates
+  - name: TaintPredicates
+priorities:
+  - name: LabelPriorities
+    weight: 1
+  - name: TaintPriorities
+    weight: 1
+`
+
+var testClouds = map[string]string{
+	"openstack-blue.yml": "name: openstack-blue\nlabels:\n  region: na\n  purpose: ilt\n",
+	"openstack-red.yml":  "name: openstack-red\nlabels:\n  region: emea\n  purpose: ilt\n",
+}
+
+// setupConfig writes a config directory and loads it in-memory.
+// Redis is not available in the tests, so taints are empty.
+func setupConfig(t *testing.T) {
+	log.InitLoggers(false)
+	dir, err := ioutil.TempDir("", "agnostics-client-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	if err := os.Mkdir(filepath.Join(dir, "clouds"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testClouds {
+		if err := ioutil.WriteFile(filepath.Join(dir, "clouds", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "policy.yaml"), []byte(testPolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.LoadFromDir(dir)
+}
+
+// newTestServer starts a server with the real API handlers.
+func newTestServer(t *testing.T, apiAuth bool, htpasswd string) *httptest.Server {
+	setupConfig(t)
+	router, err := api.NewRouter(apiAuth, htpasswd, api.ValidationEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetClouds(t *testing.T) {
+	server := newTestServer(t, false, "")
+	c := New(server.URL)
+
+	clouds, err := c.GetClouds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clouds) != len(testClouds) {
+		t.Errorf("expected %d clouds, got %d", len(testClouds), len(clouds))
+	}
+
+	cloud, err := c.GetCloud(context.Background(), "openstack-red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cloud.Labels["region"] != "emea" {
+		t.Errorf("expected region emea, got %s", cloud.Labels["region"])
+	}
+
+	_, err = c.GetCloud(context.Background(), "unknown")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDryRunSchedule(t *testing.T) {
+	server := newTestServer(t, false, "")
+	c := New(server.URL)
+
+	p, err := c.DryRunSchedule(context.Background(), v1.ScheduleQuery{
+		CloudSelector: map[string]string{"purpose": "ilt"},
+		CloudPreference: map[string]string{"region": "emea"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Cloud.Name != "openstack-red" {
+		t.Errorf("expected placement on openstack-red, got %s", p.Cloud.Name)
+	}
+
+	_, err = c.DryRunSchedule(context.Background(), v1.ScheduleQuery{
+		CloudSelector: map[string]string{"region": "apac"},
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTaintErrors(t *testing.T) {
+	server := newTestServer(t, false, "")
+	c := New(server.URL)
+
+	_, err := c.Taint(context.Background(), "unknown", v1.Taint{Key: "foo", Effect: v1.TaintEffectNoSchedule})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_, err = c.Taint(context.Background(), "openstack-blue", v1.Taint{Key: "foo", Effect: "NoExecute"})
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusBadRequest || apiError.Message == "" {
+		t.Errorf("expected a 400 APIError, got %v", err)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	sum := sha1.Sum([]byte("secret"))
+	htpasswdFile, err := ioutil.TempFile("", "agnostics-htpasswd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(htpasswdFile.Name())
+	htpasswdFile.WriteString("admin:{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n")
+	htpasswdFile.Close()
+
+	server := newTestServer(t, true, htpasswdFile.Name())
+
+	_, err = New(server.URL, WithBasicAuth("admin", "wrong")).GetClouds(context.Background())
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+
+	if _, err := New(server.URL, WithBasicAuth("admin", "secret")).GetClouds(context.Background()); err != nil {
+		t.Errorf("expected no error with valid credentials, got %v", err)
+	}
+}
+
+func TestAlreadyPlaced(t *testing.T) {
+	// Placements require redis, the response of the API is reproduced here.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code": 400, "message": "This service uuid already has a placement"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).Schedule(context.Background(), v1.ScheduleQuery{UUID: "test"})
+	if !errors.Is(err, ErrAlreadyPlaced) {
+		t.Errorf("expected ErrAlreadyPlaced, got %v", err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("did not expect ErrNotFound")
+	}
+}
+
+func TestRetries(t *testing.T) {
+	setupConfig(t)
+	router, err := api.NewRouter(false, "", api.ValidationEnforce, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	if _, err := c.GetClouds(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+
+	// POST is not retried
+	attempts = 0
+	_, err = c.DryRunSchedule(context.Background(), v1.ScheduleQuery{})
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 APIError, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	server := newTestServer(t, false, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(server.URL).GetClouds(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	server := newTestServer(t, false, "")
+
+	status, err := New(server.URL).GetStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Config.Loaded || status.Config.Clouds != len(testClouds) {
+		t.Errorf("expected the config to be loaded with %d clouds, got %+v", len(testClouds), status.Config)
+	}
+	// Without redis
+	if status.Ready || status.Redis.Error == "" {
+		t.Errorf("expected the scheduler not to be ready without redis, got %+v", status)
+	}
+}
+
+func TestPinRepository(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	pinned := "none"
+	// Pinning requires the git repository and redis, the API is reproduced here.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repo/pin" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": 404, "message": "Not found"}`))
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			var pin v1.RepoPin
+			if err := json.NewDecoder(r.Body).Decode(&pin); err != nil || pin.Commit != commit {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"code": 404, "message": "Commit not found in the tracked ref of the config repository."}`))
+				return
+			}
+			pinned = pin.Commit
+		case "DELETE":
+			pinned = ""
+		}
+		w.Write([]byte(`{"message": "Request received."}`))
+	}))
+	defer server.Close()
+	c := New(server.URL)
+
+	if _, err := c.PinRepository(context.Background(), commit); err != nil {
+		t.Fatal(err)
+	}
+	if pinned != commit {
+		t.Errorf("expected the config to be pinned to %s, got %q", commit, pinned)
+	}
+
+	_, err := c.PinRepository(context.Background(), strings.Repeat("b", 40))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := c.UnpinRepository(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if pinned != "" {
+		t.Errorf("expected the config to be unpinned, got %q", pinned)
+	}
+}
+
+func TestPinRepositoryUnknownCommit(t *testing.T) {
+	// The config of the tests isn't read from git, no commit is known
+	server := newTestServer(t, false, "")
+
+	_, err := New(server.URL).PinRepository(context.Background(), strings.Repeat("a", 40))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	var apiError *APIError
+	_, err = New(server.URL).PinRepository(context.Background(), "aaaaaaa")
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400 APIError, got %v", err)
+	}
+}
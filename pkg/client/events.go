@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EventStream reads the events sent by the scheduler, see Client.Events.
+type EventStream struct {
+	body io.ReadCloser
+	scanner *bufio.Scanner
+	// LastID is the ID of the last event read. It can be used to resume the stream.
+	LastID string
+}
+
+// Events opens the stream of events. If since is not empty, the stream resumes after
+// the event 'since'. If types are provided, only those event types are received.
+// The stream ends when ctx is cancelled or Close is called.
+func (c *Client) Events(ctx context.Context, since string, types ...string) (*EventStream, error) {
+	query := url.Values{}
+	if since != "" {
+		query.Set("since", since)
+	}
+	if len(types) > 0 {
+		query.Set("type", strings.Join(types, ","))
+	}
+	path := "/api/v1/events"
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	// The stream is long-lived, the timeout of the client must not apply.
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		content, _ := ioutil.ReadAll(resp.Body)
+		return nil, newAPIError(resp.StatusCode, content)
+	}
+
+	return &EventStream{
+		body: resp.Body,
+		scanner: bufio.NewScanner(resp.Body),
+		LastID: since,
+	}, nil
+}
+
+// Next blocks until the next event is received. It returns io.EOF when the stream ends.
+func (s *EventStream) Next() (v1.Event, error) {
+	data := []string{}
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "":
+			// End of an event, comments-only blocks are ignored
+			if len(data) == 0 {
+				continue
+			}
+			var e v1.Event
+			if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &e); err != nil {
+				return v1.Event{}, err
+			}
+			if e.ID != "" {
+				s.LastID = e.ID
+			}
+			return e, nil
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return v1.Event{}, err
+	}
+	return v1.Event{}, io.EOF
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
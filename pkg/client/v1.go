@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"github.com/redhat-gpe/agnostics/pkg/api/v1"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+)
+
+// Health returns nil if the scheduler is healthy.
+func (c *Client) Health(ctx context.Context) error {
+	resp, err := c.send(ctx, "GET", "/api/v1/health", nil, "text/plain")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		content, _ := ioutil.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, content)
+	}
+	return nil
+}
+
+// GetStatus returns the status of the scheduler: redis, the git repository, the config,
+// the subscriptions and the replicas.
+func (c *Client) GetStatus(ctx context.Context) (v1.Status, error) {
+	status := v1.Status{}
+	err := c.do(ctx, "GET", "/api/v1/status", nil, &status)
+	return status, err
+}
+
+// GetClouds returns all the clouds.
+func (c *Client) GetClouds(ctx context.Context) ([]v1.Cloud, error) {
+	clouds := []v1.Cloud{}
+	err := c.do(ctx, "GET", "/api/v1/clouds", nil, &clouds)
+	return clouds, err
+}
+
+// GetCloud returns the cloud 'name'.
+func (c *Client) GetCloud(ctx context.Context, name string) (v1.Cloud, error) {
+	cloud := v1.Cloud{}
+	err := c.do(ctx, "GET", "/api/v1/clouds/"+url.PathEscape(name), nil, &cloud)
+	return cloud, err
+}
+
+// Taint adds the taint to the cloud and returns the updated cloud.
+func (c *Client) Taint(ctx context.Context, cloudName string, t v1.Taint) (v1.Cloud, error) {
+	cloud := v1.Cloud{}
+	err := c.do(ctx, "POST", "/api/v1/taint/"+url.PathEscape(cloudName), t, &cloud)
+	return cloud, err
+}
+
+// Untaint removes the taints matching key:effect of t and returns the updated cloud.
+func (c *Client) Untaint(ctx context.Context, cloudName string, t v1.Taint) (v1.Cloud, error) {
+	cloud := v1.Cloud{}
+	err := c.do(ctx, "POST", "/api/v1/taint/"+url.PathEscape(cloudName)+"/delete", t, &cloud)
+	return cloud, err
+}
+
+// UntaintByIndex removes the taint at position index and returns the updated cloud.
+func (c *Client) UntaintByIndex(ctx context.Context, cloudName string, index int) (v1.Cloud, error) {
+	cloud := v1.Cloud{}
+	err := c.do(ctx, "DELETE", "/api/v1/taint/"+url.PathEscape(cloudName)+"/"+strconv.Itoa(index), nil, &cloud)
+	return cloud, err
+}
+
+// UntaintAll removes all the taints of the cloud and returns the updated cloud.
+func (c *Client) UntaintAll(ctx context.Context, cloudName string) (v1.Cloud, error) {
+	cloud := v1.Cloud{}
+	err := c.do(ctx, "DELETE", "/api/v1/taints/"+url.PathEscape(cloudName), nil, &cloud)
+	return cloud, err
+}
+
+// GetRepository returns the commit of the config used by the scheduler.
+func (c *Client) GetRepository(ctx context.Context) (v1.GitCommit, error) {
+	commit := v1.GitCommit{}
+	err := c.do(ctx, "GET", "/api/v1/repo", nil, &commit)
+	return commit, err
+}
+
+// PullRepository requests the scheduler to pull the config repository.
+func (c *Client) PullRepository(ctx context.Context) (v1.Message, error) {
+	m := v1.Message{}
+	err := c.do(ctx, "PUT", "/api/v1/repo", nil, &m)
+	return m, err
+}
+
+// PinRepository pins the config of all the replicas to the commit, the full hash of a commit
+// of the tracked ref. The pulls are ignored until UnpinRepository.
+// If the commit is unknown, the error matches ErrNotFound.
+func (c *Client) PinRepository(ctx context.Context, commit string) (v1.Message, error) {
+	m := v1.Message{}
+	err := c.do(ctx, "PUT", "/api/v1/repo/pin", v1.RepoPin{Commit: commit}, &m)
+	return m, err
+}
+
+// UnpinRepository unpins the config: all the replicas pull the tracked ref again.
+func (c *Client) UnpinRepository(ctx context.Context) (v1.Message, error) {
+	m := v1.Message{}
+	err := c.do(ctx, "DELETE", "/api/v1/repo/pin", nil, &m)
+	return m, err
+}
+
+// GetRepositoryChanges returns what the last reload of the scheduler changed in the config.
+func (c *Client) GetRepositoryChanges(ctx context.Context) (v1.ConfigChanges, error) {
+	changes := v1.ConfigChanges{}
+	err := c.do(ctx, "GET", "/api/v1/repo/changes", nil, &changes)
+	return changes, err
+}
+
+// Schedule requests a placement. If the uuid already has a placement,
+// the error matches ErrAlreadyPlaced.
+func (c *Client) Schedule(ctx context.Context, q v1.ScheduleQuery) (v1.Placement, error) {
+	p := v1.Placement{}
+	err := c.do(ctx, "POST", "/api/v1/schedule", q, &p)
+	return p, err
+}
+
+// DryRunSchedule returns the placement the scheduler would make, without saving it.
+func (c *Client) DryRunSchedule(ctx context.Context, q v1.ScheduleQuery) (v1.Placement, error) {
+	p := v1.Placement{}
+	err := c.do(ctx, "POST", "/api/v1/schedule?dry_run=true", q, &p)
+	return p, err
+}
+
+// GetPlacements returns all the placements.
+func (c *Client) GetPlacements(ctx context.Context) ([]v1.Placement, error) {
+	p := []v1.Placement{}
+	err := c.do(ctx, "GET", "/api/v1/placements", nil, &p)
+	return p, err
+}
+
+// GetPlacement returns the placement of the uuid.
+func (c *Client) GetPlacement(ctx context.Context, uuid string) (v1.Placement, error) {
+	p := v1.Placement{}
+	err := c.do(ctx, "GET", "/api/v1/placements/"+url.PathEscape(uuid), nil, &p)
+	return p, err
+}
+
+// DeletePlacement deletes the placement of the uuid.
+func (c *Client) DeletePlacement(ctx context.Context, uuid string) (v1.Message, error) {
+	m := v1.Message{}
+	err := c.do(ctx, "DELETE", "/api/v1/placements/"+url.PathEscape(uuid), nil, &m)
+	return m, err
+}
+
+// RefreshCounters requests the scheduler to recalculate all the placement counters.
+func (c *Client) RefreshCounters(ctx context.Context) (v1.Message, error) {
+	m := v1.Message{}
+	err := c.do(ctx, "PUT", "/api/v1/counters", nil, &m)
+	return m, err
+}
+
+// GetWebhooks returns all the webhooks. Secrets are not returned.
+func (c *Client) GetWebhooks(ctx context.Context) ([]v1.Webhook, error) {
+	w := []v1.Webhook{}
+	err := c.do(ctx, "GET", "/api/v1/webhooks", nil, &w)
+	return w, err
+}
+
+// CreateWebhook registers the webhook and returns it with its ID.
+func (c *Client) CreateWebhook(ctx context.Context, w v1.Webhook) (v1.Webhook, error) {
+	result := v1.Webhook{}
+	err := c.do(ctx, "POST", "/api/v1/webhooks", w, &result)
+	return result, err
+}
+
+// DeleteWebhook deletes the webhook registered through the API.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) (v1.Message, error) {
+	m := v1.Message{}
+	err := c.do(ctx, "DELETE", "/api/v1/webhooks/"+url.PathEscape(id), nil, &m)
+	return m, err
+}
+
+// GetWebhookDeadLetters returns the failed webhook deliveries.
+func (c *Client) GetWebhookDeadLetters(ctx context.Context) ([]v1.WebhookDeadLetter, error) {
+	d := []v1.WebhookDeadLetter{}
+	err := c.do(ctx, "GET", "/api/v1/webhooks/deadletter", nil, &d)
+	return d, err
+}